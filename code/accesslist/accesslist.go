@@ -0,0 +1,186 @@
+package accesslist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"ethereum-development-with-go/code/dryrun"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+/*
+ * Generate EIP-2930 access lists and attach them to transactions.
+ */
+
+var (
+	// ErrUnsupported is returned when the node has no eth_createAccessList
+	ErrUnsupported = errors.New("accesslist: eth_createAccessList not supported")
+	// ErrExecution is returned when the call fails, no list helps then
+	ErrExecution = errors.New("accesslist: execution failed")
+)
+
+// Gas costs of EIP-2929 and EIP-2930 deciding whether an entry pays off
+const (
+	addressCost = 2400
+	slotCost    = 1900
+	// a listed slot is read warm for 100 instead of cold for 2100
+	slotSaving = 2000
+)
+
+// Result is an access list and the gas of the call with and without it
+type Result struct {
+	AccessList types.AccessList
+	GasWithout uint64
+	GasWith    uint64
+}
+
+// Saving is the gas the list saves, negative when it costs more
+func (r *Result) Saving() int64 {
+	return int64(r.GasWithout) - int64(r.GasWith)
+}
+
+// Worth reports whether attaching the list lowers the gas
+func (r *Result) Worth() bool {
+	return len(r.AccessList) > 0 && r.GasWith < r.GasWithout
+}
+
+// Creator creates the access list of a call
+type Creator interface {
+	CreateAccessList(ctx context.Context, msg ethereum.CallMsg) (*Result, error)
+}
+
+// RPC creates access lists with eth_createAccessList and measures them with
+// eth_estimateGas, both at the pending block
+type RPC struct {
+	client dryrun.Caller
+}
+
+// NewRPC returns a creator backed by a node, *rpc.Client implements client
+func NewRPC(client dryrun.Caller) *RPC {
+	return &RPC{client: client}
+}
+
+// CreateAccessList implements Creator
+func (r *RPC) CreateAccessList(ctx context.Context, msg ethereum.CallMsg) (*Result, error) {
+	var created struct {
+		AccessList types.AccessList `json:"accessList"`
+		Error      string           `json:"error"`
+	}
+	if err := r.client.CallContext(ctx, &created, "eth_createAccessList", dryrun.CallArg(msg), "pending"); err != nil {
+		var rpcErr rpc.Error
+		if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == -32601 {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+		}
+		return nil, err
+	}
+	if created.Error != "" {
+		return nil, fmt.Errorf("%w: %s", ErrExecution, created.Error)
+	}
+
+	result := &Result{AccessList: Trim(created.AccessList, msg.From, msg.To)}
+	var err error
+	msg.AccessList = nil
+	if result.GasWithout, err = r.estimate(ctx, msg); err != nil {
+		return nil, err
+	}
+	msg.AccessList = result.AccessList
+	if result.GasWith, err = r.estimate(ctx, msg); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *RPC) estimate(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	var gas hexutil.Uint64
+	err := r.client.CallContext(ctx, &gas, "eth_estimateGas", dryrun.CallArg(msg), "pending")
+	return uint64(gas), err
+}
+
+// fallback uses a second creator when the first is not supported
+type fallback struct {
+	primary, secondary Creator
+}
+
+// WithFallback returns a creator trying primary first and secondary when
+// primary returns ErrUnsupported. Both must run on the same chain, a Remote
+// on the same node backs up an RPC creator
+func WithFallback(primary, secondary Creator) Creator {
+	return &fallback{primary: primary, secondary: secondary}
+}
+
+// CreateAccessList implements Creator
+func (f *fallback) CreateAccessList(ctx context.Context, msg ethereum.CallMsg) (*Result, error) {
+	result, err := f.primary.CreateAccessList(ctx, msg)
+	if errors.Is(err, ErrUnsupported) {
+		return f.secondary.CreateAccessList(ctx, msg)
+	}
+	return result, err
+}
+
+// Trim drops the entries that cost more than they save. The sender, the
+// recipient and the precompiles are warm anyway, so listing them only pays
+// for the slots, which takes more than addressCost / (slotSaving - slotCost)
+// of them.
+func Trim(list types.AccessList, from common.Address, to *common.Address) types.AccessList {
+	warm := map[common.Address]bool{from: true}
+	if to != nil {
+		warm[*to] = true
+	}
+	for _, address := range vm.PrecompiledAddressesBerlin {
+		warm[address] = true
+	}
+
+	trimmed := types.AccessList{}
+	for _, tuple := range list {
+		if warm[tuple.Address] && len(tuple.StorageKeys)*(slotSaving-slotCost) <= addressCost {
+			continue
+		}
+		trimmed = append(trimmed, tuple)
+	}
+
+	return trimmed
+}
+
+// Apply returns a copy of an unsigned transaction carrying list. Legacy
+// transactions become EIP-2930 ones for chainID. The gas limit is kept, set
+// it from Result.GasWith.
+func Apply(tx *types.Transaction, chainID *big.Int, list types.AccessList) (*types.Transaction, error) {
+	switch tx.Type() {
+	case types.LegacyTxType, types.AccessListTxType:
+		if tx.Type() == types.AccessListTxType {
+			chainID = tx.ChainId()
+		}
+		return types.NewTx(&types.AccessListTx{
+			ChainID:    chainID,
+			Nonce:      tx.Nonce(),
+			GasPrice:   tx.GasPrice(),
+			Gas:        tx.Gas(),
+			To:         tx.To(),
+			Value:      tx.Value(),
+			Data:       tx.Data(),
+			AccessList: list,
+		}), nil
+	case types.DynamicFeeTxType:
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasTipCap:  tx.GasTipCap(),
+			GasFeeCap:  tx.GasFeeCap(),
+			Gas:        tx.Gas(),
+			To:         tx.To(),
+			Value:      tx.Value(),
+			Data:       tx.Data(),
+			AccessList: list,
+		}), nil
+	default:
+		return nil, fmt.Errorf("accesslist: unsupported transaction type %d", tx.Type())
+	}
+}
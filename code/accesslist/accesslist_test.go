@@ -0,0 +1,245 @@
+package accesslist
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	store "ethereum-development-with-go/code/contracts"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	caller = common.HexToAddress("0x96216849c49358B10257cb55b28eA603c874b05E")
+	// probe reads its own slot 0 and the balance of target
+	probe  = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	target = common.HexToAddress("0x2000000000000000000000000000000000000002")
+)
+
+func probeCode() []byte {
+	code := []byte{0x60, 0x00, 0x54, 0x50, 0x73} // PUSH1 0 SLOAD POP PUSH20
+	code = append(code, target.Bytes()...)
+	return append(code, 0x31, 0x50, 0x00) // BALANCE POP STOP
+}
+
+func newBackend(t *testing.T) *backends.SimulatedBackend {
+	return backends.NewSimulatedBackend(core.GenesisAlloc{
+		caller: {Balance: big.NewInt(1000000000000000000)},
+		probe:  {Balance: new(big.Int), Code: probeCode()},
+	}, 8000000)
+}
+
+func TestLocal(t *testing.T) {
+	t.Parallel()
+	client := newBackend(t)
+	defer client.Close()
+
+	result, err := NewLocal(client.Blockchain()).CreateAccessList(context.Background(), ethereum.CallMsg{From: caller, To: &probe})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the probe's own slot is not worth listing, it is warm as the recipient
+	if len(result.AccessList) != 1 || result.AccessList[0].Address != target || len(result.AccessList[0].StorageKeys) != 0 {
+		t.Errorf("Expected only %v, got %v", target.Hex(), result.AccessList)
+	}
+	// a cold BALANCE costs 2600, a listed address 2400 and then 100
+	if result.Saving() != 100 || !result.Worth() {
+		t.Errorf("Expected a saving of 100, got %v", result.Saving())
+	}
+}
+
+func TestLocalDirectCall(t *testing.T) {
+	t.Parallel()
+	key, _ := crypto.GenerateKey()
+	auth, _ := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+	client := backends.NewSimulatedBackend(core.GenesisAlloc{
+		auth.From: {Balance: big.NewInt(1000000000000000000)},
+	}, 8000000)
+	defer client.Close()
+
+	address, _, _, err := store.DeployStore(auth, client, "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Commit()
+
+	parsed, _ := abi.JSON(strings.NewReader(store.StoreABI))
+	data, _ := parsed.Pack("setItem", [32]byte{1}, [32]byte{2})
+	result, err := NewLocal(client.Blockchain()).CreateAccessList(context.Background(), ethereum.CallMsg{From: auth.From, To: &address, Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// setItem only touches the store's own storage
+	if len(result.AccessList) != 0 || result.Worth() || result.GasWith != result.GasWithout {
+		t.Errorf("Expected no list for a direct call, got %v saving %v", result.AccessList, result.Saving())
+	}
+}
+
+func TestRemote(t *testing.T) {
+	t.Parallel()
+	key, _ := crypto.GenerateKey()
+	auth, _ := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+	client := backends.NewSimulatedBackend(core.GenesisAlloc{
+		auth.From: {Balance: big.NewInt(1000000000000000000)},
+		probe:     {Balance: new(big.Int), Code: probeCode()},
+	}, 8000000)
+	defer client.Close()
+
+	address, _, instance, err := store.DeployStore(auth, client, "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Commit()
+	if _, err := instance.SetItem(auth, [32]byte{1}, [32]byte{2}); err != nil {
+		t.Fatal(err)
+	}
+	client.Commit()
+
+	parsed, _ := abi.JSON(strings.NewReader(store.StoreABI))
+	overwrite, _ := parsed.Pack("setItem", [32]byte{1}, [32]byte{3})
+	fresh, _ := parsed.Pack("setItem", [32]byte{4}, [32]byte{5})
+	ctx := context.Background()
+	for _, msg := range []ethereum.CallMsg{
+		{From: auth.From, To: &probe},
+		{From: auth.From, To: &address, Data: overwrite},
+		{From: auth.From, To: &address, Data: fresh},
+	} {
+		// the node state gives what the chain itself gives
+		expected, err := NewLocal(client.Blockchain()).CreateAccessList(ctx, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := NewRemote(client, nil).CreateAccessList(ctx, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equal(result.AccessList, expected.AccessList) || result.GasWith != expected.GasWith || result.GasWithout != expected.GasWithout {
+			t.Errorf("Expected %v with %d and %d gas, got %v with %d and %d", expected.AccessList, expected.GasWith, expected.GasWithout,
+				result.AccessList, result.GasWith, result.GasWithout)
+		}
+	}
+}
+
+// fakeEth answers like a node with eth_createAccessList
+type fakeEth struct{}
+
+func (fakeEth) CreateAccessList(args map[string]interface{}, block string) (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"accessList": types.AccessList{
+			{Address: probe, StorageKeys: []common.Hash{{}}},
+			{Address: target, StorageKeys: []common.Hash{}},
+		},
+		"gasUsed": "0x5208",
+	}, nil
+}
+
+func (fakeEth) EstimateGas(args map[string]interface{}, block string) (hexutil.Uint64, error) {
+	if list, ok := args["accessList"].([]interface{}); ok && len(list) > 0 {
+		return 29900, nil
+	}
+	return 30000, nil
+}
+
+// noAccessList is a node without eth_createAccessList
+type noAccessList struct{}
+
+func (noAccessList) BlockNumber() hexutil.Uint64 { return 0 }
+
+func dial(t *testing.T, service interface{}) *rpc.Client {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", service); err != nil {
+		t.Fatal(err)
+	}
+	return rpc.DialInProc(server)
+}
+
+func TestRPC(t *testing.T) {
+	t.Parallel()
+	client := dial(t, fakeEth{})
+	defer client.Close()
+
+	result, err := NewRPC(client).CreateAccessList(context.Background(), ethereum.CallMsg{From: caller, To: &probe})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.AccessList) != 1 || result.AccessList[0].Address != target {
+		t.Errorf("Expected the recipient trimmed, got %v", result.AccessList)
+	}
+	if result.GasWithout != 30000 || result.GasWith != 29900 {
+		t.Errorf("Expected 30000 and 29900, got %v and %v", result.GasWithout, result.GasWith)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	t.Parallel()
+	client := dial(t, noAccessList{})
+	defer client.Close()
+	backend := newBackend(t)
+	defer backend.Close()
+
+	msg := ethereum.CallMsg{From: caller, To: &probe}
+	if _, err := NewRPC(client).CreateAccessList(context.Background(), msg); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("Expected %v, got %v", ErrUnsupported, err)
+	}
+	result, err := WithFallback(NewRPC(client), NewLocal(backend.Blockchain())).CreateAccessList(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.AccessList) != 1 || result.Saving() != 100 {
+		t.Errorf("Expected the local list, got %v saving %v", result.AccessList, result.Saving())
+	}
+
+	// the state of the node backs it up as well
+	if result, err = WithFallback(NewRPC(client), NewRemote(backend, nil)).CreateAccessList(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.AccessList) != 1 || result.Saving() != 100 {
+		t.Errorf("Expected the remote list, got %v saving %v", result.AccessList, result.Saving())
+	}
+}
+
+func TestTrimAndApply(t *testing.T) {
+	t.Parallel()
+	many := make([]common.Hash, 25)
+	for i := range many {
+		many[i] = common.BigToHash(big.NewInt(int64(i)))
+	}
+	list := types.AccessList{
+		{Address: caller, StorageKeys: []common.Hash{}},
+		{Address: common.BytesToAddress([]byte{1}), StorageKeys: []common.Hash{}},
+		{Address: probe, StorageKeys: many},
+		{Address: target, StorageKeys: []common.Hash{}},
+	}
+	trimmed := Trim(list, caller, &probe)
+	if len(trimmed) != 2 || trimmed[0].Address != probe || trimmed[1].Address != target {
+		t.Errorf("Expected the recipient with 25 slots and the target, got %v", trimmed)
+	}
+
+	legacy := types.NewTransaction(1, probe, big.NewInt(1), 50000, big.NewInt(1e9), nil)
+	tx, err := Apply(legacy, big.NewInt(1337), trimmed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Type() != types.AccessListTxType || tx.ChainId().Int64() != 1337 || len(tx.AccessList()) != 2 || tx.Nonce() != 1 {
+		t.Errorf("Expected an EIP-2930 transaction for chain 1337, got type %v chain %v", tx.Type(), tx.ChainId())
+	}
+
+	dynamic := types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(5), GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2), Gas: 50000, To: &probe})
+	if tx, err = Apply(dynamic, nil, trimmed); err != nil {
+		t.Fatal(err)
+	}
+	if tx.Type() != types.DynamicFeeTxType || tx.ChainId().Int64() != 5 || len(tx.AccessList()) != 2 {
+		t.Errorf("Expected an EIP-1559 transaction for chain 5, got type %v chain %v", tx.Type(), tx.ChainId())
+	}
+}
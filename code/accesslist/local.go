@@ -0,0 +1,234 @@
+package accesslist
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	cmath "github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// maxRounds bounds the runs needed for the list to stop changing, a list can
+// change the gas a call sees and so the path it takes
+const maxRounds = 10
+
+// Local derives access lists by running calls through a tracing EVM on a
+// local chain, such as the one of a SimulatedBackend. Remote does the same
+// with the state of a node
+type Local struct {
+	chain *core.BlockChain
+}
+
+// NewLocal returns a creator executing on the head state of chain
+func NewLocal(chain *core.BlockChain) *Local {
+	return &Local{chain: chain}
+}
+
+// CreateAccessList implements Creator
+func (l *Local) CreateAccessList(ctx context.Context, msg ethereum.CallMsg) (*Result, error) {
+	return settle(msg, l.run)
+}
+
+// run executes msg with list on a copy of the head state
+func (l *Local) run(msg ethereum.CallMsg, list types.AccessList) (uint64, types.AccessList, error) {
+	statedb, err := l.chain.State()
+	if err != nil {
+		return 0, nil, err
+	}
+	statedb.SetBalance(msg.From, cmath.MaxBig256)
+
+	blockContext := core.NewEVMBlockContext(l.chain.CurrentHeader(), l.chain, nil)
+	return execute(statedb, blockContext, l.chain.Config(), msg, list)
+}
+
+// settle runs msg until the list the tracer sees stops changing and returns
+// the trimmed list with the gas of msg with and without it. run returns the
+// gas used and the list the tracer saw
+func settle(msg ethereum.CallMsg, run func(ethereum.CallMsg, types.AccessList) (uint64, types.AccessList, error)) (*Result, error) {
+	gasWithout, _, err := run(msg, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	list := msg.AccessList
+	for round := 0; ; round++ {
+		_, traced, err := run(msg, list)
+		if err != nil {
+			return nil, err
+		}
+		if equal(traced, list) {
+			break
+		}
+		if round == maxRounds {
+			return nil, fmt.Errorf("accesslist: list did not settle after %d runs", maxRounds)
+		}
+		list = traced
+	}
+
+	result := &Result{AccessList: Trim(list, msg.From, msg.To), GasWithout: gasWithout}
+	if result.GasWith, _, err = run(msg, result.AccessList); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// execute runs msg with list on statedb and returns the gas used and the
+// list the tracer saw. Fees are zero like in eth_call, callers give the
+// sender the balance for the value
+func execute(statedb vm.StateDB, blockContext vm.BlockContext, config *params.ChainConfig, msg ethereum.CallMsg, list types.AccessList) (uint64, types.AccessList, error) {
+	to := crypto.CreateAddress(msg.From, statedb.GetNonce(msg.From))
+	if msg.To != nil {
+		to = *msg.To
+	}
+	precompiles := vm.ActivePrecompiles(config.Rules(blockContext.BlockNumber, false))
+	tracer := newTracer(list, msg.From, to, precompiles)
+
+	gas := msg.Gas
+	if gas == 0 {
+		gas = blockContext.GasLimit
+	}
+	value := msg.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	zero := new(big.Int)
+	message := types.NewMessage(msg.From, msg.To, 0, value, gas, zero, zero, zero, msg.Data, list, true)
+
+	evm := vm.NewEVM(blockContext, core.NewEVMTxContext(message), statedb, config,
+		vm.Config{NoBaseFee: true, Debug: true, Tracer: tracer})
+	result, err := core.ApplyMessage(evm, message, new(core.GasPool).AddGas(math.MaxUint64))
+	if err != nil {
+		return 0, nil, err
+	}
+	if result.Failed() {
+		return 0, nil, fmt.Errorf("%w: %v", ErrExecution, result.Err)
+	}
+
+	return result.UsedGas, tracer.list(), nil
+}
+
+// tracer records the addresses and slots a call touches, like geth's
+// AccessListTracer
+type tracer struct {
+	exclude   map[common.Address]bool
+	addresses []common.Address
+	slots     map[common.Address][]common.Hash
+	seen      map[common.Address]map[common.Hash]bool
+}
+
+func newTracer(list types.AccessList, from, to common.Address, precompiles []common.Address) *tracer {
+	t := &tracer{
+		exclude: map[common.Address]bool{from: true, to: true},
+		slots:   make(map[common.Address][]common.Hash),
+		seen:    make(map[common.Address]map[common.Hash]bool),
+	}
+	for _, address := range precompiles {
+		t.exclude[address] = true
+	}
+	for _, tuple := range list {
+		if !t.exclude[tuple.Address] {
+			t.addAddress(tuple.Address)
+		}
+		for _, slot := range tuple.StorageKeys {
+			t.addSlot(tuple.Address, slot)
+		}
+	}
+
+	return t
+}
+
+func (t *tracer) addAddress(address common.Address) {
+	if _, ok := t.seen[address]; ok {
+		return
+	}
+	t.seen[address] = make(map[common.Hash]bool)
+	t.addresses = append(t.addresses, address)
+}
+
+func (t *tracer) addSlot(address common.Address, slot common.Hash) {
+	t.addAddress(address)
+	if t.seen[address][slot] {
+		return
+	}
+	t.seen[address][slot] = true
+	t.slots[address] = append(t.slots[address], slot)
+}
+
+func (t *tracer) list() types.AccessList {
+	list := types.AccessList{}
+	for _, address := range t.addresses {
+		keys := t.slots[address]
+		if keys == nil {
+			keys = []common.Hash{}
+		}
+		list = append(list, types.AccessTuple{Address: address, StorageKeys: keys})
+	}
+	return list
+}
+
+// CaptureState records the operands of opcodes that warm an address or slot
+func (t *tracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	stack := scope.Stack
+	size := len(stack.Data())
+	switch {
+	case (op == vm.SLOAD || op == vm.SSTORE) && size >= 1:
+		t.addSlot(scope.Contract.Address(), common.Hash(stack.Back(0).Bytes32()))
+	case (op == vm.EXTCODECOPY || op == vm.EXTCODEHASH || op == vm.EXTCODESIZE || op == vm.BALANCE || op == vm.SELFDESTRUCT) && size >= 1:
+		if address := common.Address(stack.Back(0).Bytes20()); !t.exclude[address] {
+			t.addAddress(address)
+		}
+	case (op == vm.CALL || op == vm.CALLCODE || op == vm.DELEGATECALL || op == vm.STATICCALL) && size >= 5:
+		if address := common.Address(stack.Back(1).Bytes20()); !t.exclude[address] {
+			t.addAddress(address)
+		}
+	}
+}
+
+func (t *tracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+}
+
+func (t *tracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+}
+
+func (t *tracer) CaptureExit(output []byte, gasUsed uint64, err error) {}
+
+func (t *tracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+func (t *tracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {}
+
+// equal compares two lists as sets
+func equal(a, b types.AccessList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	keys := make(map[common.Address]map[common.Hash]bool)
+	for _, tuple := range a {
+		keys[tuple.Address] = make(map[common.Hash]bool)
+		for _, slot := range tuple.StorageKeys {
+			keys[tuple.Address][slot] = true
+		}
+	}
+	for _, tuple := range b {
+		slots, ok := keys[tuple.Address]
+		if !ok || len(slots) != len(tuple.StorageKeys) {
+			return false
+		}
+		for _, slot := range tuple.StorageKeys {
+			if !slots[slot] {
+				return false
+			}
+		}
+	}
+	return true
+}
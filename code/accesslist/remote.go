@@ -0,0 +1,289 @@
+package accesslist
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	cmath "github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// maxFetches bounds the runs of one call that read state not fetched yet,
+// every run fetches all it read
+const maxFetches = 64
+
+// StateReader reads headers and the state at a block, ethclient and the
+// simulated backend implement it
+type StateReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+	StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error)
+}
+
+// chainIDReader is a StateReader that knows the chain ID, as ethclient does
+type chainIDReader interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+}
+
+// Remote derives access lists like Local, on the latest state of a node.
+// Accounts and slots are fetched over RPC as the call reads them and the
+// call runs again until it read nothing new, so any node can back up one
+// without eth_createAccessList
+type Remote struct {
+	reader StateReader
+	config *params.ChainConfig
+}
+
+// NewRemote returns a creator reading state from reader. config gives the
+// fork rules, nil is London with the chain ID of reader when it has one
+func NewRemote(reader StateReader, config *params.ChainConfig) *Remote {
+	return &Remote{reader: reader, config: config}
+}
+
+// CreateAccessList implements Creator
+func (r *Remote) CreateAccessList(ctx context.Context, msg ethereum.CallMsg) (*Result, error) {
+	config, err := r.chainConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// every run reads the same block
+	header, err := r.reader.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	baseFee := header.BaseFee
+	if baseFee == nil {
+		baseFee = new(big.Int)
+	}
+	blockContext := vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		GetHash: func(number uint64) common.Hash {
+			header, err := r.reader.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+			if err != nil {
+				return common.Hash{}
+			}
+			return header.Hash()
+		},
+		Coinbase:    header.Coinbase,
+		GasLimit:    header.GasLimit,
+		BlockNumber: header.Number,
+		Time:        new(big.Int).SetUint64(header.Time),
+		Difficulty:  header.Difficulty,
+		BaseFee:     baseFee,
+	}
+
+	cache := &fetched{
+		accounts: make(map[common.Address]*fetchedAccount),
+		slots:    make(map[common.Address]map[common.Hash]common.Hash),
+	}
+	return settle(msg, func(msg ethereum.CallMsg, list types.AccessList) (uint64, types.AccessList, error) {
+		for i := 0; ; i++ {
+			statedb, err := cache.state()
+			if err != nil {
+				return 0, nil, err
+			}
+			statedb.SetBalance(msg.From, cmath.MaxBig256)
+			gas, traced, err := execute(statedb, blockContext, config, msg, list)
+			// a run on incomplete state proves nothing, failed or not
+			if statedb.complete() {
+				return gas, traced, err
+			}
+			if i == maxFetches {
+				return 0, nil, fmt.Errorf("accesslist: state still incomplete after %d fetches", maxFetches)
+			}
+			if err := cache.fetch(ctx, r.reader, header.Number, statedb); err != nil {
+				return 0, nil, err
+			}
+		}
+	})
+}
+
+func (r *Remote) chainConfig(ctx context.Context) (*params.ChainConfig, error) {
+	if r.config != nil {
+		return r.config, nil
+	}
+	config := *params.AllEthashProtocolChanges
+	if reader, ok := r.reader.(chainIDReader); ok {
+		chainID, err := reader.ChainID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		config.ChainID = chainID
+	}
+	return &config, nil
+}
+
+// fetchedAccount is an account as the node has it
+type fetchedAccount struct {
+	balance *big.Int
+	nonce   uint64
+	code    []byte
+}
+
+// fetched is the state fetched for a call so far
+type fetched struct {
+	accounts map[common.Address]*fetchedAccount
+	slots    map[common.Address]map[common.Hash]common.Hash
+}
+
+// state returns a state holding what was fetched as committed, so the
+// original values EIP-2200 prices SSTORE by are those of the node
+func (f *fetched) state() (*remoteState, error) {
+	db := state.NewDatabase(rawdb.NewMemoryDatabase())
+	statedb, err := state.New(common.Hash{}, db, nil)
+	if err != nil {
+		return nil, err
+	}
+	for address, account := range f.accounts {
+		statedb.SetBalance(address, account.balance)
+		statedb.SetNonce(address, account.nonce)
+		statedb.SetCode(address, account.code)
+	}
+	for address, slots := range f.slots {
+		for key, value := range slots {
+			if value != (common.Hash{}) {
+				statedb.SetState(address, key, value)
+			}
+		}
+	}
+	// missing accounts are left out as empty
+	root, err := statedb.Commit(true)
+	if err != nil {
+		return nil, err
+	}
+	if statedb, err = state.New(root, db, nil); err != nil {
+		return nil, err
+	}
+
+	return &remoteState{
+		StateDB:  statedb,
+		fetched:  f,
+		accounts: make(map[common.Address]bool),
+		slots:    make(map[common.Address]map[common.Hash]bool),
+	}, nil
+}
+
+// fetch reads the accounts and slots statedb lacked at block
+func (f *fetched) fetch(ctx context.Context, reader StateReader, block *big.Int, statedb *remoteState) error {
+	for address := range statedb.accounts {
+		balance, err := reader.BalanceAt(ctx, address, block)
+		if err != nil {
+			return err
+		}
+		nonce, err := reader.NonceAt(ctx, address, block)
+		if err != nil {
+			return err
+		}
+		code, err := reader.CodeAt(ctx, address, block)
+		if err != nil {
+			return err
+		}
+		f.accounts[address] = &fetchedAccount{balance: balance, nonce: nonce, code: code}
+	}
+	for address, keys := range statedb.slots {
+		if f.slots[address] == nil {
+			f.slots[address] = make(map[common.Hash]common.Hash)
+		}
+		for key := range keys {
+			value, err := reader.StorageAt(ctx, address, key, block)
+			if err != nil {
+				return err
+			}
+			f.slots[address][key] = common.BytesToHash(value)
+		}
+	}
+	return nil
+}
+
+// remoteState is the state of a run, it records the accounts and slots read
+// before they were fetched
+type remoteState struct {
+	*state.StateDB
+	fetched *fetched
+	// accounts and slots are those still to fetch
+	accounts map[common.Address]bool
+	slots    map[common.Address]map[common.Hash]bool
+}
+
+func (s *remoteState) complete() bool {
+	return len(s.accounts) == 0 && len(s.slots) == 0
+}
+
+func (s *remoteState) account(address common.Address) {
+	if _, ok := s.fetched.accounts[address]; !ok {
+		s.accounts[address] = true
+	}
+}
+
+func (s *remoteState) slot(address common.Address, key common.Hash) {
+	// storage is only kept for accounts that exist
+	s.account(address)
+	if _, ok := s.fetched.slots[address][key]; ok {
+		return
+	}
+	if s.slots[address] == nil {
+		s.slots[address] = make(map[common.Hash]bool)
+	}
+	s.slots[address][key] = true
+}
+
+func (s *remoteState) CreateAccount(address common.Address) {
+	s.account(address)
+	s.StateDB.CreateAccount(address)
+}
+
+func (s *remoteState) GetBalance(address common.Address) *big.Int {
+	s.account(address)
+	return s.StateDB.GetBalance(address)
+}
+
+func (s *remoteState) GetNonce(address common.Address) uint64 {
+	s.account(address)
+	return s.StateDB.GetNonce(address)
+}
+
+func (s *remoteState) GetCode(address common.Address) []byte {
+	s.account(address)
+	return s.StateDB.GetCode(address)
+}
+
+func (s *remoteState) GetCodeSize(address common.Address) int {
+	s.account(address)
+	return s.StateDB.GetCodeSize(address)
+}
+
+func (s *remoteState) GetCodeHash(address common.Address) common.Hash {
+	s.account(address)
+	return s.StateDB.GetCodeHash(address)
+}
+
+func (s *remoteState) Exist(address common.Address) bool {
+	s.account(address)
+	return s.StateDB.Exist(address)
+}
+
+func (s *remoteState) Empty(address common.Address) bool {
+	s.account(address)
+	return s.StateDB.Empty(address)
+}
+
+func (s *remoteState) GetState(address common.Address, key common.Hash) common.Hash {
+	s.slot(address, key)
+	return s.StateDB.GetState(address, key)
+}
+
+func (s *remoteState) GetCommittedState(address common.Address, key common.Hash) common.Hash {
+	s.slot(address, key)
+	return s.StateDB.GetCommittedState(address, key)
+}
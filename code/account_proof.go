@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	proof "ethereum-development-with-go/code/proof"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func main() {
+	// 状态根来自我们信任的节点，账户和存储证明来自不受信任的提供者
+	trusted, err := ethclient.Dial("https://cloudflare-eth.com")
+	if err != nil {
+		log.Fatal(err)
+	}
+	provider, err := rpc.Dial("https://mainnet.infura.io/v3/**********")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// USDT合约，槽0保存owner
+	account := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	slot := common.BigToHash(common.Big0)
+	verified, err := proof.VerifiedAccount(context.Background(), trusted, provider, account, []common.Hash{slot}, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(verified.Balance)                                            // ...
+	fmt.Println(verified.Nonce)                                              // ...
+	fmt.Println(verified.HasCode())                                          // true
+	fmt.Println(common.BytesToAddress(verified.Storage[slot].Bytes()).Hex()) // 0x...
+}
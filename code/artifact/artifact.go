@@ -0,0 +1,154 @@
+package artifact
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"ethereum-development-with-go/code/verify"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+/*
+ * Load the build artifacts of solc, Hardhat and Foundry into one type, and
+ * deploy, call and transact with them by method name without abigen.
+ */
+
+var (
+	ErrFormat     = errors.New("artifact: unknown format")
+	ErrNotFound   = errors.New("artifact: contract not found")
+	ErrAmbiguous  = errors.New("artifact: contract name in several sources")
+	ErrNoBytecode = errors.New("artifact: no bytecode")
+	// ErrUnlinked is returned when deploying code with library placeholders
+	ErrUnlinked = errors.New("artifact: unlinked libraries")
+)
+
+// Artifact is a compiled contract
+type Artifact struct {
+	// Name is the contract name, Source the file it is defined in when known
+	Name   string
+	Source string
+	ABI    abi.ABI
+	// Bytecode is the creation code, DeployedBytecode the runtime, both
+	// with zeros for the libraries until linked
+	Bytecode         []byte
+	DeployedBytecode []byte
+	// Immutables are where the runtime keeps immutables, when given
+	Immutables []verify.Reference
+
+	// library placeholders and their offsets in both codes
+	links         map[string][]int
+	deployedLinks map[string][]int
+}
+
+// FullName is Source:Name, or Name without source
+func (a *Artifact) FullName() string {
+	if a.Source == "" {
+		return a.Name
+	}
+	return a.Source + ":" + a.Name
+}
+
+// Unlinked returns the placeholders of the libraries still to link, sorted
+func (a *Artifact) Unlinked() []string {
+	seen := make(map[string]bool)
+	var placeholders []string
+	for _, links := range []map[string][]int{a.links, a.deployedLinks} {
+		for placeholder := range links {
+			if !seen[placeholder] {
+				seen[placeholder] = true
+				placeholders = append(placeholders, placeholder)
+			}
+		}
+	}
+	sort.Strings(placeholders)
+	return placeholders
+}
+
+// Link writes the addresses of libraries into the code. Libraries are named
+// by their fully qualified name, path/Lib.sol:Lib, which both placeholder
+// styles of solc derive from
+func (a *Artifact) Link(libraries map[string]common.Address) {
+	for name, address := range libraries {
+		for _, placeholder := range []string{placeholder(name), legacyPlaceholder(name)} {
+			link(a.Bytecode, a.links, placeholder, address)
+			link(a.DeployedBytecode, a.deployedLinks, placeholder, address)
+		}
+	}
+}
+
+func link(code []byte, links map[string][]int, placeholder string, address common.Address) {
+	for _, offset := range links[placeholder] {
+		copy(code[offset:], address[:])
+	}
+	delete(links, placeholder)
+}
+
+// placeholder is that of solc 0.5 and later, a hash of the qualified name
+func placeholder(name string) string {
+	return "__$" + hex.EncodeToString(crypto.Keccak256([]byte(name)))[:34] + "$__"
+}
+
+// legacyPlaceholder is that of older solc, the name cut to 36 characters
+func legacyPlaceholder(name string) string {
+	if len(name) > 36 {
+		name = name[:36]
+	}
+	return "__" + name + strings.Repeat("_", 38-len(name))
+}
+
+// Deploy deploys the contract with the constructor args and returns the
+// contract bound to its address
+func (a *Artifact) Deploy(opts *bind.TransactOpts, backend bind.ContractBackend, args ...interface{}) (common.Address, *types.Transaction, *bind.BoundContract, error) {
+	if len(a.Bytecode) == 0 {
+		return common.Address{}, nil, nil, fmt.Errorf("%w: %s", ErrNoBytecode, a.FullName())
+	}
+	if unlinked := a.Unlinked(); len(unlinked) > 0 {
+		return common.Address{}, nil, nil, fmt.Errorf("%w: %s needs %s", ErrUnlinked, a.FullName(), strings.Join(unlinked, ", "))
+	}
+	return bind.DeployContract(opts, a.ABI, a.Bytecode, backend, args...)
+}
+
+// Bind binds the ABI to address, Call, Transact and FilterLogs then take
+// method and event names
+func (a *Artifact) Bind(address common.Address, backend bind.ContractBackend) *bind.BoundContract {
+	return bind.NewBoundContract(address, a.ABI, backend, backend, backend)
+}
+
+// Verification returns the artifact to verify deployments with. Libraries
+// must be linked first, with zeros in their place the code cannot match
+func (a *Artifact) Verification() (*verify.Artifact, error) {
+	if len(a.Bytecode) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoBytecode, a.FullName())
+	}
+	if unlinked := a.Unlinked(); len(unlinked) > 0 {
+		return nil, fmt.Errorf("%w: %s needs %s", ErrUnlinked, a.FullName(), strings.Join(unlinked, ", "))
+	}
+	return &verify.Artifact{ABI: a.ABI, Bin: a.Bytecode, Immutables: a.Immutables}, nil
+}
+
+// Find returns the artifact named name or Source:Name
+func Find(artifacts []*Artifact, name string) (*Artifact, error) {
+	var found []*Artifact
+	for _, a := range artifacts {
+		if a.FullName() == name {
+			return a, nil
+		}
+		if a.Name == name {
+			found = append(found, a)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	case 1:
+		return found[0], nil
+	}
+	return nil, fmt.Errorf("%w: %s in %d sources", ErrAmbiguous, name, len(found))
+}
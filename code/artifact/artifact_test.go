@@ -0,0 +1,226 @@
+package artifact
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	store "ethereum-development-with-go/code/contracts"
+	"ethereum-development-with-go/code/verify"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// marshal returns v as JSON
+func marshal(t *testing.T, v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestLoadFiles(t *testing.T) {
+	t.Parallel()
+	artifacts, err := Load("../contracts/Store_sol_Store.abi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := artifacts[0]
+	if a.FullName() != "Store.sol:Store" || !bytes.Equal(a.Bytecode, common.FromHex(store.StoreBin)) || a.ABI.Methods["setItem"].Name == "" {
+		t.Errorf("Expected Store.sol:Store with its bytecode, got %v and %d bytes", a.FullName(), len(a.Bytecode))
+	}
+
+	artifacts, err = Load("../contracts_0xprotocol/Exchange.sol:Exchange.abi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := artifacts[0]; a.FullName() != "Exchange.sol:Exchange" || a.Bytecode != nil || len(a.ABI.Events) == 0 {
+		t.Errorf("Expected the Exchange ABI without bytecode, got %v and %d bytes", a.FullName(), len(a.Bytecode))
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	runtime := "0x6080"
+	formats := map[string][]byte{
+		// solc 0.4 gives the ABI as a string
+		"combined": marshal(t, map[string]interface{}{
+			"contracts": map[string]interface{}{
+				"contracts/Store.sol:Store": map[string]string{"abi": store.StoreABI, "bin": store.StoreBin, "bin-runtime": runtime[2:]},
+			},
+			"version": "0.4.24+commit.e67f0147.Linux.g++",
+		}),
+		"standard": marshal(t, map[string]interface{}{
+			"contracts": map[string]interface{}{
+				"contracts/Store.sol": map[string]interface{}{
+					"Store": map[string]interface{}{
+						"abi": json.RawMessage(store.StoreABI),
+						"evm": map[string]interface{}{
+							"bytecode":         map[string]string{"object": store.StoreBin},
+							"deployedBytecode": map[string]interface{}{"object": runtime[2:], "immutableReferences": map[string]interface{}{"7": []verify.Reference{{Start: 1, Length: 32}}}},
+						},
+					},
+				},
+			},
+		}),
+		"hardhat": marshal(t, map[string]interface{}{
+			"_format":          "hh-sol-artifact-1",
+			"contractName":     "Store",
+			"sourceName":       "contracts/Store.sol",
+			"abi":              json.RawMessage(store.StoreABI),
+			"bytecode":         "0x" + store.StoreBin,
+			"deployedBytecode": runtime,
+		}),
+		"foundry": marshal(t, map[string]interface{}{
+			"abi":              json.RawMessage(store.StoreABI),
+			"bytecode":         map[string]string{"object": "0x" + store.StoreBin},
+			"deployedBytecode": map[string]string{"object": runtime},
+			"metadata":         map[string]interface{}{"settings": map[string]interface{}{"compilationTarget": map[string]string{"contracts/Store.sol": "Store"}}},
+		}),
+	}
+	for format, data := range formats {
+		artifacts, err := Parse(data)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		a := artifacts[0]
+		if len(artifacts) != 1 || a.FullName() != "contracts/Store.sol:Store" {
+			t.Errorf("Expected contracts/Store.sol:Store from %s, got %v", format, a.FullName())
+		}
+		if !bytes.Equal(a.Bytecode, common.FromHex(store.StoreBin)) || !bytes.Equal(a.DeployedBytecode, common.FromHex(runtime)) || len(a.ABI.Methods) != 3 {
+			t.Errorf("Expected the Store code and ABI from %s, got %d and %d bytes", format, len(a.Bytecode), len(a.DeployedBytecode))
+		}
+	}
+
+	artifacts, _ := Parse(formats["standard"])
+	if immutables := artifacts[0].Immutables; len(immutables) != 1 || immutables[0].Start != 1 || immutables[0].Length != 32 {
+		t.Errorf("Expected the immutable at 1, got %v", immutables)
+	}
+	// metadata and ABIs as strings may escape slashes, as JSON allows
+	metadata := bytes.ReplaceAll(marshal(t, string(marshal(t, map[string]interface{}{
+		"settings": map[string]interface{}{"compilationTarget": map[string]string{"contracts/Store.sol": "Store"}},
+	}))), []byte("/"), []byte(`\/`))
+	escaped := marshal(t, map[string]interface{}{
+		"abi":      json.RawMessage(bytes.ReplaceAll(marshal(t, store.StoreABI), []byte("/"), []byte(`\/`))),
+		"bytecode": map[string]string{"object": "0x" + store.StoreBin},
+		"metadata": json.RawMessage(metadata),
+	})
+	if artifacts, err := Parse(escaped); err != nil || artifacts[0].FullName() != "contracts/Store.sol:Store" || len(artifacts[0].ABI.Methods) != 3 {
+		t.Errorf("Expected contracts/Store.sol:Store from escaped strings, got %v %v", artifacts, err)
+	}
+	broken := marshal(t, map[string]interface{}{"abi": json.RawMessage(store.StoreABI), "bytecode": map[string]string{"object": "0x"}, "metadata": "{"})
+	if _, err := Parse(broken); err == nil {
+		t.Error("Expected an error for broken metadata")
+	}
+
+	if _, err := Parse([]byte(`{"version": "0.8.19"}`)); !errors.Is(err, ErrFormat) {
+		t.Errorf("Expected %v, got %v", ErrFormat, err)
+	}
+
+	// Foundry without metadata is named after its file
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Store.json")
+	data := marshal(t, map[string]interface{}{"abi": json.RawMessage(store.StoreABI), "bytecode": map[string]string{"object": "0x"}})
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if artifacts, err := Load(path); err != nil || artifacts[0].FullName() != "Store" || len(artifacts[0].Bytecode) != 0 {
+		t.Errorf("Expected Store, got %v %v", artifacts, err)
+	}
+}
+
+func TestLink(t *testing.T) {
+	t.Parallel()
+	math := common.HexToAddress("0xbebebebebebebebebebebebebebebebebebebebe")
+	data := marshal(t, map[string]interface{}{
+		"contracts": map[string]interface{}{
+			"Vault.sol:Vault": map[string]string{"abi": "[]", "bin": "73" + placeholder("lib/Math.sol:Math") + "00", "bin-runtime": "73" + legacyPlaceholder("lib/Math.sol:Math") + "00"},
+			"Other.sol:Vault": map[string]string{"abi": "[]"},
+		},
+	})
+	artifacts, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Find(artifacts, "Vault"); !errors.Is(err, ErrAmbiguous) {
+		t.Errorf("Expected %v, got %v", ErrAmbiguous, err)
+	}
+	if _, err := Find(artifacts, "Math"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected %v, got %v", ErrNotFound, err)
+	}
+	a, err := Find(artifacts, "Vault.sol:Vault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unlinked := a.Unlinked(); len(unlinked) != 2 {
+		t.Errorf("Expected both placeholders, got %v", unlinked)
+	}
+	if _, _, _, err := a.Deploy(&bind.TransactOpts{}, nil); !errors.Is(err, ErrUnlinked) {
+		t.Errorf("Expected %v, got %v", ErrUnlinked, err)
+	}
+	if _, err := a.Verification(); !errors.Is(err, ErrUnlinked) {
+		t.Errorf("Expected %v, got %v", ErrUnlinked, err)
+	}
+
+	a.Link(map[string]common.Address{"lib/Math.sol:Math": math})
+	expected := append(append([]byte{0x73}, math[:]...), 0x00)
+	if len(a.Unlinked()) != 0 || !bytes.Equal(a.Bytecode, expected) || !bytes.Equal(a.DeployedBytecode, expected) {
+		t.Errorf("Expected %x, got %x and %x", expected, a.Bytecode, a.DeployedBytecode)
+	}
+}
+
+func TestDeploy(t *testing.T) {
+	t.Parallel()
+	key, _ := crypto.GenerateKey()
+	auth, _ := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{
+		auth.From: {Balance: big.NewInt(1000000000000000000)},
+	}, 8000000)
+	defer backend.Close()
+
+	artifacts, err := Load("../contracts/Store_sol_Store.abi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := artifacts[0]
+	address, _, contract, err := a.Deploy(auth, backend, "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.Commit()
+	if _, err := contract.Transact(auth, "setItem", [32]byte{1}, [32]byte{2}); err != nil {
+		t.Fatal(err)
+	}
+	backend.Commit()
+
+	// bound again by address, as a contract deployed elsewhere
+	contract = a.Bind(address, backend)
+	var version, item []interface{}
+	if err := contract.Call(nil, &version, "version"); err != nil || version[0] != "1.0" {
+		t.Errorf("Expected 1.0, got %v %v", version, err)
+	}
+	if err := contract.Call(nil, &item, "items", [32]byte{1}); err != nil || item[0] != [32]byte{2} {
+		t.Errorf("Expected %x, got %v %v", [32]byte{2}, item, err)
+	}
+
+	verification, err := a.Verification()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := verify.Verify(context.Background(), backend, address, nil, verification, "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Match != verify.FullMatch {
+		t.Errorf("Expected a full match, got %v", result.Match)
+	}
+}
@@ -0,0 +1,294 @@
+package artifact
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"ethereum-development-with-go/code/verify"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// Load loads the artifacts of a file: the JSON of any format Parse takes, or
+// loose .abi and .bin files as solc -o writes them, Store_sol_Store.abi or
+// Exchange.sol:Exchange.abi next to their .bin
+func Load(path string) ([]*Artifact, error) {
+	ext := filepath.Ext(path)
+	if ext == ".abi" || ext == ".bin" {
+		a, err := loadFiles(strings.TrimSuffix(path, ext))
+		if err != nil {
+			return nil, err
+		}
+		return []*Artifact{a}, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	artifacts, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	// Foundry names its files after the contract
+	for _, a := range artifacts {
+		if a.Name == "" {
+			a.Name = strings.TrimSuffix(filepath.Base(path), ext)
+		}
+	}
+	return artifacts, nil
+}
+
+// loadFiles loads base.abi and base.bin, the latter being optional
+func loadFiles(base string) (*Artifact, error) {
+	a := &Artifact{}
+	name := filepath.Base(base)
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		a.Source, a.Name = name[:i], name[i+1:]
+	} else if i := strings.LastIndex(name, "_sol_"); i >= 0 {
+		a.Source, a.Name = name[:i]+".sol", name[i+len("_sol_"):]
+	} else {
+		a.Name = name
+	}
+
+	data, err := ioutil.ReadFile(base + ".abi")
+	if err != nil {
+		return nil, err
+	}
+	if a.ABI, err = abi.JSON(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	bin, err := ioutil.ReadFile(base + ".bin")
+	if os.IsNotExist(err) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if a.Bytecode, a.links, err = decodeCode(string(bin)); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Parse parses solc --combined-json output, solc standard JSON output,
+// Hardhat and Foundry artifacts, and bare ABIs
+func Parse(data []byte) ([]*Artifact, error) {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		parsed, err := abi.JSON(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return []*Artifact{{ABI: parsed}}, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	switch {
+	case fields["contracts"] != nil:
+		return parseSolc(fields["contracts"])
+	case fields["abi"] != nil && bytes.HasPrefix(fields["bytecode"], []byte("{")):
+		return parseFoundry(data)
+	case fields["abi"] != nil:
+		return parseHardhat(data)
+	}
+	return nil, ErrFormat
+}
+
+// combinedContract is a contract of solc --combined-json abi,bin,bin-runtime
+type combinedContract struct {
+	ABI        json.RawMessage `json:"abi"`
+	Bin        string          `json:"bin"`
+	BinRuntime string          `json:"bin-runtime"`
+}
+
+// standardContract is a contract of the solc standard JSON output, Foundry
+// artifacts share its bytecode objects
+type standardContract struct {
+	ABI json.RawMessage `json:"abi"`
+	EVM struct {
+		Bytecode         bytecodeObject `json:"bytecode"`
+		DeployedBytecode bytecodeObject `json:"deployedBytecode"`
+	} `json:"evm"`
+}
+
+type bytecodeObject struct {
+	Object              string                        `json:"object"`
+	ImmutableReferences map[string][]verify.Reference `json:"immutableReferences"`
+}
+
+// parseSolc parses the contracts of combined or standard JSON, the former
+// keyed by Source:Name, the latter by source then name
+func parseSolc(contracts json.RawMessage) ([]*Artifact, error) {
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(contracts, &entries); err != nil {
+		return nil, err
+	}
+
+	var artifacts []*Artifact
+	for key, entry := range entries {
+		if i := strings.LastIndex(key, ":"); i >= 0 {
+			var c combinedContract
+			if err := json.Unmarshal(entry, &c); err != nil {
+				return nil, err
+			}
+			a, err := newArtifact(key[:i], key[i+1:], c.ABI, bytecodeObject{Object: c.Bin}, bytecodeObject{Object: c.BinRuntime})
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			artifacts = append(artifacts, a)
+			continue
+		}
+
+		var named map[string]standardContract
+		if err := json.Unmarshal(entry, &named); err != nil {
+			return nil, err
+		}
+		for name, c := range named {
+			a, err := newArtifact(key, name, c.ABI, c.EVM.Bytecode, c.EVM.DeployedBytecode)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%s: %w", key, name, err)
+			}
+			artifacts = append(artifacts, a)
+		}
+	}
+	sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].FullName() < artifacts[j].FullName() })
+	return artifacts, nil
+}
+
+// hardhatArtifact is a Hardhat or Truffle artifact
+type hardhatArtifact struct {
+	ContractName     string          `json:"contractName"`
+	SourceName       string          `json:"sourceName"`
+	ABI              json.RawMessage `json:"abi"`
+	Bytecode         string          `json:"bytecode"`
+	DeployedBytecode string          `json:"deployedBytecode"`
+}
+
+func parseHardhat(data []byte) ([]*Artifact, error) {
+	var h hardhatArtifact
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, err
+	}
+	a, err := newArtifact(h.SourceName, h.ContractName, h.ABI, bytecodeObject{Object: h.Bytecode}, bytecodeObject{Object: h.DeployedBytecode})
+	if err != nil {
+		return nil, err
+	}
+	return []*Artifact{a}, nil
+}
+
+// foundryArtifact is a file of the Foundry out/ directory
+type foundryArtifact struct {
+	ABI              json.RawMessage `json:"abi"`
+	Bytecode         bytecodeObject  `json:"bytecode"`
+	DeployedBytecode bytecodeObject  `json:"deployedBytecode"`
+	// Metadata is the solc metadata, as an object or a string
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+func parseFoundry(data []byte) ([]*Artifact, error) {
+	var f foundryArtifact
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	var metadata struct {
+		Settings struct {
+			CompilationTarget map[string]string `json:"compilationTarget"`
+		} `json:"settings"`
+	}
+	raw, err := unquote(f.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: %w", err)
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &metadata); err != nil {
+			return nil, fmt.Errorf("metadata: %w", err)
+		}
+	}
+
+	// the compilation target is the one contract of the file, else Load
+	// takes the file name
+	var source, name string
+	for s, n := range metadata.Settings.CompilationTarget {
+		source, name = s, n
+	}
+	a, err := newArtifact(source, name, f.ABI, f.Bytecode, f.DeployedBytecode)
+	if err != nil {
+		return nil, err
+	}
+	return []*Artifact{a}, nil
+}
+
+// newArtifact builds an artifact from the parts all formats have
+func newArtifact(source, name string, rawABI json.RawMessage, bytecode, deployed bytecodeObject) (*Artifact, error) {
+	a := &Artifact{Name: name, Source: source}
+	// solc before 0.8 gives the ABI of --combined-json as a string
+	rawABI, err := unquote(rawABI)
+	if err != nil {
+		return nil, err
+	}
+	if a.ABI, err = abi.JSON(bytes.NewReader(rawABI)); err != nil {
+		return nil, err
+	}
+	if a.Bytecode, a.links, err = decodeCode(bytecode.Object); err != nil {
+		return nil, err
+	}
+	if a.DeployedBytecode, a.deployedLinks, err = decodeCode(deployed.Object); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(deployed.ImmutableReferences))
+	for id := range deployed.ImmutableReferences {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		a.Immutables = append(a.Immutables, deployed.ImmutableReferences[id]...)
+	}
+	return a, nil
+}
+
+// unquote returns the JSON a JSON string holds, other values as they are
+func unquote(raw json.RawMessage) (json.RawMessage, error) {
+	if !bytes.HasPrefix(raw, []byte(`"`)) {
+		return raw, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, err
+	}
+	return json.RawMessage(s), nil
+}
+
+// decodeCode decodes hex code, with or without 0x, and returns the offsets
+// of the library placeholders it replaces with zeros
+func decodeCode(s string) ([]byte, map[string][]int, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "0x")
+	links := make(map[string][]int)
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		// placeholders start on a byte and take 20 bytes
+		if i%2 == 0 && strings.HasPrefix(s[i:], "__") && i+40 <= len(s) {
+			links[s[i:i+40]] = append(links[s[i:i+40]], i/2)
+			b.WriteString(strings.Repeat("0", 40))
+			i += 40
+			continue
+		}
+		b.WriteByte(s[i])
+		i++
+	}
+	code, err := hex.DecodeString(b.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	return code, links, nil
+}
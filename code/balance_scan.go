@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"os/signal"
+	"strings"
+
+	"ethereum-development-with-go/code/hdwallet"
+	"ethereum-development-with-go/code/scanner"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// go run balance_scan.go -tokens ETH,0xdAC17F958D2ee523a2206206994597C13D831ec7 -addresses reval.txt -out balances.csv
+// go run balance_scan.go -tokens ETH -mnemonic "test test ... junk" -count 100 -format json
+// cat addresses.txt | go run balance_scan.go -tokens 0xdAC17F958D2ee523a2206206994597C13D831ec7:USDT:6 -addresses -
+func main() {
+	rpcURL := flag.String("rpc", "https://mainnet.infura.io/v3/**********", "node endpoint")
+	tokenList := flag.String("tokens", "ETH", "comma separated ETH, 0x<address> or 0x<address>:<symbol>:<decimals>")
+	addresses := flag.String("addresses", "", "file with one address or index#address per line, - for stdin")
+	mnemonic := flag.String("mnemonic", "", "derive addresses from this mnemonic instead of a file")
+	path := flag.String("path", "m/44'/60'/0'/0/0", "first derivation path")
+	count := flag.Uint64("count", 20, "number of derived addresses")
+	workers := flag.Int("workers", scanner.DefaultWorkers, "concurrent addresses")
+	rps := flag.Float64("rps", 20, "requests per second, 0 is unlimited")
+	block := flag.Int64("block", -1, "block number, latest when negative")
+	format := flag.String("format", "csv", "csv or json")
+	out := flag.String("out", "", "output file, stdout when empty; resumed after the checkpoint")
+	checkpoint := flag.String("checkpoint", "", "checkpoint file, defaults to <out>.checkpoint")
+	flag.Parse()
+
+	ctx, stop := context.WithCancel(context.Background())
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		log.Println("interrupted, saving checkpoint")
+		stop()
+	}()
+
+	client, err := ethclient.Dial(*rpcURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var tokens []scanner.Token
+	for _, spec := range strings.Split(*tokenList, ",") {
+		t, err := scanner.ParseToken(spec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := scanner.ResolveToken(ctx, client, &t); err != nil {
+			log.Fatal(err)
+		}
+		tokens = append(tokens, t)
+	}
+
+	var src scanner.Source
+	switch {
+	case *mnemonic != "":
+		wallet, err := hdwallet.NewFromMnemonic(*mnemonic, os.Getenv("MNEMONIC_PASSPHRASE"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		base, err := accounts.ParseDerivationPath(*path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		src = scanner.NewHDSource(wallet, base, *count)
+	case *addresses == "-":
+		src = scanner.NewLineSource(os.Stdin)
+	case *addresses != "":
+		file, err := os.Open(*addresses)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		src = scanner.NewLineSource(file)
+	default:
+		log.Fatal("either -addresses or -mnemonic is required")
+	}
+
+	var w io.Writer = os.Stdout
+	var output *os.File
+	header := true
+	if *out != "" {
+		if *checkpoint == "" {
+			*checkpoint = *out + ".checkpoint"
+		}
+		if _, err := os.Stat(*checkpoint); err == nil {
+			header = false
+		}
+		file, err := os.OpenFile(*out, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		w, output = file, file
+	}
+
+	var writer scanner.Writer = scanner.NewCSVWriter(w, header)
+	if *format == "json" {
+		writer = scanner.NewJSONWriter(w)
+	}
+
+	cfg := scanner.Config{
+		Tokens:            tokens,
+		Workers:           *workers,
+		RequestsPerSecond: *rps,
+		Checkpoint:        *checkpoint,
+		// 恢复时截掉检查点之后写出的行，避免重复
+		Output: output,
+		OnProgress: func(done uint64) {
+			if done%1000 == 0 {
+				log.Println("scanned", done)
+			}
+		},
+	}
+	if *block >= 0 {
+		cfg.BlockNumber = big.NewInt(*block)
+	}
+
+	s, err := scanner.New(client, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := s.Run(ctx, src, writer); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintln(os.Stderr, "done")
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"ethereum-development-with-go/code/follower"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+func main() {
+	client, err := ethclient.Dial("wss://rinkeby.infura.io/ws/v3/**********")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// blocks with 12 confirmations are reported final and may no longer be reverted
+	f := follower.New(client, follower.Config{FinalityDepth: 12})
+
+	updates := make(chan *follower.Update)
+	go func() {
+		log.Fatal(f.Run(context.Background(), updates))
+	}()
+
+	for update := range updates {
+		if update.Reorg() {
+			fmt.Println("reorg at", update.Ancestor.Number, update.Ancestor.Hash().Hex()) // reorg at 3477410 0x...
+		}
+		for _, header := range update.Reverted {
+			fmt.Println("reverted", header.Number, header.Hash().Hex())
+		}
+		for _, header := range update.Added {
+			fmt.Println("added", header.Number, header.Hash().Hex()) // added 3477413 0xbc10defa8dda384c96a17640d84de5578804945d347072e091b4e5f390ddea7f
+		}
+		for _, header := range update.Finalized {
+			fmt.Println("final", header.Number, header.Hash().Hex())
+		}
+	}
+}
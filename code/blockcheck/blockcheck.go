@@ -0,0 +1,299 @@
+package blockcheck
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync/atomic"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+/*
+ * Verify that the transactions and receipts a node returns for a block are
+ * the ones its header commits to, and that the header hashes to the block hash.
+ */
+
+var (
+	// ErrMismatch is returned by Report.Err when any component disagrees
+	ErrMismatch = errors.New("blockcheck: block does not match its header")
+	// ErrUnsupported is returned for transaction types this go-ethereum
+	// version cannot encode, their roots cannot be recomputed
+	ErrUnsupported = errors.New("blockcheck: unsupported transaction type")
+)
+
+// batchSize is the number of receipts requested per batch
+const batchSize = 100
+
+// Components of a block that are checked against the header
+const (
+	TransactionsRoot = "transactionsRoot"
+	ReceiptsRoot     = "receiptsRoot"
+	LogsBloom        = "logsBloom"
+	BlockHash        = "hash"
+	Receipts         = "receipts"
+)
+
+// Caller sends raw JSON-RPC requests, *rpc.Client implements it
+type Caller interface {
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+	BatchCallContext(ctx context.Context, b []rpc.BatchElem) error
+}
+
+// Mismatch is a component whose recomputed value differs from the header
+type Mismatch struct {
+	Component string
+	Header    string
+	Computed  string
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s: header %s, computed %s", m.Component, m.Header, m.Computed)
+}
+
+// Report is the outcome of verifying a block
+type Report struct {
+	Number       uint64
+	Hash         common.Hash
+	Transactions int
+	Receipts     int
+	Mismatches   []Mismatch
+}
+
+// OK reports whether every component matched
+func (r *Report) OK() bool {
+	return len(r.Mismatches) == 0
+}
+
+// Err returns an ErrMismatch naming the failed components, nil when OK
+func (r *Report) Err() error {
+	if r.OK() {
+		return nil
+	}
+	failed := make([]string, len(r.Mismatches))
+	for i, m := range r.Mismatches {
+		failed[i] = m.String()
+	}
+	return fmt.Errorf("%w: block %d: %s", ErrMismatch, r.Number, strings.Join(failed, "; "))
+}
+
+func (r *Report) check(component string, header, computed fmt.Stringer) {
+	if header.String() != computed.String() {
+		r.Mismatches = append(r.Mismatches, Mismatch{Component: component, Header: header.String(), Computed: computed.String()})
+	}
+}
+
+// Block is a block as the node returned it
+type Block struct {
+	Header       *types.Header
+	Hash         common.Hash
+	Transactions types.Transactions
+	// fields newer than the vendored header, they are part of the hash
+	extra []interface{}
+}
+
+// rpcBlock holds the fields of eth_getBlockByNumber beside the header
+type rpcBlock struct {
+	Hash                  common.Hash          `json:"hash"`
+	Transactions          []*types.Transaction `json:"transactions"`
+	WithdrawalsRoot       *common.Hash         `json:"withdrawalsRoot"`
+	BlobGasUsed           *hexutil.Uint64      `json:"blobGasUsed"`
+	ExcessBlobGas         *hexutil.Uint64      `json:"excessBlobGas"`
+	ParentBeaconBlockRoot *common.Hash         `json:"parentBeaconBlockRoot"`
+	RequestsHash          *common.Hash         `json:"requestsHash"`
+}
+
+// ComputeHash hashes the header RLP, including fields added by forks after
+// the vendored go-ethereum
+func (b *Block) ComputeHash() (common.Hash, error) {
+	h := b.Header
+	fields := []interface{}{
+		h.ParentHash, h.UncleHash, h.Coinbase, h.Root, h.TxHash, h.ReceiptHash, h.Bloom,
+		h.Difficulty, h.Number, h.GasLimit, h.GasUsed, h.Time, h.Extra, h.MixDigest, h.Nonce,
+	}
+	if h.BaseFee != nil {
+		fields = append(fields, h.BaseFee)
+	}
+	enc, err := rlp.EncodeToBytes(append(fields, b.extra...))
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(enc), nil
+}
+
+// Verifier checks blocks served by a node
+type Verifier struct {
+	client Caller
+	// noBlockReceipts is set once the node rejected eth_getBlockReceipts
+	noBlockReceipts int32
+}
+
+// New returns a verifier for the blocks of client
+func New(client Caller) *Verifier {
+	return &Verifier{client: client}
+}
+
+// Block fetches a block with its transactions, nil number is the latest
+func (v *Verifier) Block(ctx context.Context, number *big.Int) (*Block, error) {
+	tag := "latest"
+	if number != nil {
+		tag = hexutil.EncodeBig(number)
+	}
+
+	var raw json.RawMessage
+	if err := v.client.CallContext(ctx, &raw, "eth_getBlockByNumber", tag, true); err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, ethereum.NotFound
+	}
+
+	var header types.Header
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return nil, fmt.Errorf("blockcheck: header: %v", err)
+	}
+	var body rpcBlock
+	if err := json.Unmarshal(raw, &body); err != nil {
+		if errors.Is(err, types.ErrTxTypeNotSupported) {
+			return nil, fmt.Errorf("%w: block %v: %v", ErrUnsupported, header.Number, err)
+		}
+		return nil, fmt.Errorf("blockcheck: block %v: %v", header.Number, err)
+	}
+
+	block := &Block{Header: &header, Hash: body.Hash, Transactions: body.Transactions}
+	// each optional field requires the ones before it
+	for _, field := range []interface{}{body.WithdrawalsRoot, body.BlobGasUsed, body.ExcessBlobGas, body.ParentBeaconBlockRoot, body.RequestsHash} {
+		switch value := field.(type) {
+		case *common.Hash:
+			if value == nil {
+				return block, nil
+			}
+			block.extra = append(block.extra, *value)
+		case *hexutil.Uint64:
+			if value == nil {
+				return block, nil
+			}
+			block.extra = append(block.extra, uint64(*value))
+		}
+	}
+	return block, nil
+}
+
+// Receipts fetches the receipts of block with eth_getBlockReceipts, or with
+// batched eth_getTransactionReceipt calls when the node lacks it
+func (v *Verifier) Receipts(ctx context.Context, block *Block) (types.Receipts, error) {
+	if len(block.Transactions) == 0 {
+		return types.Receipts{}, nil
+	}
+
+	if atomic.LoadInt32(&v.noBlockReceipts) == 0 {
+		var receipts types.Receipts
+		err := v.client.CallContext(ctx, &receipts, "eth_getBlockReceipts", hexutil.EncodeBig(block.Header.Number))
+		if err == nil {
+			return receipts, nil
+		}
+		var rpcErr rpc.Error
+		if !errors.As(err, &rpcErr) || rpcErr.ErrorCode() != -32601 {
+			return nil, err
+		}
+		atomic.StoreInt32(&v.noBlockReceipts, 1)
+	}
+
+	receipts := make(types.Receipts, len(block.Transactions))
+	for start := 0; start < len(receipts); start += batchSize {
+		end := start + batchSize
+		if end > len(receipts) {
+			end = len(receipts)
+		}
+		batch := make([]rpc.BatchElem, end-start)
+		for i := range batch {
+			batch[i] = rpc.BatchElem{
+				Method: "eth_getTransactionReceipt",
+				Args:   []interface{}{block.Transactions[start+i].Hash()},
+				Result: &receipts[start+i],
+			}
+		}
+		if err := v.client.BatchCallContext(ctx, batch); err != nil {
+			return nil, err
+		}
+		for i, elem := range batch {
+			hash := block.Transactions[start+i].Hash().Hex()
+			if elem.Error != nil {
+				return nil, fmt.Errorf("blockcheck: receipt of %s: %v", hash, elem.Error)
+			}
+			if receipts[start+i] == nil {
+				return nil, fmt.Errorf("blockcheck: receipt of %s: %w", hash, ethereum.NotFound)
+			}
+		}
+	}
+	return receipts, nil
+}
+
+// Verify fetches block number with its receipts and checks them against the
+// header, nil number is the latest. Disagreeing components are reported, not
+// returned as errors, use Report.Err for that
+func (v *Verifier) Verify(ctx context.Context, number *big.Int) (*Report, error) {
+	block, err := v.Block(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	receipts, err := v.Receipts(ctx, block)
+	if err != nil {
+		return nil, err
+	}
+	return Check(block, receipts)
+}
+
+// Check verifies a fetched block and its receipts in block order
+func Check(block *Block, receipts types.Receipts) (*Report, error) {
+	for _, receipt := range receipts {
+		if receipt.Type > types.DynamicFeeTxType {
+			return nil, fmt.Errorf("%w: receipt of type %d", ErrUnsupported, receipt.Type)
+		}
+	}
+
+	header := block.Header
+	report := &Report{
+		Number:       header.Number.Uint64(),
+		Hash:         block.Hash,
+		Transactions: len(block.Transactions),
+		Receipts:     len(receipts),
+	}
+
+	hasher := trie.NewStackTrie(nil)
+	report.check(TransactionsRoot, header.TxHash, types.DeriveSha(block.Transactions, hasher))
+
+	// a receipt missing or out of place breaks the receipts root anyway, this
+	// says why
+	for i, receipt := range receipts {
+		if i >= len(block.Transactions) || receipt.TxHash != block.Transactions[i].Hash() {
+			report.Mismatches = append(report.Mismatches, Mismatch{Component: Receipts, Header: "in transaction order", Computed: fmt.Sprintf("receipt %d of %s", i, receipt.TxHash.Hex())})
+			break
+		}
+	}
+	if len(receipts) != len(block.Transactions) {
+		report.Mismatches = append(report.Mismatches, Mismatch{Component: Receipts, Header: fmt.Sprint(len(block.Transactions)), Computed: fmt.Sprint(len(receipts))})
+	}
+
+	hasher.Reset()
+	report.check(ReceiptsRoot, header.ReceiptHash, types.DeriveSha(receipts, hasher))
+	report.check(LogsBloom, hexutil.Bytes(header.Bloom.Bytes()), hexutil.Bytes(types.CreateBloom(receipts).Bytes()))
+
+	hash, err := block.ComputeHash()
+	if err != nil {
+		return nil, err
+	}
+	report.check(BlockHash, block.Hash, hash)
+
+	return report, nil
+}
@@ -0,0 +1,217 @@
+package blockcheck
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	store "ethereum-development-with-go/code/contracts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// fakeEth serves a block of a simulated chain, the tamper hooks let a test
+// play a lying node
+type fakeEth struct {
+	block         *types.Block
+	receipts      types.Receipts
+	tamperBlock   func(map[string]interface{})
+	tamperReceipt func(types.Receipts)
+}
+
+func (f *fakeEth) GetBlockByNumber(number string, full bool) (map[string]interface{}, error) {
+	data, err := json.Marshal(f.block.Header())
+	if err != nil {
+		return nil, err
+	}
+	var block map[string]interface{}
+	if err := json.Unmarshal(data, &block); err != nil {
+		return nil, err
+	}
+	block["transactions"] = f.block.Transactions()
+	block["uncles"] = []common.Hash{}
+	if f.tamperBlock != nil {
+		f.tamperBlock(block)
+	}
+	return block, nil
+}
+
+func (f *fakeEth) GetTransactionReceipt(hash common.Hash) (*types.Receipt, error) {
+	for _, receipt := range f.copyReceipts() {
+		if receipt.TxHash == hash {
+			return receipt, nil
+		}
+	}
+	return nil, nil
+}
+
+// copyReceipts returns tampered copies, the chain's own receipts stay intact
+func (f *fakeEth) copyReceipts() types.Receipts {
+	data, _ := json.Marshal(f.receipts)
+	var receipts types.Receipts
+	json.Unmarshal(data, &receipts)
+	if f.tamperReceipt != nil {
+		f.tamperReceipt(receipts)
+	}
+	return receipts
+}
+
+// blockReceipts is a node that also has eth_getBlockReceipts
+type blockReceipts struct {
+	*fakeEth
+	calls int
+}
+
+func (b *blockReceipts) GetBlockReceipts(number string) (types.Receipts, error) {
+	b.calls++
+	return b.copyReceipts(), nil
+}
+
+func newChain(t *testing.T) *fakeEth {
+	key, _ := crypto.GenerateKey()
+	auth, _ := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{
+		auth.From: {Balance: big.NewInt(1000000000000000000)},
+	}, 8000000)
+	defer backend.Close()
+
+	// a block with two transactions, one of them logging
+	_, _, instance, err := store.DeployStore(auth, backend, "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := instance.SetItem(auth, [32]byte{1}, [32]byte{2}); err != nil {
+		t.Fatal(err)
+	}
+	backend.Commit()
+
+	chain := backend.Blockchain()
+	block := chain.CurrentBlock()
+	if len(block.Transactions()) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(block.Transactions()))
+	}
+	return &fakeEth{block: block, receipts: chain.GetReceiptsByHash(block.Hash())}
+}
+
+func dial(t *testing.T, service interface{}) *rpc.Client {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", service); err != nil {
+		t.Fatal(err)
+	}
+	return rpc.DialInProc(server)
+}
+
+func components(report *Report) map[string]bool {
+	failed := make(map[string]bool)
+	for _, m := range report.Mismatches {
+		failed[m.Component] = true
+	}
+	return failed
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+	eth := newChain(t)
+	service := &blockReceipts{fakeEth: eth}
+	client := dial(t, service)
+	defer client.Close()
+	verifier := New(client)
+
+	for name, c := range map[string]struct {
+		tamperBlock   func(map[string]interface{})
+		tamperReceipt func(types.Receipts)
+		expected      []string
+	}{
+		"honest": {},
+		"transaction": {
+			tamperBlock: func(b map[string]interface{}) { b["transactions"] = eth.block.Transactions()[1:] },
+			expected:    []string{TransactionsRoot, Receipts},
+		},
+		"log data": {
+			tamperReceipt: func(r types.Receipts) { r[1].Logs[0].Data[31] ^= 1 },
+			expected:      []string{ReceiptsRoot},
+		},
+		"log address": {
+			tamperReceipt: func(r types.Receipts) { r[1].Logs[0].Address = common.Address{1} },
+			expected:      []string{ReceiptsRoot, LogsBloom},
+		},
+		"receipt order": {
+			tamperReceipt: func(r types.Receipts) { r[0], r[1] = r[1], r[0] },
+			expected:      []string{ReceiptsRoot, Receipts},
+		},
+		"header": {
+			tamperBlock: func(b map[string]interface{}) { b["gasUsed"] = hexutil.Uint64(1) },
+			expected:    []string{BlockHash},
+		},
+	} {
+		eth.tamperBlock, eth.tamperReceipt = c.tamperBlock, c.tamperReceipt
+		report, err := verifier.Verify(context.Background(), eth.block.Number())
+		if err != nil {
+			t.Fatal(err)
+		}
+		failed := components(report)
+		if len(failed) != len(c.expected) {
+			t.Errorf("Expected %v to fail %v, got %v", name, c.expected, report.Mismatches)
+		}
+		for _, component := range c.expected {
+			if !failed[component] {
+				t.Errorf("Expected %v to fail %v, got %v", name, component, report.Mismatches)
+			}
+		}
+		if err := report.Err(); (err == nil) != (len(c.expected) == 0) || (err != nil && !errors.Is(err, ErrMismatch)) {
+			t.Errorf("Expected %v to return %v, got %v", name, ErrMismatch, err)
+		}
+	}
+
+	if service.calls == 0 || verifier.noBlockReceipts != 0 {
+		t.Errorf("Expected eth_getBlockReceipts to be used")
+	}
+}
+
+func TestBatchFallback(t *testing.T) {
+	t.Parallel()
+	eth := newChain(t)
+	client := dial(t, eth)
+	defer client.Close()
+	verifier := New(client)
+
+	report, err := verifier.Verify(context.Background(), eth.block.Number())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || report.Receipts != 2 || report.Hash != eth.block.Hash() {
+		t.Errorf("Expected block %v to verify, got %v", eth.block.Hash().Hex(), report.Mismatches)
+	}
+	if verifier.noBlockReceipts != 1 {
+		t.Errorf("Expected eth_getBlockReceipts to be marked unsupported")
+	}
+
+	// a receipt the node does not have is an error, not a zero receipt
+	eth.tamperReceipt = func(r types.Receipts) { r[1].TxHash = common.Hash{} }
+	if _, err := verifier.Verify(context.Background(), eth.block.Number()); err == nil {
+		t.Errorf("Expected an error for a missing receipt")
+	}
+}
+
+func TestComputeHash(t *testing.T) {
+	t.Parallel()
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(0), BaseFee: big.NewInt(7)}
+	block := &Block{Header: header}
+	if hash, _ := block.ComputeHash(); hash != header.Hash() {
+		t.Errorf("Expected %v, got %v", header.Hash().Hex(), hash.Hex())
+	}
+
+	// a withdrawals root is appended to the fields go-ethereum knows
+	block.extra = []interface{}{types.EmptyRootHash}
+	if hash, _ := block.ComputeHash(); hash == header.Hash() {
+		t.Errorf("Expected the withdrawals root to change the hash")
+	}
+}
@@ -0,0 +1,226 @@
+package bytecode
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+/*
+ * Disassemble and analyze EVM bytecode: split creation code into constructor,
+ * runtime and metadata, and find what the runtime can do.
+ */
+
+// ErrNoRuntime is returned for creation code without a CODECOPY of its runtime
+var ErrNoRuntime = errors.New("bytecode: no runtime copy found")
+
+// opcodes added by forks after the vendored go-ethereum
+var newer = map[vm.OpCode]string{
+	0x49: "BLOBHASH",
+	0x4a: "BLOBBASEFEE",
+	0x5c: "TLOAD",
+	0x5d: "TSTORE",
+	0x5e: "MCOPY",
+	0x5f: "PUSH0",
+}
+
+// Newer reports whether op came with a fork after the vendored go-ethereum,
+// whose EVM treats it as invalid
+func Newer(op vm.OpCode) bool {
+	_, ok := newer[op]
+	return ok
+}
+
+// PUSH0 pushes zero, solc emits it from Shanghai on
+const PUSH0 vm.OpCode = 0x5f
+
+// Instruction is a decoded opcode and its immediate data
+type Instruction struct {
+	PC uint64
+	Op vm.OpCode
+	// Arg is the data of a PUSH, shorter than the PUSH size when truncated
+	Arg []byte
+}
+
+// Name returns the mnemonic, INVALID(0x..) for undefined opcodes
+func (i Instruction) Name() string {
+	if name, ok := newer[i.Op]; ok {
+		return name
+	}
+	if name := i.Op.String(); vm.StringToOp(name) == i.Op {
+		return name
+	}
+	return fmt.Sprintf("INVALID(%#02x)", byte(i.Op))
+}
+
+func (i Instruction) String() string {
+	if i.Op.IsPush() {
+		return fmt.Sprintf("0x%04x %s %s", i.PC, i.Name(), hexutil.Encode(i.Arg))
+	}
+	return fmt.Sprintf("0x%04x %s", i.PC, i.Name())
+}
+
+// Value returns the pushed value, nil for other instructions
+func (i Instruction) Value() *big.Int {
+	switch {
+	case i.Op == PUSH0:
+		return new(big.Int)
+	case i.Op.IsPush():
+		return new(big.Int).SetBytes(i.Arg)
+	}
+	return nil
+}
+
+// Disassemble decodes code into instructions
+func Disassemble(code []byte) []Instruction {
+	var instructions []Instruction
+	for pc := 0; pc < len(code); pc++ {
+		op := vm.OpCode(code[pc])
+		instruction := Instruction{PC: uint64(pc), Op: op}
+		if op.IsPush() {
+			size := int(op-vm.PUSH1) + 1
+			end := pc + 1 + size
+			if end > len(code) {
+				end = len(code)
+			}
+			instruction.Arg = code[pc+1 : end]
+			pc += size
+		}
+		instructions = append(instructions, instruction)
+	}
+	return instructions
+}
+
+// Analysis is the structure and features of some bytecode
+type Analysis struct {
+	// Constructor is set by AnalyzeCreation
+	Constructor []byte
+	// Runtime is the deployed code without its metadata
+	Runtime []byte
+	// Args follow the creation code when taken from a deployment
+	Args     []byte
+	Metadata *Metadata
+	// Instructions are those of Runtime
+	Instructions []Instruction
+	// Selectors are the function selectors the dispatcher compares against
+	Selectors [][4]byte
+	// Flags maps SELFDESTRUCT, DELEGATECALL and CREATE2 to where they occur
+	Flags map[string][]uint64
+}
+
+// flagged are the opcodes worth a look before trusting a contract
+var flagged = []vm.OpCode{vm.SELFDESTRUCT, vm.DELEGATECALL, vm.CREATE2}
+
+// AnalyzeRuntime analyzes deployed code, as CodeAt returns it. The code is
+// never split, a CODECOPY in it, such as a factory copying the creation code
+// of its child, says nothing about the code itself
+func AnalyzeRuntime(code []byte) *Analysis {
+	return analyze(&Analysis{Runtime: code})
+}
+
+// AnalyzeCreation analyzes creation code, possibly followed by constructor
+// arguments, split at the CODECOPY of the runtime in its constructor
+func AnalyzeCreation(code []byte) (*Analysis, error) {
+	offset, size, ok := runtimeCopy(code)
+	if !ok {
+		return nil, fmt.Errorf("%w in %d bytes", ErrNoRuntime, len(code))
+	}
+	return analyze(&Analysis{
+		Constructor: code[:offset],
+		Runtime:     code[offset : offset+size],
+		Args:        code[offset+size:],
+	}), nil
+}
+
+// analyze strips the metadata of a.Runtime and analyzes the rest
+func analyze(a *Analysis) *Analysis {
+	a.Flags = make(map[string][]uint64)
+	if metadata, n := ParseMetadata(a.Runtime); metadata != nil {
+		a.Metadata = metadata
+		a.Runtime = a.Runtime[:len(a.Runtime)-n]
+	}
+
+	a.Instructions = Disassemble(a.Runtime)
+	a.Selectors = selectors(a.Instructions)
+	for _, instruction := range a.Instructions {
+		for _, op := range flagged {
+			if instruction.Op == op {
+				a.Flags[op.String()] = append(a.Flags[op.String()], instruction.PC)
+			}
+		}
+	}
+	return a
+}
+
+// runtimeCopy finds the CODECOPY that copies the runtime out of creation
+// code. Only PUSH, PUSH0, DUP and SWAP are followed, any other instruction
+// forgets the stack, which is enough for the straight-line code solc and
+// vyper emit around it
+func runtimeCopy(code []byte) (int, int, bool) {
+	var stack []*big.Int
+	peek := func(n int) *big.Int {
+		if n >= len(stack) {
+			return nil
+		}
+		return stack[len(stack)-1-n]
+	}
+
+	for _, instruction := range Disassemble(code) {
+		op := instruction.Op
+		switch {
+		case op.IsPush() || op == PUSH0:
+			stack = append(stack, instruction.Value())
+		case op >= vm.DUP1 && op <= vm.DUP16:
+			stack = append(stack, peek(int(op-vm.DUP1)))
+		case op >= vm.SWAP1 && op <= vm.SWAP16:
+			n := int(op-vm.SWAP1) + 1
+			if n < len(stack) {
+				top := len(stack) - 1
+				stack[top], stack[top-n] = stack[top-n], stack[top]
+			} else {
+				stack = nil
+			}
+		case op == vm.CODECOPY:
+			// the runtime lies after the constructor copying it
+			offset, size := peek(1), peek(2)
+			if offset != nil && size != nil && size.Sign() > 0 && offset.IsUint64() && size.IsUint64() &&
+				offset.Uint64() > instruction.PC && offset.Uint64()+size.Uint64() <= uint64(len(code)) {
+				return int(offset.Uint64()), int(size.Uint64()), true
+			}
+			stack = nil
+		default:
+			stack = nil
+		}
+	}
+	return 0, 0, false
+}
+
+// selectors collects the PUSH4 values compared with EQ before a JUMPI, the
+// pattern of the solc and vyper dispatchers
+func selectors(instructions []Instruction) [][4]byte {
+	var found [][4]byte
+	seen := make(map[[4]byte]bool)
+	for i, instruction := range instructions {
+		if instruction.Op != vm.PUSH4 || len(instruction.Arg) != 4 || i+3 >= len(instructions) {
+			continue
+		}
+		next := instructions[i+1 : i+4]
+		// PUSH4 EQ PUSH JUMPI, or PUSH4 DUP2 EQ PUSH JUMPI
+		if next[0].Op == vm.DUP2 && i+4 < len(instructions) {
+			next = instructions[i+2 : i+5]
+		}
+		if next[0].Op != vm.EQ || !next[1].Op.IsPush() || next[2].Op != vm.JUMPI {
+			continue
+		}
+		var selector [4]byte
+		copy(selector[:], instruction.Arg)
+		if !seen[selector] {
+			seen[selector] = true
+			found = append(found, selector)
+		}
+	}
+	return found
+}
@@ -0,0 +1,118 @@
+package bytecode
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	store "ethereum-development-with-go/code/contracts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+func TestDisassemble(t *testing.T) {
+	t.Parallel()
+	instructions := Disassemble(common.FromHex("0x60806040525f0c6101"))
+	expected := []string{"0x0000 PUSH1 0x80", "0x0002 PUSH1 0x40", "0x0004 MSTORE", "0x0005 PUSH0", "0x0006 INVALID(0x0c)", "0x0007 PUSH2 0x01"}
+	if len(instructions) != len(expected) {
+		t.Fatalf("Expected %d instructions, got %v", len(expected), instructions)
+	}
+	for i, instruction := range instructions {
+		if instruction.String() != expected[i] {
+			t.Errorf("Expected %v, got %v", expected[i], instruction)
+		}
+	}
+}
+
+func TestAnalyzeStore(t *testing.T) {
+	t.Parallel()
+	parsed, _ := abi.JSON(strings.NewReader(store.StoreABI))
+	args, _ := parsed.Pack("", "1.0")
+	creation := append(common.FromHex(store.StoreBin), args...)
+
+	a, err := AnalyzeCreation(creation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Constructor) != 0x104 || !bytes.Equal(a.Args, args) {
+		t.Errorf("Expected a constructor of %d bytes and the args, got %d and %x", 0x104, len(a.Constructor), a.Args)
+	}
+	// the deployed code is the runtime with its metadata
+	if runtime := append(a.Runtime, a.Metadata.Raw...); !bytes.Equal(runtime, creation[0x104:0x104+0x2d1]) {
+		t.Errorf("Expected the runtime copied by the constructor")
+	}
+	if a.Metadata.Swarm != "bzzr0:17c3e13a732c8ac8bf1a24a092160e1e21e4541a2b209b6a3b0867df4a0e6c21" || a.Metadata.Solc != "" {
+		t.Errorf("Expected the swarm hash of a 0.4 build, got %+v", a.Metadata)
+	}
+
+	expected := []string{"0x48f343f3", "0x54fd4d50", "0xf56256c7"}
+	if len(a.Selectors) != len(expected) {
+		t.Fatalf("Expected %v, got %x", expected, a.Selectors)
+	}
+	for i, selector := range a.Selectors {
+		if hexutil.Encode(selector[:]) != expected[i] {
+			t.Errorf("Expected %v, got %x", expected[i], selector)
+		}
+	}
+	if len(a.Flags) != 0 {
+		t.Errorf("Expected no flags, got %v", a.Flags)
+	}
+
+	// the runtime alone is analyzed as such
+	if b := AnalyzeRuntime(creation[0x104 : 0x104+0x2d1]); b.Constructor != nil || !bytes.Equal(b.Runtime, a.Runtime) || len(b.Selectors) != 3 {
+		t.Errorf("Expected the same runtime, got %d bytes", len(b.Runtime))
+	}
+}
+
+func TestAnalyzeFlags(t *testing.T) {
+	t.Parallel()
+	// 0xff pushed as data is no SELFDESTRUCT
+	a := AnalyzeRuntime(common.FromHex("0x60fff4f5ff"))
+	if len(a.Flags) != 3 || a.Flags[vm.DELEGATECALL.String()][0] != 2 || a.Flags[vm.CREATE2.String()][0] != 3 || a.Flags[vm.SELFDESTRUCT.String()][0] != 4 {
+		t.Errorf("Expected DELEGATECALL at 2, CREATE2 at 3 and SELFDESTRUCT at 4, got %v", a.Flags)
+	}
+}
+
+func TestAnalyzeFactory(t *testing.T) {
+	t.Parallel()
+	// runtime copying 5 bytes of child creation code from 0x0e to memory,
+	// then the child code 0x6080604052 as data
+	runtime := common.FromHex("0x6040516100058061000e833900006080604052")
+	a := AnalyzeRuntime(runtime)
+	if a.Constructor != nil || a.Args != nil || !bytes.Equal(a.Runtime, runtime) {
+		t.Errorf("Expected the whole runtime, got constructor %x and runtime %x", a.Constructor, a.Runtime)
+	}
+	if len(a.Instructions) == 0 || a.Instructions[0].String() != "0x0000 PUSH1 0x40" {
+		t.Errorf("Expected the factory's instructions, got %v", a.Instructions)
+	}
+
+	if _, err := AnalyzeCreation(common.FromHex("0x60fff4f5ff")); !errors.Is(err, ErrNoRuntime) {
+		t.Errorf("Expected %v, got %v", ErrNoRuntime, err)
+	}
+}
+
+func TestParseMetadata(t *testing.T) {
+	t.Parallel()
+	// {"ipfs": 0x1220..., "solc": 0x000813} as solc 0.8.19 appends it
+	hash := bytes.Repeat([]byte{0xab}, 32)
+	raw := append(common.FromHex("0xa2646970667358221220"), hash...)
+	raw = append(raw, common.FromHex("0x64736f6c63430008130033")...)
+
+	m, n := ParseMetadata(append([]byte{0x00, 0xfe}, raw...))
+	if m == nil || n != len(raw) {
+		t.Fatalf("Expected %d bytes of metadata, got %d", len(raw), n)
+	}
+	if m.Solc != "0.8.19" || !strings.HasPrefix(m.IPFS, "Qm") || len(m.IPFS) != 46 {
+		t.Errorf("Expected 0.8.19 and a CIDv0, got %+v", m)
+	}
+
+	if m, _ := ParseMetadata(common.FromHex("0x6080604052")); m != nil {
+		t.Errorf("Expected no metadata, got %+v", m)
+	}
+	if base58([]byte("hello world")) != "StV1DL6CwTryKyV" || base58([]byte{0, 1}) != "12" {
+		t.Errorf("Expected base58, got %v", base58([]byte("hello world")))
+	}
+}
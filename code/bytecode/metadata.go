@@ -0,0 +1,160 @@
+package bytecode
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Metadata is the CBOR map solc appends to the runtime code, followed by its
+// length in two bytes
+type Metadata struct {
+	// Solc is the compiler version, given from 0.5.9 on
+	Solc string
+	// IPFS is the CID of the metadata JSON, Swarm its bzzr0 or bzzr1 hash
+	IPFS         string
+	Swarm        string
+	Experimental bool
+	// Raw is the CBOR with its length, the bytes stripped from the runtime
+	Raw []byte
+}
+
+// ParseMetadata parses the metadata at the end of runtime code, it returns
+// nil when there is none and else the number of bytes it takes
+func ParseMetadata(code []byte) (*Metadata, int) {
+	if len(code) < 2 {
+		return nil, 0
+	}
+	size := int(code[len(code)-2])<<8 | int(code[len(code)-1])
+	if size == 0 || size+2 > len(code) {
+		return nil, 0
+	}
+	raw := code[len(code)-2-size:]
+
+	item, n, err := decodeCBOR(raw[:size])
+	fields, ok := item.(map[string]interface{})
+	if err != nil || n != size || !ok {
+		return nil, 0
+	}
+
+	m := &Metadata{Raw: raw}
+	for key, value := range fields {
+		switch v := value.(type) {
+		case []byte:
+			switch {
+			case key == "ipfs":
+				m.IPFS = base58(v)
+			case key == "bzzr0" || key == "bzzr1":
+				m.Swarm = key + ":" + hexutil.Encode(v)[2:]
+			case key == "solc" && len(v) == 3:
+				m.Solc = fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+			}
+		case string:
+			// prereleases carry the full version string
+			if key == "solc" {
+				m.Solc = v
+			}
+		case bool:
+			if key == "experimental" {
+				m.Experimental = v
+			}
+		}
+	}
+	if m.IPFS == "" && m.Swarm == "" && m.Solc == "" {
+		return nil, 0
+	}
+	return m, len(raw)
+}
+
+var errCBOR = errors.New("bytecode: unsupported CBOR")
+
+// decodeCBOR decodes the subset of CBOR metadata uses: unsigned integers,
+// byte and text strings, maps with text keys and booleans
+func decodeCBOR(b []byte) (interface{}, int, error) {
+	if len(b) == 0 {
+		return nil, 0, errCBOR
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+
+	var value uint64
+	n := 1
+	switch {
+	case info < 24:
+		value = uint64(info)
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(b) < 1+size {
+			return nil, 0, errCBOR
+		}
+		for _, c := range b[1 : 1+size] {
+			value = value<<8 | uint64(c)
+		}
+		n += size
+	default:
+		return nil, 0, errCBOR
+	}
+
+	switch major {
+	case 0:
+		return value, n, nil
+	case 2, 3:
+		if value > uint64(len(b)-n) {
+			return nil, 0, errCBOR
+		}
+		data := b[n : n+int(value)]
+		if major == 3 {
+			return string(data), n + int(value), nil
+		}
+		return append([]byte{}, data...), n + int(value), nil
+	case 5:
+		fields := make(map[string]interface{})
+		for i := uint64(0); i < value; i++ {
+			key, kn, err := decodeCBOR(b[n:])
+			if err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, errCBOR
+			}
+			n += kn
+			item, in, err := decodeCBOR(b[n:])
+			if err != nil {
+				return nil, 0, err
+			}
+			n += in
+			fields[name] = item
+		}
+		return fields, n, nil
+	case 7:
+		if info == 20 || info == 21 {
+			return info == 21, n, nil
+		}
+	}
+	return nil, 0, errCBOR
+}
+
+const alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58 encodes b the way IPFS writes CIDv0
+func base58(b []byte) string {
+	n := new(big.Int).SetBytes(b)
+	radix, mod := big.NewInt(58), new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, alphabet[mod.Int64()])
+	}
+	for _, c := range b {
+		if c != 0 {
+			break
+		}
+		out = append(out, alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
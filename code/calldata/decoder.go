@@ -0,0 +1,257 @@
+package calldata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+
+	"ethereum-development-with-go/code/sigdb"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+/*
+ * Decode transaction calldata against known ABIs.
+ */
+
+// DefaultMaxDepth bounds how deep nested calls are decoded
+const DefaultMaxDepth = 4
+
+// Sources of a decoded method
+const (
+	SourceABI   = "abi"
+	SourceSigDB = "sigdb"
+)
+
+var (
+	// ErrShortData is returned for calldata without a 4-byte selector
+	ErrShortData = errors.New("calldata shorter than a selector")
+	// ErrUnknownSelector is returned when no known method matches the selector
+	ErrUnknownSelector = errors.New("unknown selector")
+)
+
+// Call is a decoded method call
+type Call struct {
+	Selector  string `json:"selector"`
+	Method    string `json:"method"`
+	Signature string `json:"signature"`
+	Source    string `json:"source"`
+	Args      []Arg  `json:"args"`
+	// Candidates lists the other signatures that also decoded the data
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+// Arg is a decoded, named argument. Value is JSON friendly: integers are
+// decimal strings, addresses and byte strings are hex, tuples are []Arg,
+// arrays are []interface{} and byte strings holding a call are *Nested.
+type Arg struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+	// Raw is the value as unpacked by go-ethereum
+	Raw interface{} `json:"-"`
+}
+
+// Nested is a bytes argument that decoded as a call, e.g. a multicall entry
+type Nested struct {
+	Data hexutil.Bytes `json:"data"`
+	Call *Call         `json:"call"`
+}
+
+// JSON renders the call as indented JSON
+func (c *Call) JSON() ([]byte, error) {
+	return json.MarshalIndent(c, "", "  ")
+}
+
+// Decoder decodes calldata using registered ABIs and a signature database fallback
+type Decoder struct {
+	mu       sync.RWMutex
+	methods  map[sigdb.Selector][]abi.Method
+	sigs     *sigdb.DB
+	MaxDepth int
+}
+
+// NewDecoder returns a decoder that falls back to sigs for unregistered
+// selectors; sigs may be nil
+func NewDecoder(sigs *sigdb.DB) *Decoder {
+	return &Decoder{
+		methods:  make(map[sigdb.Selector][]abi.Method),
+		sigs:     sigs,
+		MaxDepth: DefaultMaxDepth,
+	}
+}
+
+// Register adds the methods of a contract ABI
+func (d *Decoder) Register(contractAbi abi.ABI) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, method := range contractAbi.Methods {
+		var selector sigdb.Selector
+		copy(selector[:], method.ID)
+		d.methods[selector] = append(d.methods[selector], method)
+	}
+}
+
+// RegisterJSON parses and adds a JSON contract ABI
+func (d *Decoder) RegisterJSON(raw string) error {
+	parsed, err := abi.JSON(strings.NewReader(raw))
+	if err != nil {
+		return err
+	}
+
+	d.Register(parsed)
+	return nil
+}
+
+// Decode identifies the called method and decodes its arguments, following
+// bytes arguments that themselves hold calls
+func (d *Decoder) Decode(data []byte) (*Call, error) {
+	return d.decode(data, 0)
+}
+
+// decode decodes a call at the given nesting depth
+func (d *Decoder) decode(data []byte, depth int) (*Call, error) {
+	if len(data) < 4 {
+		return nil, ErrShortData
+	}
+
+	var selector sigdb.Selector
+	copy(selector[:], data[:4])
+
+	d.mu.RLock()
+	registered := d.methods[selector]
+	d.mu.RUnlock()
+
+	// registered ABIs are trusted, guesses from the signature database must
+	// re-encode to exactly the same bytes to count as a match
+	call, err := d.match(registered, data, depth, SourceABI, false)
+	if err == nil {
+		return call, nil
+	}
+
+	if d.sigs != nil {
+		var guesses []abi.Method
+		for _, sig := range d.sigs.Functions(selector) {
+			method, err := MethodFromSignature(sig)
+			if err != nil {
+				continue
+			}
+			guesses = append(guesses, method)
+		}
+		if call, err := d.match(guesses, data, depth, SourceSigDB, true); err == nil {
+			return call, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownSelector, selector.Hex())
+}
+
+// match decodes data with the first method that accepts it
+func (d *Decoder) match(methods []abi.Method, data []byte, depth int, source string, strict bool) (*Call, error) {
+	var call *Call
+	for _, method := range methods {
+		values, err := method.Inputs.Unpack(data[4:])
+		if err != nil {
+			continue
+		}
+		if strict {
+			packed, err := method.Inputs.Pack(values...)
+			if err != nil || !bytes.Equal(packed, data[4:]) {
+				continue
+			}
+		}
+		if call != nil {
+			if method.Sig != call.Signature && !contains(call.Candidates, method.Sig) {
+				call.Candidates = append(call.Candidates, method.Sig)
+			}
+			continue
+		}
+
+		call = &Call{
+			Selector:  hexutil.Encode(data[:4]),
+			Method:    method.RawName,
+			Signature: method.Sig,
+			Source:    source,
+			Args:      make([]Arg, len(method.Inputs)),
+		}
+		for i, input := range method.Inputs {
+			call.Args[i] = Arg{
+				Name:  input.Name,
+				Type:  input.Type.String(),
+				Value: d.normalize(input.Type, reflect.ValueOf(values[i]), depth),
+				Raw:   values[i],
+			}
+		}
+	}
+	if call == nil {
+		return nil, ErrUnknownSelector
+	}
+
+	return call, nil
+}
+
+// normalize converts an unpacked value into its JSON friendly form
+func (d *Decoder) normalize(typ abi.Type, v reflect.Value, depth int) interface{} {
+	switch typ.T {
+	case abi.IntTy, abi.UintTy:
+		if n, ok := v.Interface().(*big.Int); ok {
+			return n.String()
+		}
+		if v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64 {
+			return big.NewInt(v.Int()).String()
+		}
+		return new(big.Int).SetUint64(v.Uint()).String()
+	case abi.AddressTy:
+		return v.Interface().(common.Address).Hex()
+	case abi.FixedBytesTy, abi.FunctionTy:
+		b := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(b), v)
+		return hexutil.Encode(b)
+	case abi.BytesTy:
+		b := v.Bytes()
+		if depth < d.MaxDepth {
+			if call, err := d.decode(b, depth+1); err == nil {
+				return &Nested{Data: b, Call: call}
+			}
+		}
+		return hexutil.Encode(b)
+	case abi.SliceTy, abi.ArrayTy:
+		out := make([]interface{}, v.Len())
+		for i := range out {
+			out[i] = d.normalize(*typ.Elem, v.Index(i), depth)
+		}
+		return out
+	case abi.TupleTy:
+		out := make([]Arg, len(typ.TupleElems))
+		for i, elem := range typ.TupleElems {
+			field := v.Field(i)
+			out[i] = Arg{
+				Name:  typ.TupleRawNames[i],
+				Type:  elem.String(),
+				Value: d.normalize(*elem, field, depth),
+				Raw:   field.Interface(),
+			}
+		}
+		return out
+	default:
+		return v.Interface()
+	}
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,195 @@
+package calldata
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	store "ethereum-development-with-go/code/contracts"
+	token "ethereum-development-with-go/code/contracts_erc20"
+	"ethereum-development-with-go/code/sigdb"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var to = common.HexToAddress("0x4592d8f8d7b001e72cb26a73e4fa1806a51ac79d")
+
+func pack(t *testing.T, sig string, args ...interface{}) []byte {
+	method, err := MethodFromSignature(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := method.Inputs.Pack(args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return append(method.ID, data...)
+}
+
+func TestDecodeRegistered(t *testing.T) {
+	t.Parallel()
+	decoder := NewDecoder(nil)
+	if err := decoder.RegisterJSON(token.TokenABI); err != nil {
+		t.Fatal(err)
+	}
+
+	// the same bytes transfer_tokens.go assembles by hand
+	data := hexutil.MustDecode("0xa9059cbb0000000000000000000000004592d8f8d7b001e72cb26a73e4fa1806a51ac79d00000000000000000000000000000000000000000000003635c9adc5dea00000")
+	call, err := decoder.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.Method != "transfer" || call.Source != SourceABI {
+		t.Errorf("Expected transfer from abi, got %v from %v", call.Method, call.Source)
+	}
+	if call.Args[0].Name != "to" || call.Args[0].Value != to.Hex() {
+		t.Errorf("Expected to=%v, got %v=%v", to.Hex(), call.Args[0].Name, call.Args[0].Value)
+	}
+	if call.Args[1].Name != "tokens" || call.Args[1].Value != "1000000000000000000000" {
+		t.Errorf("Expected tokens=1000000000000000000000, got %v=%v", call.Args[1].Name, call.Args[1].Value)
+	}
+	if call.Args[1].Raw.(*big.Int).Cmp(big.NewInt(0)) != 1 {
+		t.Errorf("Expected raw *big.Int, got %v", call.Args[1].Raw)
+	}
+}
+
+func TestDecodeSigDBFallback(t *testing.T) {
+	t.Parallel()
+	decoder := NewDecoder(sigdb.Default())
+
+	data := pack(t, "approve(address,uint256)", to, big.NewInt(5))
+	call, err := decoder.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.Signature != "approve(address,uint256)" || call.Source != SourceSigDB {
+		t.Errorf("Expected approve from sigdb, got %v from %v", call.Signature, call.Source)
+	}
+	if call.Args[0].Name != "arg0" {
+		t.Errorf("Expected %v, got %v", "arg0", call.Args[0].Name)
+	}
+
+	_, err = decoder.Decode(hexutil.MustDecode("0xdeadbeef"))
+	if !errors.Is(err, ErrUnknownSelector) {
+		t.Errorf("Expected %v, got %v", ErrUnknownSelector, err)
+	}
+	_, err = decoder.Decode([]byte{1, 2})
+	if !errors.Is(err, ErrShortData) {
+		t.Errorf("Expected %v, got %v", ErrShortData, err)
+	}
+}
+
+func TestDecodeNested(t *testing.T) {
+	t.Parallel()
+	decoder := NewDecoder(sigdb.Default())
+	storeAbi, err := abi.JSON(strings.NewReader(store.StoreABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder.Register(storeAbi)
+
+	key := [32]byte{}
+	value := [32]byte{}
+	copy(key[:], []byte("foo"))
+	copy(value[:], []byte("bar"))
+	setItem, err := storeAbi.Pack("setItem", key, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transfer := pack(t, "transfer(address,uint256)", to, big.NewInt(1))
+	execute := pack(t, "execute(bytes)", transfer)
+	multicall := pack(t, "multicall(bytes[])", [][]byte{setItem, execute, {0xde, 0xad}})
+
+	call, err := decoder.Decode(multicall)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := call.Args[0].Value.([]interface{})
+	if len(calls) != 3 {
+		t.Fatalf("Expected 3 calls, got %d", len(calls))
+	}
+
+	first := calls[0].(*Nested).Call
+	if first.Method != "setItem" || first.Args[0].Value != hexutil.Encode(key[:]) {
+		t.Errorf("Expected setItem(foo, bar), got %v(%v)", first.Method, first.Args[0].Value)
+	}
+
+	second := calls[1].(*Nested).Call
+	inner := second.Args[0].Value.(*Nested).Call
+	if second.Method != "execute" || inner.Method != "transfer" {
+		t.Errorf("Expected execute(transfer), got %v(%v)", second.Method, inner.Method)
+	}
+
+	if calls[2] != "0xdead" {
+		t.Errorf("Expected %v, got %v", "0xdead", calls[2])
+	}
+
+	out, err := call.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rendered map[string]interface{}
+	if err := json.Unmarshal(out, &rendered); err != nil {
+		t.Fatal(err)
+	}
+	if rendered["signature"] != "multicall(bytes[])" {
+		t.Errorf("Expected %v, got %v", "multicall(bytes[])", rendered["signature"])
+	}
+}
+
+func TestDecodeTuple(t *testing.T) {
+	t.Parallel()
+	db := sigdb.New()
+	db.AddFunction("aggregate((address,bytes)[])")
+	db.AddFunction("transfer(address,uint256)")
+	decoder := NewDecoder(db)
+
+	transfer := pack(t, "transfer(address,uint256)", to, big.NewInt(1))
+	calls := []struct {
+		Field0 common.Address
+		Field1 []byte
+	}{{to, transfer}}
+	data := pack(t, "aggregate((address,bytes)[])", calls)
+
+	call, err := decoder.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := call.Args[0].Value.([]interface{})[0].([]Arg)
+	if entry[0].Value != to.Hex() {
+		t.Errorf("Expected %v, got %v", to.Hex(), entry[0].Value)
+	}
+	if entry[1].Value.(*Nested).Call.Method != "transfer" {
+		t.Errorf("Expected nested transfer, got %v", entry[1].Value)
+	}
+}
+
+func TestParseSignature(t *testing.T) {
+	t.Parallel()
+	tests := []string{
+		"transfer(address,uint256)",
+		"aggregate((address,bytes)[])",
+		"swap((address,(uint256,bool)[2]),bytes32[])",
+		"noop()",
+	}
+	for _, sig := range tests {
+		method, err := MethodFromSignature(sig)
+		if err != nil {
+			t.Errorf("Got error for %v: %s", sig, err)
+			continue
+		}
+		if method.Sig != sig {
+			t.Errorf("Expected %v, got %v", sig, method.Sig)
+		}
+	}
+
+	for _, sig := range []string{"transfer", "f(uint256", "f((uint256)", "f(uint256,)"} {
+		if _, err := MethodFromSignature(sig); err == nil {
+			t.Errorf("Expected error for %v", sig)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package calldata
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// ParseSignature turns a human-readable signature such as
+// "multicall((address,bytes)[])" into its name and unnamed arguments
+func ParseSignature(sig string) (string, abi.Arguments, error) {
+	sig = strings.Join(strings.Fields(sig), "")
+	open := strings.IndexByte(sig, '(')
+	if open <= 0 || !strings.HasSuffix(sig, ")") {
+		return "", nil, fmt.Errorf("calldata: invalid signature %q", sig)
+	}
+
+	name := sig[:open]
+	params, err := splitParams(sig[open+1 : len(sig)-1])
+	if err != nil {
+		return "", nil, fmt.Errorf("calldata: invalid signature %q: %v", sig, err)
+	}
+
+	args := make(abi.Arguments, len(params))
+	for i, param := range params {
+		marshaling, err := parseParam(fmt.Sprintf("arg%d", i), param)
+		if err != nil {
+			return "", nil, fmt.Errorf("calldata: invalid signature %q: %v", sig, err)
+		}
+		typ, err := abi.NewType(marshaling.Type, "", marshaling.Components)
+		if err != nil {
+			return "", nil, fmt.Errorf("calldata: invalid signature %q: %v", sig, err)
+		}
+		args[i] = abi.Argument{Name: marshaling.Name, Type: typ}
+	}
+
+	return name, args, nil
+}
+
+// MethodFromSignature builds an ABI method from a human-readable signature
+func MethodFromSignature(sig string) (abi.Method, error) {
+	name, args, err := ParseSignature(sig)
+	if err != nil {
+		return abi.Method{}, err
+	}
+
+	return abi.NewMethod(name, name, abi.Function, "nonpayable", false, false, args, nil), nil
+}
+
+// parseParam converts a single parameter type into its ABI marshaling,
+// expanding tuples into named components
+func parseParam(name string, param string) (abi.ArgumentMarshaling, error) {
+	if !strings.HasPrefix(param, "(") {
+		return abi.ArgumentMarshaling{Name: name, Type: param}, nil
+	}
+
+	closing := matchParen(param)
+	if closing < 0 {
+		return abi.ArgumentMarshaling{}, fmt.Errorf("unbalanced tuple %q", param)
+	}
+	params, err := splitParams(param[1:closing])
+	if err != nil {
+		return abi.ArgumentMarshaling{}, err
+	}
+
+	components := make([]abi.ArgumentMarshaling, len(params))
+	for i, p := range params {
+		if components[i], err = parseParam(fmt.Sprintf("field%d", i), p); err != nil {
+			return abi.ArgumentMarshaling{}, err
+		}
+	}
+
+	return abi.ArgumentMarshaling{
+		Name:       name,
+		Type:       "tuple" + param[closing+1:],
+		Components: components,
+	}, nil
+}
+
+// splitParams splits a parameter list on its top level commas
+func splitParams(list string) ([]string, error) {
+	if list == "" {
+		return nil, nil
+	}
+
+	var params []string
+	depth, start := 0, 0
+	for i, r := range list {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses in %q", list)
+			}
+		case ',':
+			if depth == 0 {
+				params = append(params, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses in %q", list)
+	}
+	params = append(params, list[start:])
+
+	for _, p := range params {
+		if p == "" {
+			return nil, fmt.Errorf("empty parameter in %q", list)
+		}
+	}
+
+	return params, nil
+}
+
+// matchParen returns the index of the parenthesis closing the one at index 0
+func matchParen(s string) int {
+	depth := 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+
+	"ethereum-development-with-go/code/artifact"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+func main() {
+	client, err := ethclient.Dial("https://rinkeby.infura.io/v3/**********")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// 也可以是solc --combined-json、标准JSON输出、Hardhat的artifacts或者Foundry的out/目录里的JSON
+	artifacts, err := artifact.Load("contracts/Store_sol_Store.abi")
+	if err != nil {
+		log.Fatal(err)
+	}
+	store, err := artifact.Find(artifacts, "Store")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(store.FullName(), len(store.Bytecode)) // Store.sol:Store ...
+
+	// 不需要abigen生成的代码，按方法名调用
+	contract := store.Bind(common.HexToAddress("0x147B8eb97fD247D06C4006D269c90C1908Fb5D54"), client)
+	var version []interface{}
+	if err := contract.Call(nil, &version, "version"); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(version[0]) // 1.0
+
+	privateKey, err := crypto.HexToECDSA("fad9c8855b740a0b7ed4c221dbad0f33a83a49cad6b3fe8d5817ac83d38b6a19")
+	if err != nil {
+		log.Fatal(err)
+	}
+	auth, err := bind.NewKeyedTransactorWithChainID(privateKey, big.NewInt(4))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	key := [32]byte{}
+	value := [32]byte{}
+	copy(key[:], []byte("foo"))
+	copy(value[:], []byte("bar"))
+	tx, err := contract.Transact(auth, "setItem", key, value)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(tx.Hash().Hex()) // 0x...
+
+	// 部署也一样，构造函数参数跟在后面
+	address, tx, contract, err := store.Deploy(auth, client, "1.0")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(address.Hex())   // 0x...
+	fmt.Println(tx.Hash().Hex()) // 0x...
+
+	_ = contract
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 
+	"ethereum-development-with-go/code/bytecode"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 )
@@ -17,10 +18,38 @@ func main() {
 	}
 
 	contractAddress := common.HexToAddress("0x147B8eb97fD247D06C4006D269c90C1908Fb5D54")
-	bytecode, err := client.CodeAt(context.Background(), contractAddress, nil) // nil is latest block
+	code, err := client.CodeAt(context.Background(), contractAddress, nil) // nil is latest block
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	fmt.Println(hex.EncodeToString(bytecode)) // 60806...10029
+	fmt.Println(hex.EncodeToString(code)) // 60806...10029
+
+	// 反汇编部署的代码，末尾的CBOR元数据会被分离出来
+	// 外部账户没有代码，指令可能少于三条
+	analysis := bytecode.AnalyzeRuntime(code)
+	instructions := analysis.Instructions
+	if len(instructions) > 3 {
+		instructions = instructions[:3]
+	}
+	for _, instruction := range instructions {
+		fmt.Println(instruction)
+	}
+	// 0x0000 PUSH1 0x80
+	// 0x0002 PUSH1 0x40
+	// 0x0004 MSTORE
+
+	if analysis.Metadata != nil {
+		fmt.Println(analysis.Metadata.Solc, analysis.Metadata.IPFS, analysis.Metadata.Swarm) // ... bzzr0:...
+	}
+
+	// 分发器比较的函数选择器
+	for _, selector := range analysis.Selectors {
+		fmt.Println(hex.EncodeToString(selector[:])) // 48f343f3 ...
+	}
+
+	// SELFDESTRUCT、DELEGATECALL和CREATE2出现的位置
+	for op, pcs := range analysis.Flags {
+		fmt.Println(op, pcs)
+	}
 }
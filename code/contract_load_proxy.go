@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"ethereum-development-with-go/code/proxy"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	store "ethereum-development-with-go/code/contracts" // for demo
+)
+
+func main() {
+	client, err := ethclient.Dial("https://rinkeby.infura.io/v3/**********")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// 代理合约本身的ABI只有升级相关的方法，要用实现合约的ABI
+	address := common.HexToAddress("0x147B8eb97fD247D06C4006D269c90C1908Fb5D54")
+	p, err := proxy.Detect(context.Background(), client, address, nil) // nil is latest block
+	if errors.Is(err, proxy.ErrNotProxy) {
+		fmt.Println("not a proxy")
+	} else if err != nil {
+		log.Fatal(err)
+	} else {
+		fmt.Println(p.Kind, p.Implementation.Hex(), p.Admin.Hex()) // EIP-1967 0x... 0x...
+	}
+
+	// 代理的代理（比如EIP-1167克隆）也会一直追踪到最终的实现合约
+	implementation, err := proxy.Resolve(context.Background(), client, address, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(implementation.Hex()) // 0x...
+
+	// 实现合约的ABI绑定到代理的地址上，调用经过代理转发
+	instance, err := store.NewStore(address, client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("contract is loaded")
+	_ = instance
+}
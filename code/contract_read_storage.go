@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+
+	"ethereum-development-with-go/code/storage"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// storeLayout is the storageLayout output of solc for Store.sol
+const storeLayout = `{
+	"storage": [
+		{"label": "version", "offset": 0, "slot": "0", "type": "t_string_storage"},
+		{"label": "items", "offset": 0, "slot": "1", "type": "t_mapping(t_bytes32,t_bytes32)"}
+	],
+	"types": {
+		"t_bytes32": {"encoding": "inplace", "label": "bytes32", "numberOfBytes": "32"},
+		"t_mapping(t_bytes32,t_bytes32)": {"encoding": "mapping", "key": "t_bytes32", "label": "mapping(bytes32 => bytes32)", "numberOfBytes": "32", "value": "t_bytes32"},
+		"t_string_storage": {"encoding": "bytes", "label": "string", "numberOfBytes": "32"}
+	}
+}`
+
+func main() {
+	client, err := ethclient.Dial("https://rinkeby.infura.io/v3/**********")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// 用solc --storage-layout的输出，不需要getter也能读取任何状态变量
+	layout, err := storage.ParseLayout([]byte(storeLayout))
+	if err != nil {
+		log.Fatal(err)
+	}
+	address := common.HexToAddress("0x147B8eb97fD247D06C4006D269c90C1908Fb5D54")
+	reader := storage.NewReader(client, address, layout)
+
+	version, err := reader.Read(context.Background(), "version")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(version) // 1.0
+
+	// items[key]的槽是keccak256(key . uint256(1))
+	key := [32]byte{}
+	copy(key[:], []byte("foo"))
+	loc, err := layout.Locate("items", key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(loc.Slot.Hex()) // 0x...
+
+	// 也可以读取任何历史区块的值
+	header, err := client.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	value, err := reader.At(header.Number).Read(context.Background(), "items", key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// bytes32右侧补零，打印前去掉
+	fmt.Println(string(bytes.TrimRight(value.([]byte), "\x00"))) // bar
+}
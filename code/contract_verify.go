@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"ethereum-development-with-go/code/verify"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+func main() {
+	client, err := ethclient.Dial("https://rinkeby.infura.io/v3/**********")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// solc --abi --bin Store.sol 的输出
+	abiFile, err := os.Open("contracts/Store_sol_Store.abi")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer abiFile.Close()
+	parsed, err := abi.JSON(abiFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	bin, err := ioutil.ReadFile("contracts/Store_sol_Store.bin")
+	if err != nil {
+		log.Fatal(err)
+	}
+	artifact := &verify.Artifact{ABI: parsed, Bin: common.FromHex(strings.TrimSpace(string(bin)))}
+
+	// 在本地EVM里执行创建代码得到运行时代码，再和CodeAt的结果比较
+	address := common.HexToAddress("0x147B8eb97fD247D06C4006D269c90C1908Fb5D54")
+	result, err := verify.Verify(context.Background(), client, address, nil, artifact, "1.0") // nil is latest block
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(result.Match) // full
+
+	// 只有元数据哈希不同是部分匹配，说明源码的注释或编译设置不一样
+	if err := result.Err(); err != nil {
+		log.Fatal(err)
+	}
+	if result.ActualMetadata != nil {
+		fmt.Println(result.ActualMetadata.Swarm) // bzzr0:...
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -14,6 +15,8 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient"
 
 	store "ethereum-development-with-go/code/contracts"
+	"ethereum-development-with-go/code/reverts"
+	"ethereum-development-with-go/code/sigdb"
 )
 
 func main() {
@@ -56,12 +59,21 @@ func main() {
 		log.Fatal(err)
 	}
 
-	estimatedGas, err := client.EstimateGas(context.Background(), ethereum.CallMsg{
-		From: fromAddress,
-		To:   &address,
-		Data: encodedData,
+	// decodes Error(string), Panic(uint256) and custom errors instead of an opaque string
+	decoder := reverts.NewDecoder(sigdb.Default())
+	decoder.Register(parsed)
+
+	estimatedGas, err := decoder.EstimateGas(context.Background(), client, ethereum.CallMsg{
+		From:     fromAddress,
+		To:       &address,
+		Data:     encodedData,
 		GasPrice: gasPrice,
 	})
+	// Store只会在发送ETH时回滚，setItem不是payable，回滚时没有数据
+	var revert *reverts.RevertError
+	if errors.As(err, &revert) {
+		log.Fatalf("%s: %v", revert.Kind, revert) // Unknown: execution reverted
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
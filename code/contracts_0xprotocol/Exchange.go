@@ -4,6 +4,7 @@
 package exchange
 
 import (
+	"errors"
 	"math/big"
 	"strings"
 
@@ -15,8 +16,26 @@ import (
 	"github.com/ethereum/go-ethereum/event"
 )
 
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = errors.New
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+)
+
+// ExchangeMetaData contains all meta data concerning the Exchange contract.
+var ExchangeMetaData = &bind.MetaData{
+	ABI: "[{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"name\":\"maker\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"taker\",\"type\":\"address\"},{\"indexed\":true,\"name\":\"feeRecipient\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"makerToken\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"takerToken\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"filledMakerTokenAmount\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"filledTakerTokenAmount\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"paidMakerFee\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"paidTakerFee\",\"type\":\"uint256\"},{\"indexed\":true,\"name\":\"tokens\",\"type\":\"bytes32\"},{\"indexed\":false,\"name\":\"orderHash\",\"type\":\"bytes32\"}],\"name\":\"LogFill\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"name\":\"maker\",\"type\":\"address\"},{\"indexed\":true,\"name\":\"feeRecipient\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"makerToken\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"takerToken\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"cancelledMakerTokenAmount\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"cancelledTakerTokenAmount\",\"type\":\"uint256\"},{\"indexed\":true,\"name\":\"tokens\",\"type\":\"bytes32\"},{\"indexed\":false,\"name\":\"orderHash\",\"type\":\"bytes32\"}],\"name\":\"LogCancel\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"name\":\"errorId\",\"type\":\"uint8\"},{\"indexed\":true,\"name\":\"orderHash\",\"type\":\"bytes32\"}],\"name\":\"LogError\",\"type\":\"event\"}]",
+}
+
 // ExchangeABI is the input ABI used to generate the binding from.
-const ExchangeABI = "[{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"name\":\"maker\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"taker\",\"type\":\"address\"},{\"indexed\":true,\"name\":\"feeRecipient\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"makerToken\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"takerToken\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"filledMakerTokenAmount\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"filledTakerTokenAmount\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"paidMakerFee\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"paidTakerFee\",\"type\":\"uint256\"},{\"indexed\":true,\"name\":\"tokens\",\"type\":\"bytes32\"},{\"indexed\":false,\"name\":\"orderHash\",\"type\":\"bytes32\"}],\"name\":\"LogFill\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"name\":\"maker\",\"type\":\"address\"},{\"indexed\":true,\"name\":\"feeRecipient\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"makerToken\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"takerToken\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"cancelledMakerTokenAmount\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"cancelledTakerTokenAmount\",\"type\":\"uint256\"},{\"indexed\":true,\"name\":\"tokens\",\"type\":\"bytes32\"},{\"indexed\":false,\"name\":\"orderHash\",\"type\":\"bytes32\"}],\"name\":\"LogCancel\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"name\":\"errorId\",\"type\":\"uint8\"},{\"indexed\":true,\"name\":\"orderHash\",\"type\":\"bytes32\"}],\"name\":\"LogError\",\"type\":\"event\"}]"
+// Deprecated: Use ExchangeMetaData.ABI instead.
+var ExchangeABI = ExchangeMetaData.ABI
 
 // Exchange is an auto generated Go binding around an Ethereum contract.
 type Exchange struct {
@@ -126,7 +145,7 @@ func bindExchange(address common.Address, caller bind.ContractCaller, transactor
 // sets the output to result. The result type might be a single field for simple
 // returns, a slice of interfaces for anonymous returns and a struct for named
 // returns.
-func (_Exchange *ExchangeRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+func (_Exchange *ExchangeRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
 	return _Exchange.Contract.ExchangeCaller.contract.Call(opts, result, method, params...)
 }
 
@@ -145,7 +164,7 @@ func (_Exchange *ExchangeRaw) Transact(opts *bind.TransactOpts, method string, p
 // sets the output to result. The result type might be a single field for simple
 // returns, a slice of interfaces for anonymous returns and a struct for named
 // returns.
-func (_Exchange *ExchangeCallerRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+func (_Exchange *ExchangeCallerRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
 	return _Exchange.Contract.contract.Call(opts, result, method, params...)
 }
 
@@ -242,7 +261,7 @@ type ExchangeLogCancel struct {
 
 // FilterLogCancel is a free log retrieval operation binding the contract event 0x67d66f160bc93d925d05dae1794c90d2d6d6688b29b84ff069398a9b04587131.
 //
-// Solidity: e LogCancel(maker indexed address, feeRecipient indexed address, makerToken address, takerToken address, cancelledMakerTokenAmount uint256, cancelledTakerTokenAmount uint256, tokens indexed bytes32, orderHash bytes32)
+// Solidity: event LogCancel(address indexed maker, address indexed feeRecipient, address makerToken, address takerToken, uint256 cancelledMakerTokenAmount, uint256 cancelledTakerTokenAmount, bytes32 indexed tokens, bytes32 orderHash)
 func (_Exchange *ExchangeFilterer) FilterLogCancel(opts *bind.FilterOpts, maker []common.Address, feeRecipient []common.Address, tokens [][32]byte) (*ExchangeLogCancelIterator, error) {
 
 	var makerRule []interface{}
@@ -268,7 +287,7 @@ func (_Exchange *ExchangeFilterer) FilterLogCancel(opts *bind.FilterOpts, maker
 
 // WatchLogCancel is a free log subscription operation binding the contract event 0x67d66f160bc93d925d05dae1794c90d2d6d6688b29b84ff069398a9b04587131.
 //
-// Solidity: e LogCancel(maker indexed address, feeRecipient indexed address, makerToken address, takerToken address, cancelledMakerTokenAmount uint256, cancelledTakerTokenAmount uint256, tokens indexed bytes32, orderHash bytes32)
+// Solidity: event LogCancel(address indexed maker, address indexed feeRecipient, address makerToken, address takerToken, uint256 cancelledMakerTokenAmount, uint256 cancelledTakerTokenAmount, bytes32 indexed tokens, bytes32 orderHash)
 func (_Exchange *ExchangeFilterer) WatchLogCancel(opts *bind.WatchOpts, sink chan<- *ExchangeLogCancel, maker []common.Address, feeRecipient []common.Address, tokens [][32]byte) (event.Subscription, error) {
 
 	var makerRule []interface{}
@@ -317,6 +336,18 @@ func (_Exchange *ExchangeFilterer) WatchLogCancel(opts *bind.WatchOpts, sink cha
 	}), nil
 }
 
+// ParseLogCancel is a log parse operation binding the contract event 0x67d66f160bc93d925d05dae1794c90d2d6d6688b29b84ff069398a9b04587131.
+//
+// Solidity: event LogCancel(address indexed maker, address indexed feeRecipient, address makerToken, address takerToken, uint256 cancelledMakerTokenAmount, uint256 cancelledTakerTokenAmount, bytes32 indexed tokens, bytes32 orderHash)
+func (_Exchange *ExchangeFilterer) ParseLogCancel(log types.Log) (*ExchangeLogCancel, error) {
+	event := new(ExchangeLogCancel)
+	if err := _Exchange.contract.UnpackLog(event, "LogCancel", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
 // ExchangeLogErrorIterator is returned from FilterLogError and is used to iterate over the raw logs and unpacked data for LogError events raised by the Exchange contract.
 type ExchangeLogErrorIterator struct {
 	Event *ExchangeLogError // Event containing the contract specifics and raw log
@@ -393,7 +424,7 @@ type ExchangeLogError struct {
 
 // FilterLogError is a free log retrieval operation binding the contract event 0x36d86c59e00bd73dc19ba3adfe068e4b64ac7e92be35546adeddf1b956a87e90.
 //
-// Solidity: e LogError(errorId indexed uint8, orderHash indexed bytes32)
+// Solidity: event LogError(uint8 indexed errorId, bytes32 indexed orderHash)
 func (_Exchange *ExchangeFilterer) FilterLogError(opts *bind.FilterOpts, errorId []uint8, orderHash [][32]byte) (*ExchangeLogErrorIterator, error) {
 
 	var errorIdRule []interface{}
@@ -414,7 +445,7 @@ func (_Exchange *ExchangeFilterer) FilterLogError(opts *bind.FilterOpts, errorId
 
 // WatchLogError is a free log subscription operation binding the contract event 0x36d86c59e00bd73dc19ba3adfe068e4b64ac7e92be35546adeddf1b956a87e90.
 //
-// Solidity: e LogError(errorId indexed uint8, orderHash indexed bytes32)
+// Solidity: event LogError(uint8 indexed errorId, bytes32 indexed orderHash)
 func (_Exchange *ExchangeFilterer) WatchLogError(opts *bind.WatchOpts, sink chan<- *ExchangeLogError, errorId []uint8, orderHash [][32]byte) (event.Subscription, error) {
 
 	var errorIdRule []interface{}
@@ -458,6 +489,18 @@ func (_Exchange *ExchangeFilterer) WatchLogError(opts *bind.WatchOpts, sink chan
 	}), nil
 }
 
+// ParseLogError is a log parse operation binding the contract event 0x36d86c59e00bd73dc19ba3adfe068e4b64ac7e92be35546adeddf1b956a87e90.
+//
+// Solidity: event LogError(uint8 indexed errorId, bytes32 indexed orderHash)
+func (_Exchange *ExchangeFilterer) ParseLogError(log types.Log) (*ExchangeLogError, error) {
+	event := new(ExchangeLogError)
+	if err := _Exchange.contract.UnpackLog(event, "LogError", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
 // ExchangeLogFillIterator is returned from FilterLogFill and is used to iterate over the raw logs and unpacked data for LogFill events raised by the Exchange contract.
 type ExchangeLogFillIterator struct {
 	Event *ExchangeLogFill // Event containing the contract specifics and raw log
@@ -543,7 +586,7 @@ type ExchangeLogFill struct {
 
 // FilterLogFill is a free log retrieval operation binding the contract event 0x0d0b9391970d9a25552f37d436d2aae2925e2bfe1b2a923754bada030c498cb3.
 //
-// Solidity: e LogFill(maker indexed address, taker address, feeRecipient indexed address, makerToken address, takerToken address, filledMakerTokenAmount uint256, filledTakerTokenAmount uint256, paidMakerFee uint256, paidTakerFee uint256, tokens indexed bytes32, orderHash bytes32)
+// Solidity: event LogFill(address indexed maker, address taker, address indexed feeRecipient, address makerToken, address takerToken, uint256 filledMakerTokenAmount, uint256 filledTakerTokenAmount, uint256 paidMakerFee, uint256 paidTakerFee, bytes32 indexed tokens, bytes32 orderHash)
 func (_Exchange *ExchangeFilterer) FilterLogFill(opts *bind.FilterOpts, maker []common.Address, feeRecipient []common.Address, tokens [][32]byte) (*ExchangeLogFillIterator, error) {
 
 	var makerRule []interface{}
@@ -570,7 +613,7 @@ func (_Exchange *ExchangeFilterer) FilterLogFill(opts *bind.FilterOpts, maker []
 
 // WatchLogFill is a free log subscription operation binding the contract event 0x0d0b9391970d9a25552f37d436d2aae2925e2bfe1b2a923754bada030c498cb3.
 //
-// Solidity: e LogFill(maker indexed address, taker address, feeRecipient indexed address, makerToken address, takerToken address, filledMakerTokenAmount uint256, filledTakerTokenAmount uint256, paidMakerFee uint256, paidTakerFee uint256, tokens indexed bytes32, orderHash bytes32)
+// Solidity: event LogFill(address indexed maker, address taker, address indexed feeRecipient, address makerToken, address takerToken, uint256 filledMakerTokenAmount, uint256 filledTakerTokenAmount, uint256 paidMakerFee, uint256 paidTakerFee, bytes32 indexed tokens, bytes32 orderHash)
 func (_Exchange *ExchangeFilterer) WatchLogFill(opts *bind.WatchOpts, sink chan<- *ExchangeLogFill, maker []common.Address, feeRecipient []common.Address, tokens [][32]byte) (event.Subscription, error) {
 
 	var makerRule []interface{}
@@ -619,3 +662,15 @@ func (_Exchange *ExchangeFilterer) WatchLogFill(opts *bind.WatchOpts, sink chan<
 		}
 	}), nil
 }
+
+// ParseLogFill is a log parse operation binding the contract event 0x0d0b9391970d9a25552f37d436d2aae2925e2bfe1b2a923754bada030c498cb3.
+//
+// Solidity: event LogFill(address indexed maker, address taker, address indexed feeRecipient, address makerToken, address takerToken, uint256 filledMakerTokenAmount, uint256 filledTakerTokenAmount, uint256 paidMakerFee, uint256 paidTakerFee, bytes32 indexed tokens, bytes32 orderHash)
+func (_Exchange *ExchangeFilterer) ParseLogFill(log types.Log) (*ExchangeLogFill, error) {
+	event := new(ExchangeLogFill)
+	if err := _Exchange.contract.UnpackLog(event, "LogFill", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
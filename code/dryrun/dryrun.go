@@ -0,0 +1,338 @@
+package dryrun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"ethereum-development-with-go/code/rawtx"
+	"ethereum-development-with-go/code/reverts"
+	"ethereum-development-with-go/code/sigdb"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+/*
+ * Simulate transactions with eth_call at the pending block before sending them.
+ */
+
+// Caller sends raw JSON-RPC requests, *rpc.Client implements it
+type Caller interface {
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// Account overrides the state of one account for the simulation
+type Account struct {
+	Nonce   *hexutil.Uint64 `json:"nonce,omitempty"`
+	Code    hexutil.Bytes   `json:"code,omitempty"`
+	Balance *hexutil.Big    `json:"balance,omitempty"`
+	// State replaces the whole storage, StateDiff only the given slots
+	State     map[common.Hash]common.Hash `json:"state,omitempty"`
+	StateDiff map[common.Hash]common.Hash `json:"stateDiff,omitempty"`
+}
+
+// Overrides is the stateOverride set of eth_call
+type Overrides map[common.Address]*Account
+
+func (o Overrides) account(address common.Address) *Account {
+	if o[address] == nil {
+		o[address] = new(Account)
+	}
+	return o[address]
+}
+
+// SetBalance overrides the balance of address
+func (o Overrides) SetBalance(address common.Address, balance *big.Int) Overrides {
+	o.account(address).Balance = (*hexutil.Big)(balance)
+	return o
+}
+
+// SetNonce overrides the nonce of address
+func (o Overrides) SetNonce(address common.Address, nonce uint64) Overrides {
+	o.account(address).Nonce = (*hexutil.Uint64)(&nonce)
+	return o
+}
+
+// SetCode overrides the code of address
+func (o Overrides) SetCode(address common.Address, code []byte) Overrides {
+	o.account(address).Code = code
+	return o
+}
+
+// SetStorage overrides one storage slot of address, keeping the others
+func (o Overrides) SetStorage(address common.Address, slot, value common.Hash) Overrides {
+	account := o.account(address)
+	if account.StateDiff == nil {
+		account.StateDiff = make(map[common.Hash]common.Hash)
+	}
+	account.StateDiff[slot] = value
+	return o
+}
+
+// Result is the outcome of a simulation
+type Result struct {
+	Success bool
+	// ReturnData is the call output, or the revert data when it failed
+	ReturnData []byte
+	// Revert is the decoded revert when Success is false
+	Revert *reverts.RevertError
+	// GasUsed is measured by the trace, or estimated with eth_estimateGas
+	// when the node cannot trace
+	GasUsed      uint64
+	GasEstimated bool
+	// Traced reports whether debug_traceCall ran and returned logs, Logs are
+	// only known then. The gas used of a trace without logs is still kept
+	Traced bool
+	Logs   []*types.Log
+	// Warnings describe what the node could not do
+	Warnings []string
+}
+
+// Simulator runs dry runs against a node
+type Simulator struct {
+	client  Caller
+	reverts *reverts.Decoder
+	// noTrace is set once the node rejected debug_traceCall
+	noTrace int32
+	// logs caches whether the callTracer of the node returns logs
+	logs int32
+}
+
+// states of Simulator.logs
+const (
+	logsUnknown int32 = iota
+	logsSupported
+	logsUnsupported
+)
+
+// New returns a simulator, decoder decodes reverts and may be nil
+func New(client Caller, decoder *reverts.Decoder) *Simulator {
+	if decoder == nil {
+		decoder = reverts.NewDecoder(sigdb.Default())
+	}
+	return &Simulator{client: client, reverts: decoder}
+}
+
+// Transaction simulates a signed transaction from its sender
+func (s *Simulator) Transaction(ctx context.Context, tx *types.Transaction, overrides Overrides) (*Result, error) {
+	signer, _ := rawtx.SignerFor(tx)
+	from, err := types.Sender(signer, tx)
+	if err != nil {
+		return nil, fmt.Errorf("dryrun: sender: %w", err)
+	}
+
+	msg := ethereum.CallMsg{
+		From:       from,
+		To:         tx.To(),
+		Gas:        tx.Gas(),
+		Value:      tx.Value(),
+		Data:       tx.Data(),
+		AccessList: tx.AccessList(),
+	}
+	if tx.Type() == types.DynamicFeeTxType {
+		msg.GasFeeCap = tx.GasFeeCap()
+		msg.GasTipCap = tx.GasTipCap()
+	} else {
+		msg.GasPrice = tx.GasPrice()
+	}
+
+	return s.Call(ctx, msg, overrides)
+}
+
+// Call simulates msg at the pending block with the overrides applied. A
+// revert is a Result, not an error; errors are for calls the node refused.
+func (s *Simulator) Call(ctx context.Context, msg ethereum.CallMsg, overrides Overrides) (*Result, error) {
+	arg := CallArg(msg)
+	params := []interface{}{arg, "pending"}
+	if len(overrides) > 0 {
+		params = append(params, overrides)
+	}
+
+	result := new(Result)
+	var out hexutil.Bytes
+	err := s.client.CallContext(ctx, &out, "eth_call", params...)
+	if err := s.reverts.FromError(err); err != nil {
+		if !errors.As(err, &result.Revert) {
+			return nil, err
+		}
+		result.ReturnData = result.Revert.Data
+	} else {
+		result.Success = true
+		result.ReturnData = out
+	}
+
+	if s.trace(ctx, arg, overrides, result) {
+		return result, nil
+	}
+	if !result.Success {
+		return result, nil
+	}
+
+	var gas hexutil.Uint64
+	if err := s.client.CallContext(ctx, &gas, "eth_estimateGas", params...); err != nil {
+		result.Warnings = append(result.Warnings, "gas not estimated: "+err.Error())
+	} else {
+		result.GasUsed, result.GasEstimated = uint64(gas), true
+	}
+
+	return result, nil
+}
+
+// trace fills the gas used and logs from debug_traceCall, it reports false
+// when the node cannot trace
+func (s *Simulator) trace(ctx context.Context, arg map[string]interface{}, overrides Overrides, result *Result) bool {
+	if atomic.LoadInt32(&s.noTrace) == 1 {
+		result.Warnings = append(result.Warnings, "tracing unavailable, logs unknown")
+		return false
+	}
+
+	config := map[string]interface{}{
+		"tracer":       "callTracer",
+		"tracerConfig": map[string]interface{}{"withLog": true},
+	}
+	if len(overrides) > 0 {
+		config["stateOverrides"] = overrides
+	}
+
+	var frame callFrame
+	if err := s.client.CallContext(ctx, &frame, "debug_traceCall", arg, "pending", config); err != nil {
+		var rpcErr rpc.Error
+		if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == -32601 {
+			atomic.StoreInt32(&s.noTrace, 1)
+		}
+		result.Warnings = append(result.Warnings, "tracing unavailable, logs unknown: "+err.Error())
+		return false
+	}
+
+	result.GasUsed = uint64(frame.GasUsed)
+	if !s.withLog(ctx) {
+		// an old geth ignores withLog, no logs would look like none emitted
+		result.Warnings = append(result.Warnings, "node ignores the callTracer withLog option, logs unknown")
+		return true
+	}
+	result.Traced = true
+	frame.collect(&result.Logs)
+	return true
+}
+
+// withLog reports whether the callTracer of the node returns logs, which
+// geth does from 1.11 on. Other clients and unknown versions are trusted
+func (s *Simulator) withLog(ctx context.Context) bool {
+	switch atomic.LoadInt32(&s.logs) {
+	case logsSupported:
+		return true
+	case logsUnsupported:
+		return false
+	}
+
+	var version string
+	if err := s.client.CallContext(ctx, &version, "web3_clientVersion"); err != nil {
+		return true
+	}
+	if oldGeth(version) {
+		atomic.StoreInt32(&s.logs, logsUnsupported)
+		return false
+	}
+	atomic.StoreInt32(&s.logs, logsSupported)
+	return true
+}
+
+// oldGeth reports whether version, as web3_clientVersion gives it, is a
+// geth before 1.11
+func oldGeth(version string) bool {
+	parts := strings.Split(version, "/")
+	if !strings.EqualFold(parts[0], "geth") {
+		return false
+	}
+	// a node started with --identity has its name before the version
+	for _, part := range parts[1:] {
+		if !strings.HasPrefix(part, "v") {
+			continue
+		}
+		numbers := strings.SplitN(part[1:], ".", 3)
+		if len(numbers) < 2 {
+			continue
+		}
+		major, err := strconv.Atoi(numbers[0])
+		if err != nil {
+			continue
+		}
+		minor, err := strconv.Atoi(numbers[1])
+		if err != nil {
+			continue
+		}
+		return major < 1 || major == 1 && minor < 11
+	}
+	return false
+}
+
+// callFrame is a frame of the callTracer output
+type callFrame struct {
+	GasUsed hexutil.Uint64 `json:"gasUsed"`
+	Error   string         `json:"error"`
+	Logs    []struct {
+		Address common.Address `json:"address"`
+		Topics  []common.Hash  `json:"topics"`
+		Data    hexutil.Bytes  `json:"data"`
+		// Position is the number of sub calls made before the log
+		Position hexutil.Uint `json:"position"`
+	} `json:"logs"`
+	Calls []callFrame `json:"calls"`
+}
+
+// collect appends the logs that survive in execution order, the logs of
+// reverted frames are dropped like the chain would
+func (f *callFrame) collect(logs *[]*types.Log) {
+	if f.Error != "" {
+		return
+	}
+
+	next := 0
+	for _, l := range f.Logs {
+		for ; next < int(l.Position) && next < len(f.Calls); next++ {
+			f.Calls[next].collect(logs)
+		}
+		*logs = append(*logs, &types.Log{Address: l.Address, Topics: l.Topics, Data: l.Data, Index: uint(len(*logs))})
+	}
+	for ; next < len(f.Calls); next++ {
+		f.Calls[next].collect(logs)
+	}
+}
+
+// CallArg is ethclient's JSON-RPC encoding of a call, with the EIP-1559 and
+// EIP-2930 fields it leaves out
+func CallArg(msg ethereum.CallMsg) map[string]interface{} {
+	arg := map[string]interface{}{
+		"from": msg.From,
+		"to":   msg.To,
+	}
+	if len(msg.Data) > 0 {
+		arg["data"] = hexutil.Bytes(msg.Data)
+	}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if msg.Gas != 0 {
+		arg["gas"] = hexutil.Uint64(msg.Gas)
+	}
+	if msg.GasPrice != nil {
+		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
+	}
+	if msg.GasFeeCap != nil {
+		arg["maxFeePerGas"] = (*hexutil.Big)(msg.GasFeeCap)
+	}
+	if msg.GasTipCap != nil {
+		arg["maxPriorityFeePerGas"] = (*hexutil.Big)(msg.GasTipCap)
+	}
+	if msg.AccessList != nil {
+		arg["accessList"] = msg.AccessList
+	}
+	return arg
+}
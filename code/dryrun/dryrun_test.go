@@ -0,0 +1,210 @@
+package dryrun
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	token  = common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	other  = common.HexToAddress("0x4592d8f8d7b001e72cb26a73e4fa1806a51ac79d")
+	topic  = common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+	ether1 = big.NewInt(1000000000000000000)
+)
+
+// revertError is how a node reports a revert
+type revertError struct {
+	data string
+}
+
+func (e revertError) Error() string          { return "execution reverted" }
+func (e revertError) ErrorCode() int         { return 3 }
+func (e revertError) ErrorData() interface{} { return e.data }
+
+// fakeEth succeeds when the sender has at least one ether, which only an
+// override gives it
+type fakeEth struct {
+	from common.Address
+}
+
+func (f *fakeEth) Call(args map[string]interface{}, block string, overrides *Overrides) (hexutil.Bytes, error) {
+	f.from = common.HexToAddress(args["from"].(string))
+	if block != "pending" {
+		return nil, revertError{"0x"}
+	}
+	if overrides != nil {
+		if account := (*overrides)[f.from]; account != nil && account.Balance.ToInt().Cmp(ether1) >= 0 {
+			return common.LeftPadBytes([]byte{1}, 32), nil
+		}
+	}
+
+	str, _ := abi.NewType("string", "", nil)
+	reason, _ := abi.Arguments{{Type: str}}.Pack("insufficient balance")
+	return nil, revertError{hexutil.Encode(append(hexutil.MustDecode("0x08c379a0"), reason...))}
+}
+
+func (f *fakeEth) EstimateGas(args map[string]interface{}, block string, overrides *Overrides) (hexutil.Uint64, error) {
+	return 30000, nil
+}
+
+type fakeDebug struct{}
+
+func (fakeDebug) TraceCall(args map[string]interface{}, block string, config map[string]interface{}) (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"gasUsed": "0x5208",
+		"logs": []map[string]interface{}{
+			{"address": token, "topics": []common.Hash{topic}, "data": "0x01", "position": "0x1"},
+		},
+		"calls": []map[string]interface{}{
+			{"logs": []map[string]interface{}{{"address": other, "topics": []common.Hash{}, "data": "0x", "position": "0x0"}}},
+			{"error": "execution reverted", "logs": []map[string]interface{}{{"address": other, "topics": []common.Hash{topic}, "data": "0x", "position": "0x0"}}},
+		},
+	}, nil
+}
+
+// fakeWeb3 reports the client version
+type fakeWeb3 struct {
+	version string
+}
+
+func (f fakeWeb3) ClientVersion() string { return f.version }
+
+func newClient(t *testing.T, tracing bool) (*rpc.Client, *fakeEth) {
+	eth := new(fakeEth)
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", eth); err != nil {
+		t.Fatal(err)
+	}
+	if tracing {
+		if err := server.RegisterName("debug", fakeDebug{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return rpc.DialInProc(server), eth
+}
+
+func TestCallWithOverrides(t *testing.T) {
+	t.Parallel()
+	client, _ := newClient(t, true)
+	defer client.Close()
+	sim := New(client, nil)
+
+	from := common.HexToAddress("0x96216849c49358B10257cb55b28eA603c874b05E")
+	msg := ethereum.CallMsg{From: from, To: &token, Data: []byte{1, 2, 3, 4}}
+
+	result, err := sim.Call(context.Background(), msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success || result.Revert == nil || result.Revert.Reason != "insufficient balance" {
+		t.Errorf("Expected the insufficient balance revert, got %+v", result)
+	}
+
+	result, err = sim.Call(context.Background(), msg, Overrides{}.SetBalance(from, ether1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || new(big.Int).SetBytes(result.ReturnData).Int64() != 1 {
+		t.Errorf("Expected success returning 1, got %+v", result)
+	}
+	if !result.Traced || result.GasEstimated || result.GasUsed != 21000 {
+		t.Errorf("Expected 21000 traced gas, got %v traced %v", result.GasUsed, result.Traced)
+	}
+	// the first sub call logs before the parent, the reverted one is dropped
+	if len(result.Logs) != 2 || result.Logs[0].Address != other || result.Logs[1].Address != token || result.Logs[1].Topics[0] != topic {
+		t.Errorf("Expected logs from %v then %v, got %v", other.Hex(), token.Hex(), result.Logs)
+	}
+}
+
+func TestCallWithoutTracing(t *testing.T) {
+	t.Parallel()
+	client, eth := newClient(t, false)
+	defer client.Close()
+	sim := New(client, nil)
+
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	tx, err := types.SignNewTx(key, types.NewLondonSigner(big.NewInt(1)), &types.DynamicFeeTx{
+		ChainID: big.NewInt(1), GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2), Gas: 50000, To: &token,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		result, err := sim.Transaction(context.Background(), tx, Overrides{}.SetBalance(from, ether1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if eth.from != from {
+			t.Errorf("Expected the call from %v, got %v", from.Hex(), eth.from.Hex())
+		}
+		if !result.Success || result.Traced || !result.GasEstimated || result.GasUsed != 30000 || len(result.Warnings) != 1 {
+			t.Errorf("Expected an untraced success with estimated gas, got %+v", result)
+		}
+	}
+	if sim.noTrace != 1 {
+		t.Error("Expected the missing debug_traceCall to be remembered")
+	}
+}
+
+func TestCallOldGeth(t *testing.T) {
+	t.Parallel()
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", new(fakeEth)); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterName("debug", fakeDebug{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterName("web3", fakeWeb3{"Geth/v1.10.26-stable-e5eb32ac/linux-amd64/go1.18.5"}); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+	sim := New(client, nil)
+
+	from := common.HexToAddress("0x96216849c49358B10257cb55b28eA603c874b05E")
+	msg := ethereum.CallMsg{From: from, To: &token}
+	result, err := sim.Call(context.Background(), msg, Overrides{}.SetBalance(from, ether1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || result.Traced || result.GasEstimated || result.GasUsed != 21000 || result.Logs != nil || len(result.Warnings) != 1 {
+		t.Errorf("Expected traced gas without logs and a warning, got %+v", result)
+	}
+	if sim.logs != logsUnsupported {
+		t.Error("Expected the ignored withLog to be remembered")
+	}
+}
+
+func TestOldGeth(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		version string
+		old     bool
+	}{
+		{"Geth/v1.10.26-stable-e5eb32ac/linux-amd64/go1.18.5", true},
+		{"Geth/v1.9.25-stable/linux-amd64/go1.15.6", true},
+		{"Geth/v1.11.0-stable/linux-amd64/go1.20", false},
+		{"Geth/v1.13.5-stable-916d6a44/linux-amd64/go1.21.4", false},
+		{"Geth/mynode/v1.10.26-stable/linux-amd64/go1.18.5", true},
+		{"erigon/2.48.1/linux-amd64/go1.20.5", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if old := oldGeth(test.version); old != test.old {
+			t.Errorf("Expected %v for %q, got %v", test.old, test.version, old)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+
+	"ethereum-development-with-go/code/indexer"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+func main() {
+	client, err := ethclient.Dial("https://cloudflare-eth.com")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := indexer.OpenStore("./logs.db")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+
+	// 0x Protocol (ZRX) token address
+	contractAddress := common.HexToAddress("0xe41d2489571d322189246dafa5ebde1f4699f498")
+	ix, err := indexer.New(client, store, "zrx", indexer.Config{
+		Query: ethereum.FilterQuery{
+			Addresses: []common.Address{contractAddress},
+		},
+		ChunkSize: 5000,
+		OnProgress: func(from, to uint64, logs int) {
+			fmt.Printf("indexed %d-%d: %d logs\n", from, to, logs)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// ranges are split automatically when the provider returns too many results,
+	// and re-running the program resumes from the last stored checkpoint
+	if err := ix.Backfill(context.Background(), 14500000, 14582340); err != nil {
+		log.Fatal(err)
+	}
+
+	logTransferSigHash := crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+	logs, err := ix.Logs(ethereum.FilterQuery{
+		FromBlock: big.NewInt(14582330),
+		ToBlock:   big.NewInt(14582340),
+		Topics:    [][]common.Hash{{logTransferSigHash}},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, vLog := range logs {
+		fmt.Println(vLog.BlockNumber, vLog.TxHash.Hex())
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	token "ethereum-development-with-go/code/contracts_erc20" // for demo
+	"ethereum-development-with-go/code/events"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// LogTransfer ..
+type LogTransfer struct {
+	From   common.Address
+	To     common.Address
+	Tokens *big.Int
+}
+
+func main() {
+	client, err := ethclient.Dial("https://cloudflare-eth.com")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// 0x Protocol (ZRX) token address
+	contractAddress := common.HexToAddress("0xe41d2489571d322189246dafa5ebde1f4699f498")
+	query := ethereum.FilterQuery{
+		FromBlock: big.NewInt(14582330),
+		ToBlock:   big.NewInt(14582340),
+		Addresses: []common.Address{
+			contractAddress,
+		},
+	}
+
+	logs, err := client.FilterLogs(context.Background(), query)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	contractAbi, err := abi.JSON(strings.NewReader(token.TokenABI))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// no topic hashes or Topics[1..3] copying: the decoder works them out from the ABI
+	decoder := events.NewDecoder(contractAbi)
+
+	for _, vLog := range logs {
+		event, err := decoder.Decode(vLog)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("Log Name: %s\n", event.Name)           // Log Name: Transfer
+		fmt.Printf("Log Signature: %s\n", event.Signature) // Log Signature: Transfer(address,address,uint256)
+		for name, value := range event.Args {
+			fmt.Printf("%s: %v\n", name, value)
+		}
+
+		if event.Name == "Transfer" {
+			var transfer LogTransfer
+			if err := decoder.DecodeIntoAs(&transfer, "Transfer", vLog); err != nil {
+				log.Fatal(err)
+			}
+
+			fmt.Printf("From: %s\n", transfer.From.Hex())
+			fmt.Printf("To: %s\n", transfer.To.Hex())
+			fmt.Printf("Tokens: %s\n", transfer.Tokens.String())
+		}
+
+		fmt.Printf("\n\n")
+	}
+}
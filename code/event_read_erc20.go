@@ -39,8 +39,8 @@ func main() {
 	// 0x Protocol (ZRX) token address
 	contractAddress := common.HexToAddress("0xe41d2489571d322189246dafa5ebde1f4699f498")
 	query := ethereum.FilterQuery{
-		FromBlock: big.NewInt(14582340),
-		ToBlock:   big.NewInt(14582330),
+		FromBlock: big.NewInt(14582330),
+		ToBlock:   big.NewInt(14582340),
 		Addresses: []common.Address{
 			contractAddress,
 		},
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"ethereum-development-with-go/code/subscriber"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func main() {
+	// an https:// endpoint works too, logs are then polled with eth_getFilterChanges
+	dial := subscriber.Dial("wss://rinkeby.infura.io/ws/v3/**********")
+
+	//Star Coin
+	contractAddress := common.HexToAddress("0x9b8f68d305daef003632fec0df1be20e0b23be23")
+	query := ethereum.FilterQuery{
+		Addresses: []common.Address{contractAddress},
+	}
+
+	sub := subscriber.New(dial, query, subscriber.Config{
+		OnError: func(err error) {
+			log.Println("reconnecting:", err)
+		},
+	})
+
+	events := make(chan subscriber.Event)
+	go func() {
+		log.Fatal(sub.Run(context.Background(), events))
+	}()
+
+	for ev := range events {
+		if ev.Removed {
+			fmt.Println("removed by reorg:", ev.Log.TxHash.Hex(), ev.Log.Index)
+			continue
+		}
+		fmt.Println(ev.Log.BlockNumber, ev.Log.TxHash.Hex(), ev.Log.Index, ev.Backfilled)
+	}
+}
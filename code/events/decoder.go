@@ -0,0 +1,336 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+/*
+ * Decode any contract event log given the contract ABI.
+ */
+
+var (
+	// ErrUnknownEvent is returned when a log does not match any event in the ABI
+	ErrUnknownEvent = errors.New("unknown event")
+	// ErrAmbiguousEvent is returned when an anonymous log matches more than one event
+	ErrAmbiguousEvent = errors.New("ambiguous anonymous event")
+)
+
+// Event is a decoded event log
+type Event struct {
+	Name      string
+	Signature string
+	Anonymous bool
+	// Args holds every argument by name. Indexed dynamic values (string, bytes,
+	// arrays and tuples) are only available as their keccak256 common.Hash.
+	Args map[string]interface{}
+	Log  types.Log
+}
+
+// Decoder decodes logs against a parsed contract ABI
+type Decoder struct {
+	abi       abi.ABI
+	byTopic   map[common.Hash]abi.Event
+	anonymous []abi.Event
+}
+
+// NewDecoder returns a decoder for the events of a contract ABI
+func NewDecoder(contractAbi abi.ABI) *Decoder {
+	d := &Decoder{
+		abi:     contractAbi,
+		byTopic: make(map[common.Hash]abi.Event),
+	}
+	for _, event := range contractAbi.Events {
+		if event.Anonymous {
+			d.anonymous = append(d.anonymous, event)
+			continue
+		}
+		d.byTopic[event.ID] = event
+	}
+
+	return d
+}
+
+// EventFor returns the ABI event that emitted a log. Named events are matched
+// on Topics[0]; anonymous events are matched on their indexed argument count
+// and a successful decode of the log data.
+func (d *Decoder) EventFor(vLog types.Log) (abi.Event, error) {
+	if len(vLog.Topics) > 0 {
+		if event, ok := d.byTopic[vLog.Topics[0]]; ok {
+			return event, nil
+		}
+	}
+
+	var matches []abi.Event
+	for _, event := range d.anonymous {
+		if countIndexed(event.Inputs) != len(vLog.Topics) {
+			continue
+		}
+		if _, err := event.Inputs.NonIndexed().Unpack(vLog.Data); err != nil {
+			continue
+		}
+		matches = append(matches, event)
+	}
+
+	switch len(matches) {
+	case 0:
+		if len(vLog.Topics) == 0 {
+			return abi.Event{}, ErrUnknownEvent
+		}
+		return abi.Event{}, fmt.Errorf("%w: topic %s", ErrUnknownEvent, vLog.Topics[0].Hex())
+	case 1:
+		return matches[0], nil
+	default:
+		return abi.Event{}, fmt.Errorf("%w: %d candidates, use DecodeAs", ErrAmbiguousEvent, len(matches))
+	}
+}
+
+// Decode matches a log to its event and decodes all arguments into a map
+func (d *Decoder) Decode(vLog types.Log) (*Event, error) {
+	event, err := d.EventFor(vLog)
+	if err != nil {
+		return nil, err
+	}
+
+	return decode(event, vLog)
+}
+
+// DecodeAs decodes a log as the named event, which is required for
+// anonymous events that share the same shape
+func (d *Decoder) DecodeAs(name string, vLog types.Log) (*Event, error) {
+	event, ok := d.abi.Events[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEvent, name)
+	}
+
+	return decode(event, vLog)
+}
+
+// DecodeInto decodes a log into a caller supplied struct pointer. Fields are
+// matched by the camel-cased argument name or an `abi:"name"` tag, and fields
+// with no matching argument are left untouched.
+func (d *Decoder) DecodeInto(out interface{}, vLog types.Log) (string, error) {
+	event, err := d.EventFor(vLog)
+	if err != nil {
+		return "", err
+	}
+
+	return event.Name, decodeInto(out, event, vLog)
+}
+
+// DecodeIntoAs decodes a log as the named event into a caller supplied struct pointer
+func (d *Decoder) DecodeIntoAs(out interface{}, name string, vLog types.Log) error {
+	event, ok := d.abi.Events[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownEvent, name)
+	}
+
+	return decodeInto(out, event, vLog)
+}
+
+// decode decodes both the topics and data of a log into a map
+func decode(event abi.Event, vLog types.Log) (*Event, error) {
+	indexed, topics, err := splitTopics(event, vLog)
+	if err != nil {
+		return nil, err
+	}
+
+	args := make(map[string]interface{}, len(event.Inputs))
+	if err := event.Inputs.NonIndexed().UnpackIntoMap(args, vLog.Data); err != nil {
+		return nil, err
+	}
+
+	var parsable abi.Arguments
+	var parsableTopics []common.Hash
+	for i, arg := range indexed {
+		if isHashed(arg.Type) {
+			args[arg.Name] = topics[i]
+			continue
+		}
+		parsable = append(parsable, arg)
+		parsableTopics = append(parsableTopics, topics[i])
+	}
+	if err := abi.ParseTopicsIntoMap(args, parsable, parsableTopics); err != nil {
+		return nil, err
+	}
+
+	return &Event{
+		Name:      event.Name,
+		Signature: event.Sig,
+		Anonymous: event.Anonymous,
+		Args:      args,
+		Log:       vLog,
+	}, nil
+}
+
+// decodeInto decodes both the topics and data of a log into a struct
+func decodeInto(out interface{}, event abi.Event, vLog types.Log) error {
+	value := reflect.ValueOf(out)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("events: cannot decode into %T, need a struct pointer", out)
+	}
+	value = value.Elem()
+
+	indexed, topics, err := splitTopics(event, vLog)
+	if err != nil {
+		return err
+	}
+
+	nonIndexed := event.Inputs.NonIndexed()
+	values, err := nonIndexed.Unpack(vLog.Data)
+	if err != nil {
+		return err
+	}
+	for i, arg := range nonIndexed {
+		if err := setField(value, arg.Name, values[i]); err != nil {
+			return err
+		}
+	}
+
+	// abi.ParseTopics panics on missing or mistyped fields, so only hand it
+	// the topics that have a compatible camel-cased destination and route
+	// the rest through a map
+	var parsable, tagged abi.Arguments
+	var parsableTopics, taggedTopics []common.Hash
+	for i, arg := range indexed {
+		if isHashed(arg.Type) {
+			if err := setField(value, arg.Name, topics[i]); err != nil {
+				return err
+			}
+			continue
+		}
+		field := value.FieldByName(abi.ToCamelCase(arg.Name))
+		if field.IsValid() && !hasTag(value.Type(), arg.Name) {
+			if !field.CanSet() || !topicType(arg.Type).AssignableTo(field.Type()) {
+				return fmt.Errorf("events: field %s has type %s, want %s", abi.ToCamelCase(arg.Name), field.Type(), topicType(arg.Type))
+			}
+			parsable = append(parsable, arg)
+			parsableTopics = append(parsableTopics, topics[i])
+			continue
+		}
+		tagged = append(tagged, arg)
+		taggedTopics = append(taggedTopics, topics[i])
+	}
+	if err := abi.ParseTopics(out, parsable, parsableTopics); err != nil {
+		return err
+	}
+
+	args := make(map[string]interface{}, len(tagged))
+	if err := abi.ParseTopicsIntoMap(args, tagged, taggedTopics); err != nil {
+		return err
+	}
+	for _, arg := range tagged {
+		if err := setField(value, arg.Name, args[arg.Name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// splitTopics returns the indexed arguments of an event together with the
+// topics that hold their values, skipping the signature topic
+func splitTopics(event abi.Event, vLog types.Log) (abi.Arguments, []common.Hash, error) {
+	var indexed abi.Arguments
+	for _, arg := range event.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+
+	topics := vLog.Topics
+	if !event.Anonymous {
+		if len(topics) == 0 || topics[0] != event.ID {
+			return nil, nil, fmt.Errorf("events: log is not a %s event", event.Name)
+		}
+		topics = topics[1:]
+	}
+	if len(topics) != len(indexed) {
+		return nil, nil, fmt.Errorf("events: %s has %d indexed arguments, log has %d topics", event.Name, len(indexed), len(topics))
+	}
+
+	return indexed, topics, nil
+}
+
+// setField assigns a decoded value to the struct field for an argument name,
+// ignoring arguments the struct has no field for
+func setField(value reflect.Value, name string, v interface{}) error {
+	field := fieldFor(value, name)
+	if !field.IsValid() {
+		return nil
+	}
+	if !field.CanSet() {
+		return fmt.Errorf("events: field for %s cannot be set", name)
+	}
+
+	src := reflect.ValueOf(v)
+	switch {
+	case src.Type().AssignableTo(field.Type()):
+		field.Set(src)
+	case src.Type().ConvertibleTo(field.Type()) && src.Kind() == field.Kind():
+		field.Set(src.Convert(field.Type()))
+	default:
+		return fmt.Errorf("events: field for %s has type %s, want %s", name, field.Type(), src.Type())
+	}
+
+	return nil
+}
+
+// fieldFor finds the struct field for an argument by abi tag or camel-cased name
+func fieldFor(value reflect.Value, name string) reflect.Value {
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		if typ.Field(i).Tag.Get("abi") == name {
+			return value.Field(i)
+		}
+	}
+
+	return value.FieldByName(abi.ToCamelCase(name))
+}
+
+// hasTag reports whether a struct has a field tagged with an argument name
+func hasTag(typ reflect.Type, name string) bool {
+	for i := 0; i < typ.NumField(); i++ {
+		if typ.Field(i).Tag.Get("abi") == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isHashed reports whether an indexed argument is stored as its keccak256 hash
+func isHashed(typ abi.Type) bool {
+	switch typ.T {
+	case abi.StringTy, abi.BytesTy, abi.SliceTy, abi.ArrayTy, abi.TupleTy:
+		return true
+	}
+
+	return false
+}
+
+// topicType returns the Go type abi.ParseTopics produces for an indexed argument
+func topicType(typ abi.Type) reflect.Type {
+	if typ.T == abi.FunctionTy {
+		return reflect.TypeOf([24]byte{})
+	}
+
+	return typ.GetType()
+}
+
+// countIndexed returns the number of indexed arguments
+func countIndexed(args abi.Arguments) int {
+	n := 0
+	for _, arg := range args {
+		if arg.Indexed {
+			n++
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,195 @@
+package events
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	token "ethereum-development-with-go/code/contracts_erc20"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const testABI = `[
+	{"anonymous":false,"inputs":[{"indexed":true,"name":"name","type":"string"},{"indexed":true,"name":"id","type":"bytes32"},{"indexed":false,"name":"values","type":"uint256[]"}],"name":"Registered","type":"event"},
+	{"anonymous":true,"inputs":[{"indexed":true,"name":"who","type":"address"},{"indexed":false,"name":"count","type":"uint256"}],"name":"Ping","type":"event"},
+	{"anonymous":true,"inputs":[{"indexed":true,"name":"who","type":"address"},{"indexed":true,"name":"to","type":"address"}],"name":"Pong","type":"event"}
+]`
+
+var (
+	from = common.HexToAddress("0x96216849c49358B10257cb55b28eA603c874b05E")
+	to   = common.HexToAddress("0x4592d8f8d7b001e72cb26a73e4fa1806a51ac79d")
+)
+
+func mustABI(t *testing.T, raw string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return parsed
+}
+
+func transferLog(t *testing.T, parsed abi.ABI) types.Log {
+	data, err := parsed.Events["Transfer"].Inputs.NonIndexed().Pack(big.NewInt(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return types.Log{
+		Topics: []common.Hash{
+			parsed.Events["Transfer"].ID,
+			common.BytesToHash(from.Bytes()),
+			common.BytesToHash(to.Bytes()),
+		},
+		Data: data,
+	}
+}
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+	parsed := mustABI(t, token.TokenABI)
+	decoder := NewDecoder(parsed)
+
+	event, err := decoder.Decode(transferLog(t, parsed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Name != "Transfer" {
+		t.Errorf("Expected %v, got %v", "Transfer", event.Name)
+	}
+	if event.Signature != "Transfer(address,address,uint256)" {
+		t.Errorf("Expected %v, got %v", "Transfer(address,address,uint256)", event.Signature)
+	}
+	if event.Args["from"] != from {
+		t.Errorf("Expected %v, got %v", from, event.Args["from"])
+	}
+	if event.Args["to"] != to {
+		t.Errorf("Expected %v, got %v", to, event.Args["to"])
+	}
+	if event.Args["tokens"].(*big.Int).Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("Expected %v, got %v", 1000, event.Args["tokens"])
+	}
+}
+
+func TestDecodeInto(t *testing.T) {
+	t.Parallel()
+	parsed := mustABI(t, token.TokenABI)
+	decoder := NewDecoder(parsed)
+
+	var transfer struct {
+		From   common.Address
+		To     common.Address
+		Amount *big.Int `abi:"tokens"`
+	}
+	name, err := decoder.DecodeInto(&transfer, transferLog(t, parsed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "Transfer" {
+		t.Errorf("Expected %v, got %v", "Transfer", name)
+	}
+	if transfer.From != from || transfer.To != to {
+		t.Errorf("Expected %v -> %v, got %v -> %v", from, to, transfer.From, transfer.To)
+	}
+	if transfer.Amount.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("Expected %v, got %v", 1000, transfer.Amount)
+	}
+
+	var wrong struct {
+		From string
+	}
+	if _, err := decoder.DecodeInto(&wrong, transferLog(t, parsed)); err == nil {
+		t.Error("Expected a type mismatch error")
+	}
+}
+
+func TestDecodeHashedIndexed(t *testing.T) {
+	t.Parallel()
+	parsed := mustABI(t, testABI)
+	decoder := NewDecoder(parsed)
+
+	id := crypto.Keccak256Hash([]byte("id"))
+	data, err := parsed.Events["Registered"].Inputs.NonIndexed().Pack([]*big.Int{big.NewInt(1), big.NewInt(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vLog := types.Log{
+		Topics: []common.Hash{
+			parsed.Events["Registered"].ID,
+			crypto.Keccak256Hash([]byte("alice")),
+			id,
+		},
+		Data: data,
+	}
+
+	event, err := decoder.Decode(vLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Args["name"] != crypto.Keccak256Hash([]byte("alice")) {
+		t.Errorf("Expected hashed name, got %v", event.Args["name"])
+	}
+	if event.Args["id"] != [32]byte(id) {
+		t.Errorf("Expected %v, got %v", id, event.Args["id"])
+	}
+	if len(event.Args["values"].([]*big.Int)) != 2 {
+		t.Errorf("Expected 2 values, got %v", event.Args["values"])
+	}
+
+	var registered struct {
+		Name common.Hash
+		Id   [32]byte
+	}
+	if _, err := decoder.DecodeInto(&registered, vLog); err != nil {
+		t.Fatal(err)
+	}
+	if registered.Name != crypto.Keccak256Hash([]byte("alice")) || registered.Id != [32]byte(id) {
+		t.Errorf("Unexpected struct %+v", registered)
+	}
+}
+
+func TestDecodeAnonymous(t *testing.T) {
+	t.Parallel()
+	parsed := mustABI(t, testABI)
+	decoder := NewDecoder(parsed)
+
+	data, err := parsed.Events["Ping"].Inputs.NonIndexed().Pack(big.NewInt(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ping := types.Log{
+		Topics: []common.Hash{common.BytesToHash(from.Bytes())},
+		Data:   data,
+	}
+
+	event, err := decoder.Decode(ping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Name != "Ping" || !event.Anonymous {
+		t.Errorf("Expected anonymous Ping, got %v", event.Name)
+	}
+	if event.Args["who"] != from {
+		t.Errorf("Expected %v, got %v", from, event.Args["who"])
+	}
+
+	pong := types.Log{
+		Topics: []common.Hash{common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+	}
+	event, err = decoder.Decode(pong)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Name != "Pong" || event.Args["to"] != to {
+		t.Errorf("Expected Pong to %v, got %v %v", to, event.Name, event.Args["to"])
+	}
+
+	unknown := types.Log{Topics: []common.Hash{crypto.Keccak256Hash([]byte("Nope()"))}, Data: []byte{1}}
+	if _, err := decoder.Decode(unknown); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("Expected %v, got %v", ErrUnknownEvent, err)
+	}
+}
@@ -0,0 +1,234 @@
+package follower
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+/*
+ * Follow the canonical chain and report reorgs.
+ */
+
+// DefaultWindowSize is the number of recent headers kept when Config.WindowSize is zero
+const DefaultWindowSize = 128
+
+var (
+	// ErrReorgTooDeep is returned when a new head does not connect to any header in the window
+	ErrReorgTooDeep = errors.New("follower: reorg deeper than the header window")
+	// ErrFinalizedReorg is returned when a new head would revert a finalized block
+	ErrFinalizedReorg = errors.New("follower: reorg reverts a finalized block")
+)
+
+// Backend is the part of a node client the follower needs
+type Backend interface {
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
+}
+
+// Config configures a Follower
+type Config struct {
+	// WindowSize is the number of recent headers kept to find common ancestors,
+	// it is raised to FinalityDepth+1 when smaller
+	WindowSize int
+	// FinalityDepth is the number of confirmations after which a block is
+	// reported finalized and may no longer be reverted, zero disables it
+	FinalityDepth uint64
+}
+
+// Update describes how the canonical chain changed with a new head
+type Update struct {
+	// Ancestor is the last header kept from the previous chain, the common
+	// ancestor of both branches on a reorg. It is nil for the first head.
+	Ancestor *types.Header
+	// Reverted holds the headers that left the canonical chain, newest first
+	Reverted []*types.Header
+	// Added holds the new canonical headers, oldest first
+	Added []*types.Header
+	// Finalized holds the headers that reached the finality depth, oldest first
+	Finalized []*types.Header
+}
+
+// Reorg reports whether the update reverted blocks
+func (u *Update) Reorg() bool {
+	return len(u.Reverted) > 0
+}
+
+// Empty reports whether nothing changed
+func (u *Update) Empty() bool {
+	return len(u.Reverted) == 0 && len(u.Added) == 0 && len(u.Finalized) == 0
+}
+
+// Follower keeps a window of the most recent canonical headers, linked by
+// parent hash
+type Follower struct {
+	backend Backend
+	cfg     Config
+
+	// window is the canonical chain tail, oldest first
+	window []*types.Header
+	// finalized is the next block number to report finalized
+	finalized uint64
+}
+
+// New returns a follower with an empty window, the first head processed
+// becomes its starting point
+func New(backend Backend, cfg Config) *Follower {
+	if cfg.WindowSize == 0 {
+		cfg.WindowSize = DefaultWindowSize
+	}
+	if uint64(cfg.WindowSize) <= cfg.FinalityDepth {
+		cfg.WindowSize = int(cfg.FinalityDepth) + 1
+	}
+
+	return &Follower{
+		backend: backend,
+		cfg:     cfg,
+	}
+}
+
+// Head returns the current canonical head, nil before the first update
+func (f *Follower) Head() *types.Header {
+	if len(f.window) == 0 {
+		return nil
+	}
+	return f.window[len(f.window)-1]
+}
+
+// Run follows new heads until ctx is cancelled or the subscription fails.
+// The current head is processed first. Run may be called again after an
+// error, the window is kept.
+func (f *Follower) Run(ctx context.Context, updates chan<- *Update) error {
+	heads := make(chan *types.Header, 16)
+	sub, err := f.backend.SubscribeNewHead(ctx, heads)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	head, err := f.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for {
+		update, err := f.Process(ctx, head)
+		if err != nil {
+			return err
+		}
+		if !update.Empty() {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case updates <- update:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-sub.Err():
+			if err == nil {
+				err = errors.New("follower: subscription closed")
+			}
+			return err
+		case head = <-heads:
+		}
+	}
+}
+
+// Process applies a new head. Missing ancestors are fetched by parent hash
+// until the branch connects to the window, so skipped heads and reorgs are
+// both reported. A head already in the window, at its tip or not, is stale
+// and gives an empty update; only a hash off the window starts a branch.
+func (f *Follower) Process(ctx context.Context, head *types.Header) (*Update, error) {
+	update := new(Update)
+	if len(f.window) == 0 {
+		f.window = []*types.Header{head}
+		update.Added = []*types.Header{head}
+		f.finalize(update)
+		return update, nil
+	}
+
+	// a head already followed is stale, from a lagging provider for
+	// instance, only a hash off the window means another branch
+	if f.indexOf(head.Hash()) >= 0 {
+		return update, nil
+	}
+
+	// ancestor is the window index the new branch attaches to
+	var ancestor int
+	branch := []*types.Header{head}
+	for {
+		oldest := branch[len(branch)-1]
+		if ancestor = f.indexOf(oldest.ParentHash); ancestor >= 0 {
+			break
+		}
+		if oldest.Number.Cmp(f.window[0].Number) <= 0 {
+			return nil, fmt.Errorf("%w: %s at %v", ErrReorgTooDeep, head.Hash().Hex(), head.Number)
+		}
+		parent, err := f.backend.HeaderByHash(ctx, oldest.ParentHash)
+		if err != nil {
+			return nil, fmt.Errorf("follower: fetching %s: %w", oldest.ParentHash.Hex(), err)
+		}
+		branch = append(branch, parent)
+	}
+
+	for i := len(f.window) - 1; i > ancestor; i-- {
+		if f.cfg.FinalityDepth > 0 && f.window[i].Number.Uint64() < f.finalized {
+			return nil, fmt.Errorf("%w: block %v", ErrFinalizedReorg, f.window[i].Number)
+		}
+		update.Reverted = append(update.Reverted, f.window[i])
+	}
+	for i := len(branch) - 1; i >= 0; i-- {
+		update.Added = append(update.Added, branch[i])
+	}
+	update.Ancestor = f.window[ancestor]
+
+	window := append(f.window[:ancestor+1:ancestor+1], update.Added...)
+	if len(window) > f.cfg.WindowSize {
+		window = window[len(window)-f.cfg.WindowSize:]
+	}
+	f.window = window
+	f.finalize(update)
+
+	return update, nil
+}
+
+// finalize adds the headers that reached the finality depth to update
+func (f *Follower) finalize(update *Update) {
+	if f.cfg.FinalityDepth == 0 {
+		return
+	}
+	tip := f.Head().Number.Uint64()
+	if tip < f.cfg.FinalityDepth {
+		return
+	}
+	final := tip - f.cfg.FinalityDepth
+
+	for _, header := range f.window {
+		number := header.Number.Uint64()
+		if number >= f.finalized && number <= final {
+			update.Finalized = append(update.Finalized, header)
+		}
+	}
+	if final+1 > f.finalized {
+		f.finalized = final + 1
+	}
+}
+
+// indexOf returns the window position of hash or -1
+func (f *Follower) indexOf(hash common.Hash) int {
+	for i := len(f.window) - 1; i >= 0; i-- {
+		if f.window[i].Hash() == hash {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,201 @@
+package follower
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func newBackend(t *testing.T) (*backends.SimulatedBackend, *ecdsa.PrivateKey) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := backends.NewSimulatedBackend(core.GenesisAlloc{
+		crypto.PubkeyToAddress(key.PublicKey): {Balance: big.NewInt(1000000000000000000)},
+	}, 8000000)
+
+	return client, key
+}
+
+// mine commits n blocks and returns the last header
+func mine(t *testing.T, client *backends.SimulatedBackend, n int) *types.Header {
+	for i := 0; i < n; i++ {
+		client.Commit()
+	}
+	head, err := client.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return head
+}
+
+// fork mines n blocks on top of parent, the first one holding a transfer so
+// its hash differs from the block it replaces
+func fork(t *testing.T, client *backends.SimulatedBackend, key *ecdsa.PrivateKey, parent common.Hash, n int) *types.Header {
+	ctx := context.Background()
+	if err := client.Fork(ctx, parent); err != nil {
+		t.Fatal(err)
+	}
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	nonce, err := client.PendingNonceAt(ctx, from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{1}, big.NewInt(1), 21000, gasPrice, nil), types.LatestSignerForChainID(big.NewInt(1337)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SendTransaction(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+
+	return mine(t, client, n)
+}
+
+func numbers(headers []*types.Header) []uint64 {
+	out := make([]uint64, len(headers))
+	for i, header := range headers {
+		out[i] = header.Number.Uint64()
+	}
+	return out
+}
+
+func equal(a []uint64, b ...uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestProcessReorg(t *testing.T) {
+	t.Parallel()
+	client, key := newBackend(t)
+	defer client.Close()
+	ctx := context.Background()
+
+	f := New(client, Config{FinalityDepth: 3})
+
+	update, err := f.Process(ctx, mine(t, client, 3))
+	if err != nil || !equal(numbers(update.Added), 3) || update.Ancestor != nil {
+		t.Fatalf("Expected block 3 added, got %v %v", update, err)
+	}
+	block4 := mine(t, client, 1)
+	if update, err = f.Process(ctx, block4); err != nil || !equal(numbers(update.Added), 4) {
+		t.Fatalf("Expected block 4 added, got %v %v", update, err)
+	}
+
+	// heads 5 and 6 are skipped, they are fetched by parent hash
+	update, err = f.Process(ctx, mine(t, client, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(numbers(update.Added), 5, 6) || update.Ancestor.Hash() != block4.Hash() {
+		t.Errorf("Expected blocks 5 and 6 added after 4, got %v after %v", numbers(update.Added), update.Ancestor.Number)
+	}
+	if !equal(numbers(update.Finalized), 3) {
+		t.Errorf("Expected block 3 finalized, got %v", numbers(update.Finalized))
+	}
+	old6 := update.Added[1]
+
+	head := fork(t, client, key, block4.Hash(), 3)
+	update, err = f.Process(ctx, head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !update.Reorg() || !equal(numbers(update.Reverted), 6, 5) || update.Reverted[0].Hash() != old6.Hash() {
+		t.Errorf("Expected blocks 6 and 5 reverted, got %v", numbers(update.Reverted))
+	}
+	if !equal(numbers(update.Added), 5, 6, 7) || update.Ancestor.Hash() != block4.Hash() {
+		t.Errorf("Expected blocks 5 to 7 added after 4, got %v after %v", numbers(update.Added), update.Ancestor.Number)
+	}
+	if !equal(numbers(update.Finalized), 4) {
+		t.Errorf("Expected block 4 finalized, got %v", numbers(update.Finalized))
+	}
+	if f.Head().Hash() != head.Hash() {
+		t.Errorf("Expected head %v, got %v", head.Hash().Hex(), f.Head().Hash().Hex())
+	}
+
+	// replaying the same head is a no-op
+	if update, err = f.Process(ctx, head); err != nil || !update.Empty() {
+		t.Errorf("Expected an empty update, got %v %v", update, err)
+	}
+	// and so is an older head of the same branch, as a lagging provider gives
+	stale, err := client.HeaderByNumber(ctx, big.NewInt(6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update, err = f.Process(ctx, stale); err != nil || !update.Empty() || f.Head().Hash() != head.Hash() {
+		t.Errorf("Expected a stale head ignored, got %v %v", update, err)
+	}
+
+	// a reorg below block 5 would revert the finalized block 4
+	if _, err = f.Process(ctx, fork(t, client, key, block4.ParentHash, 6)); !errors.Is(err, ErrFinalizedReorg) {
+		t.Errorf("Expected %v, got %v", ErrFinalizedReorg, err)
+	}
+}
+
+func TestProcessTooDeep(t *testing.T) {
+	t.Parallel()
+	client, key := newBackend(t)
+	defer client.Close()
+	ctx := context.Background()
+
+	genesis := mine(t, client, 0)
+	f := New(client, Config{WindowSize: 2})
+	if _, err := f.Process(ctx, mine(t, client, 4)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Process(ctx, mine(t, client, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Process(ctx, fork(t, client, key, genesis.Hash(), 6)); !errors.Is(err, ErrReorgTooDeep) {
+		t.Errorf("Expected %v, got %v", ErrReorgTooDeep, err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+	client, _ := newBackend(t)
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f := New(client, Config{})
+	updates := make(chan *Update)
+	go f.Run(ctx, updates)
+
+	for want := uint64(0); want < 3; want++ {
+		select {
+		case update := <-updates:
+			if !equal(numbers(update.Added), want) {
+				t.Fatalf("Expected block %v added, got %v", want, numbers(update.Added))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected an update")
+		}
+		client.Commit()
+	}
+}
@@ -0,0 +1,158 @@
+package hdwallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+/*
+ * Derive keys from a BIP-39 mnemonic along BIP-32 paths.
+ */
+
+var (
+	// ErrInvalidMnemonic is returned for a mnemonic with a word count BIP-39
+	// does not allow, a word off the English list or a wrong checksum
+	ErrInvalidMnemonic = errors.New("hdwallet: invalid mnemonic")
+	// ErrInvalidKey is returned in the rare case a derivation step yields an invalid key
+	ErrInvalidKey = errors.New("hdwallet: derived key is invalid")
+
+	curveOrder = crypto.S256().Params().N
+)
+
+// Wallet is a BIP-32 master key
+type Wallet struct {
+	key       *big.Int
+	chainCode []byte
+}
+
+// Seed returns the BIP-39 seed of a mnemonic. Any text gives a seed, use
+// CheckMnemonic or NewFromMnemonic to catch typos
+func Seed(mnemonic, passphrase string) []byte {
+	mnemonic = norm.NFKD.String(strings.Join(strings.Fields(mnemonic), " "))
+	salt := norm.NFKD.String("mnemonic" + passphrase)
+
+	return pbkdf2.Key([]byte(mnemonic), []byte(salt), 2048, 64, sha512.New)
+}
+
+// CheckMnemonic verifies that mnemonic is 12 to 24 words of the English list
+// whose last bits are the checksum of the entropy the others encode
+func CheckMnemonic(mnemonic string) error {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return fmt.Errorf("%w: %d words", ErrInvalidMnemonic, len(words))
+	}
+
+	// every word holds 11 bits, one bit in 33 is checksum
+	bits := new(big.Int)
+	for _, word := range words {
+		value, ok := wordIndex[word]
+		if !ok {
+			return fmt.Errorf("%w: %q is not a BIP-39 word", ErrInvalidMnemonic, word)
+		}
+		bits.Lsh(bits, 11)
+		bits.Or(bits, big.NewInt(int64(value)))
+	}
+	checksumBits := uint(len(words) * 11 / 33)
+	checksum := new(big.Int).And(bits, big.NewInt(1<<checksumBits-1))
+	entropy := math.PaddedBigBytes(bits.Rsh(bits, checksumBits), int(checksumBits)*4)
+
+	hash := sha256.Sum256(entropy)
+	if uint64(hash[0]>>(8-checksumBits)) != checksum.Uint64() {
+		return fmt.Errorf("%w: checksum mismatch", ErrInvalidMnemonic)
+	}
+	return nil
+}
+
+// NewFromMnemonic returns the wallet of a 12 to 24 word mnemonic, checked
+// with CheckMnemonic
+func NewFromMnemonic(mnemonic, passphrase string) (*Wallet, error) {
+	if err := CheckMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+
+	return NewFromSeed(Seed(mnemonic, passphrase))
+}
+
+// NewFromSeed returns the wallet of a BIP-32 seed
+func NewFromSeed(seed []byte) (*Wallet, error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	key := new(big.Int).SetBytes(sum[:32])
+	if key.Sign() == 0 || key.Cmp(curveOrder) >= 0 {
+		return nil, ErrInvalidKey
+	}
+
+	return &Wallet{key: key, chainCode: sum[32:]}, nil
+}
+
+// Derive returns the private key at path, e.g. accounts.DefaultBaseDerivationPath
+func (w *Wallet) Derive(path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	key, chainCode := w.key, w.chainCode
+	for _, index := range path {
+		var err error
+		if key, chainCode, err = child(key, chainCode, index); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, path)
+		}
+	}
+
+	return crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+}
+
+// Address returns the address of the key at path
+func (w *Wallet) Address(path accounts.DerivationPath) (common.Address, error) {
+	key, err := w.Derive(path)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return crypto.PubkeyToAddress(key.PublicKey), nil
+}
+
+// child derives a private child key, hardened when index >= 2^31
+func child(key *big.Int, chainCode []byte, index uint32) (*big.Int, []byte, error) {
+	var data []byte
+	if index >= 0x80000000 {
+		data = append([]byte{0}, math.PaddedBigBytes(key, 32)...)
+	} else {
+		priv, err := crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+		if err != nil {
+			return nil, nil, err
+		}
+		data = crypto.CompressPubkey(&priv.PublicKey)
+	}
+	var i [4]byte
+	binary.BigEndian.PutUint32(i[:], index)
+	data = append(data, i[:]...)
+
+	mac := hmac.New(sha512.New, chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	tweak := new(big.Int).SetBytes(sum[:32])
+	if tweak.Cmp(curveOrder) >= 0 {
+		return nil, nil, ErrInvalidKey
+	}
+	childKey := tweak.Add(tweak, key)
+	childKey.Mod(childKey, curveOrder)
+	if childKey.Sign() == 0 {
+		return nil, nil, ErrInvalidKey
+	}
+
+	return childKey, sum[32:], nil
+}
@@ -0,0 +1,92 @@
+package hdwallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestSeed(t *testing.T) {
+	t.Parallel()
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	expected := "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"
+	if got := hex.EncodeToString(Seed(mnemonic, "TREZOR")); got != expected {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	if _, err := NewFromMnemonic("abandon about", ""); !errors.Is(err, ErrInvalidMnemonic) {
+		t.Errorf("Expected %v, got %v", ErrInvalidMnemonic, err)
+	}
+}
+
+func TestCheckMnemonic(t *testing.T) {
+	t.Parallel()
+	for mnemonic, valid := range map[string]bool{
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about":                                                            true,
+		"legal winner thank year wave sausage worth useful legal winner thank yellow":                                                                              true,
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote":                                                         true,
+		"void come effort suffer camp survey warrior heavy shoot primary clutch crush open amazing screen patrol group space point ten exist slush involve unfold": true,
+		"test test test test test test test test test test test junk":                                                                                              true,
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon":                                                          false,
+		"legal winner thank year wave sausage worth useful legal winner thank yelow":                                                                               false,
+		"void come effort suffer camp survey warrior heavy shoot primary clutch crush open amazing screen patrol group space point ten exist slush involve zoo":    false,
+	} {
+		if err := CheckMnemonic(mnemonic); valid && err != nil || !valid && !errors.Is(err, ErrInvalidMnemonic) {
+			t.Errorf("Expected valid %v for %q, got %v", valid, mnemonic, err)
+		}
+	}
+}
+
+func TestDeriveBIP32Vector(t *testing.T) {
+	t.Parallel()
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	w, err := NewFromSeed(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vectors := map[string]string{
+		"m":                      "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35",
+		"m/0'":                   "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea",
+		"m/0'/1/2'/2/1000000000": "471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8",
+	}
+	for path, expected := range vectors {
+		parsed, err := accounts.ParseDerivationPath(path)
+		if path == "m" {
+			parsed, err = accounts.DerivationPath{}, nil
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := w.Derive(parsed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(crypto.FromECDSA(key)); got != expected {
+			t.Errorf("Expected %v at %v, got %v", expected, path, got)
+		}
+	}
+}
+
+func TestAddress(t *testing.T) {
+	t.Parallel()
+	w, err := NewFromMnemonic("test test test test test test test test test test test junk", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := accounts.DefaultIterator(accounts.DefaultBaseDerivationPath)
+	for _, expected := range []string{"0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266", "0x70997970C51812dc3A010C7d01b50e0d17dc79C8"} {
+		address, err := w.Address(next())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if address != common.HexToAddress(expected) {
+			t.Errorf("Expected %v, got %v", expected, address.Hex())
+		}
+	}
+}
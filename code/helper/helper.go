@@ -3,18 +3,24 @@ package ethereum
 import (
 	"context"
 	"errors"
+	"ethereum-development-with-go/code/accesslist"
 	token "ethereum-development-with-go/code/contracts_erc20"
+	"ethereum-development-with-go/code/dryrun"
+	"ethereum-development-with-go/code/reverts"
+	"ethereum-development-with-go/code/sigdb"
 	"math/big"
 	"strings"
 
 	"crypto/ecdsa"
 
+	ethgo "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 /*
@@ -24,6 +30,10 @@ import (
 // Service service
 type Service struct {
 	Client *ethclient.Client
+	// RPC is the connection under Client, used for calls ethclient lacks
+	RPC *rpc.Client
+	// Reverts decodes revert reasons and custom errors, register contract ABIs on it
+	Reverts *reverts.Decoder
 }
 
 // Options service options
@@ -36,12 +46,14 @@ func New(opts *Options) (*Service, error) {
 	if opts.ProviderURI == "" {
 		return nil, errors.New("ethereum provider uri is required")
 	}
-	client, err := ethclient.Dial(opts.ProviderURI)
+	rpcClient, err := rpc.Dial(opts.ProviderURI)
 	if err != nil {
 		return nil, err
 	}
 	return &Service{
-		Client: client,
+		Client:  ethclient.NewClient(rpcClient),
+		RPC:     rpcClient,
+		Reverts: reverts.NewDecoder(sigdb.Default()),
 	}, nil
 }
 
@@ -145,16 +157,35 @@ func (s *Service) TransferTokens(auth bind.TransactOpts, _tokenAddress string, _
 	return tx, nil
 }
 
-// SendTx send a transaction to the network
+// SendTx send a transaction to the network, execution reverts are returned as *reverts.RevertError
 func (s *Service) SendTx(tx *types.Transaction) error {
 	err := s.Client.SendTransaction(context.Background(), tx)
 	if err != nil {
-		return err
+		return s.decodeRevert(err)
 	}
 
 	return nil
 }
 
+// ReplayTx replays a mined transaction that reverted and returns the decoded revert
+func (s *Service) ReplayTx(txHash string) (*reverts.RevertError, error) {
+	decoder := s.Reverts
+	if decoder == nil {
+		decoder = reverts.NewDecoder(sigdb.Default())
+	}
+
+	return decoder.Replay(context.Background(), s.Client, common.HexToHash(txHash))
+}
+
+// decodeRevert turns execution reverts into *reverts.RevertError
+func (s *Service) decodeRevert(err error) error {
+	if s.Reverts == nil {
+		return err
+	}
+
+	return s.Reverts.FromError(err)
+}
+
 // SignTx sign a transaction with a private key
 func (s *Service) SignTx(nonce uint64, _toAddress string, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte, privateKey string) (*types.Transaction, error) {
 	key, err := crypto.HexToECDSA(privateKey)
@@ -182,6 +213,84 @@ func (s *Service) SignTx(nonce uint64, _toAddress string, amount *big.Int, gasLi
 	return tx, nil
 }
 
+// SimulateTx dry-runs a signed transaction at the pending block before SendTx,
+// overrides may be nil
+func (s *Service) SimulateTx(tx *types.Transaction, overrides dryrun.Overrides) (*dryrun.Result, error) {
+	if s.RPC == nil {
+		return nil, errors.New("simulating requires the rpc connection")
+	}
+
+	return dryrun.New(s.RPC, s.Reverts).Transaction(context.Background(), tx, overrides)
+}
+
+// CreateAccessList returns the access list of a call and its gas with and without the list.
+// Nodes without eth_createAccessList get the list by tracing the call on their fetched state
+func (s *Service) CreateAccessList(_fromAddress string, _toAddress string, amount *big.Int, data []byte) (*accesslist.Result, error) {
+	if s.RPC == nil {
+		return nil, errors.New("creating access lists requires the rpc connection")
+	}
+	toAddress := common.HexToAddress(_toAddress)
+	msg := ethgo.CallMsg{From: common.HexToAddress(_fromAddress), To: &toAddress, Value: amount, Data: data}
+
+	creator := accesslist.WithFallback(accesslist.NewRPC(s.RPC), accesslist.NewRemote(s.Client, nil))
+	return creator.CreateAccessList(context.Background(), msg)
+}
+
+// SignAccessListTx sign an EIP-2930 transaction carrying an access list
+func (s *Service) SignAccessListTx(nonce uint64, _toAddress string, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte, accessList types.AccessList, privateKey string) (*types.Transaction, error) {
+	var err error
+	if gasPrice == nil {
+		gasPrice, err = s.Client.SuggestGasPrice(context.Background())
+		if err != nil {
+			return &types.Transaction{}, err
+		}
+	}
+	tx := types.NewTransaction(nonce, common.HexToAddress(_toAddress), amount, gasLimit, gasPrice, data)
+
+	return s.signWithAccessList(tx, accessList, privateKey)
+}
+
+// SignDynamicFeeTx sign an EIP-1559 transaction, accessList may be nil
+func (s *Service) SignDynamicFeeTx(nonce uint64, _toAddress string, amount *big.Int, gasLimit uint64, gasTipCap *big.Int, gasFeeCap *big.Int, data []byte, accessList types.AccessList, privateKey string) (*types.Transaction, error) {
+	toAddress := common.HexToAddress(_toAddress)
+	chainID, err := s.Client.ChainID(context.Background())
+	if err != nil {
+		return &types.Transaction{}, err
+	}
+
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		GasTipCap: gasTipCap,
+		GasFeeCap: gasFeeCap,
+		Gas:       gasLimit,
+		To:        &toAddress,
+		Value:     amount,
+		Data:      data,
+	})
+
+	return s.signWithAccessList(tx, accessList, privateKey)
+}
+
+// signWithAccessList attaches accessList to an unsigned transaction and signs it
+func (s *Service) signWithAccessList(tx *types.Transaction, accessList types.AccessList, privateKey string) (*types.Transaction, error) {
+	key, err := crypto.HexToECDSA(privateKey)
+	if err != nil {
+		return &types.Transaction{}, err
+	}
+	chainID, err := s.Client.ChainID(context.Background())
+	if err != nil {
+		return &types.Transaction{}, err
+	}
+
+	tx, err = accesslist.Apply(tx, chainID, accessList)
+	if err != nil {
+		return &types.Transaction{}, err
+	}
+
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), key)
+}
+
 // TransferTokensTxData generate transaction data for transfer token call
 func (s *Service) TransferTokensTxData(_toAddress string, amount *big.Int) ([]byte, error) {
 	toAddress := common.HexToAddress(_toAddress)
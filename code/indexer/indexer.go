@@ -0,0 +1,212 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+/*
+ * Backfill historical logs in chunks that adapt to provider limits.
+ */
+
+// Defaults used when Config fields are left zero
+const (
+	DefaultChunkSize  = 2000
+	DefaultMaxRetries = 5
+	DefaultRetryDelay = time.Second
+)
+
+// rangeErrors are fragments of the errors providers return when a
+// eth_getLogs range holds too many results or spans too many blocks
+var rangeErrors = []string{
+	"query returned more than",
+	"too many results",
+	"response size exceeded",
+	"block range",
+	"limit exceeded",
+	"log response size",
+	"query timeout exceeded",
+}
+
+// Config configures an Indexer
+type Config struct {
+	// Query selects the addresses and topics to index, its block range and
+	// block hash are ignored
+	Query ethereum.FilterQuery
+	// ChunkSize is the largest number of blocks requested at once
+	ChunkSize uint64
+	// MaxRetries bounds retries of a chunk on transient errors
+	MaxRetries int
+	// RetryDelay is the initial backoff between retries, doubled each time
+	RetryDelay time.Duration
+	// IsRangeError reports whether an error means the range must be split,
+	// IsRangeError is used when nil
+	IsRangeError func(error) bool
+	// OnProgress is called after every stored chunk
+	OnProgress func(from, to uint64, logs int)
+}
+
+// Filterer is the part of a node client the indexer needs
+type Filterer interface {
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+}
+
+// Indexer backfills the logs of one filter into a Store
+type Indexer struct {
+	client Filterer
+	store  *Store
+	name   string
+	cfg    Config
+}
+
+// New returns an indexer storing logs under name, which must not contain '/'
+func New(client Filterer, store *Store, name string, cfg Config) (*Indexer, error) {
+	if name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("indexer: invalid name %q", name)
+	}
+	if cfg.ChunkSize == 0 {
+		cfg.ChunkSize = DefaultChunkSize
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = DefaultMaxRetries
+	}
+	if cfg.RetryDelay == 0 {
+		cfg.RetryDelay = DefaultRetryDelay
+	}
+	if cfg.IsRangeError == nil {
+		cfg.IsRangeError = IsRangeError
+	}
+
+	return &Indexer{
+		client: client,
+		store:  store,
+		name:   name,
+		cfg:    cfg,
+	}, nil
+}
+
+// IsRangeError reports whether a provider rejected eth_getLogs because the
+// range was too large, JSON-RPC code -32005 or a known message
+func IsRangeError(err error) bool {
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == -32005 {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, fragment := range rangeErrors {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checkpoint returns the highest block indexed, see Store.Checkpoint
+func (ix *Indexer) Checkpoint() (uint64, bool, error) {
+	return ix.store.Checkpoint(ix.name)
+}
+
+// Ranges returns the block ranges indexed, see Store.Ranges
+func (ix *Indexer) Ranges() ([]Range, error) {
+	return ix.store.Ranges(ix.name)
+}
+
+// Backfill indexes the logs between from and to inclusive. Only the blocks
+// not indexed yet are fetched, so calling it again after a crash or
+// cancellation continues where it stopped, and an earlier range can be
+// backfilled after a later one.
+func (ix *Indexer) Backfill(ctx context.Context, from, to uint64) error {
+	ranges, err := ix.Ranges()
+	if err != nil {
+		return err
+	}
+	for _, gap := range missing(ranges, from, to) {
+		if err := ix.backfill(ctx, gap.From, gap.To); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// backfill indexes from to to in chunks, halving them on range errors
+func (ix *Indexer) backfill(ctx context.Context, from, to uint64) error {
+	chunk := ix.cfg.ChunkSize
+	for from <= to {
+		end := from + chunk - 1
+		if end > to || end < from {
+			end = to
+		}
+
+		logs, err := ix.fetch(ctx, from, end)
+		if err != nil && ix.cfg.IsRangeError(err) {
+			if end == from {
+				return fmt.Errorf("indexer: block %d alone exceeds the provider limit: %w", from, err)
+			}
+			chunk = (end - from + 1) / 2
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := ix.store.Put(ix.name, logs, from, end); err != nil {
+			return err
+		}
+		if ix.cfg.OnProgress != nil {
+			ix.cfg.OnProgress(from, end, len(logs))
+		}
+
+		from = end + 1
+		// grow back towards the configured size after a split
+		if chunk < ix.cfg.ChunkSize {
+			chunk *= 2
+			if chunk > ix.cfg.ChunkSize {
+				chunk = ix.cfg.ChunkSize
+			}
+		}
+	}
+
+	return nil
+}
+
+// fetch runs a single eth_getLogs, retrying transient errors with backoff
+func (ix *Indexer) fetch(ctx context.Context, from, to uint64) ([]types.Log, error) {
+	q := ix.cfg.Query
+	q.BlockHash = nil
+	q.FromBlock = new(big.Int).SetUint64(from)
+	q.ToBlock = new(big.Int).SetUint64(to)
+
+	delay := ix.cfg.RetryDelay
+	for attempt := 0; ; attempt++ {
+		logs, err := ix.client.FilterLogs(ctx, q)
+		if err == nil {
+			return logs, nil
+		}
+		if ix.cfg.IsRangeError(err) || attempt >= ix.cfg.MaxRetries {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// Logs queries the indexed logs, see Store.Logs
+func (ix *Indexer) Logs(q ethereum.FilterQuery) ([]types.Log, error) {
+	return ix.store.Logs(ix.name, q)
+}
@@ -0,0 +1,233 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	tokenA   = common.HexToAddress("0xe41d2489571d322189246dafa5ebde1f4699f498")
+	tokenB   = common.HexToAddress("0x9b8f68d305daef003632fec0df1be20e0b23be23")
+	transfer = common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+	approval = common.HexToHash("0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925")
+)
+
+// fakeFilterer serves logs like a provider capped at maxResults per query
+type fakeFilterer struct {
+	logs       []types.Log
+	maxResults int
+	failAfter  int
+	calls      int
+}
+
+func (f *fakeFilterer) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	f.calls++
+	if f.failAfter > 0 && f.calls > f.failAfter {
+		return nil, errors.New("connection reset by peer")
+	}
+
+	var out []types.Log
+	for _, vLog := range f.logs {
+		if vLog.BlockNumber < q.FromBlock.Uint64() || vLog.BlockNumber > q.ToBlock.Uint64() {
+			continue
+		}
+		if matches(vLog, q) {
+			out = append(out, vLog)
+		}
+	}
+	if len(out) > f.maxResults {
+		return nil, fmt.Errorf("query returned more than %d results", f.maxResults)
+	}
+
+	return out, nil
+}
+
+func testLogs() []types.Log {
+	var logs []types.Log
+	for block := uint64(100); block < 200; block++ {
+		for i := uint(0); i < 3; i++ {
+			address, topic := tokenA, transfer
+			if i == 2 {
+				address, topic = tokenB, approval
+			}
+			logs = append(logs, types.Log{
+				Address:     address,
+				Topics:      []common.Hash{topic},
+				Data:        []byte{byte(block), byte(i)},
+				BlockNumber: block,
+				BlockHash:   common.BigToHash(big.NewInt(int64(block))),
+				Index:       uint(block)*3 + i,
+			})
+		}
+	}
+
+	return logs
+}
+
+func TestBackfillSplitsRanges(t *testing.T) {
+	t.Parallel()
+	store, err := NewMemoryStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	client := &fakeFilterer{logs: testLogs(), maxResults: 20}
+	ix, err := New(client, store, "zrx", Config{
+		Query:     ethereum.FilterQuery{Addresses: []common.Address{tokenA}},
+		ChunkSize: 50,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ix.Backfill(context.Background(), 100, 199); err != nil {
+		t.Fatal(err)
+	}
+
+	logs, err := ix.Logs(ethereum.FilterQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 200 {
+		t.Errorf("Expected %v logs, got %v", 200, len(logs))
+	}
+	for i := 1; i < len(logs); i++ {
+		if logs[i].Index <= logs[i-1].Index {
+			t.Fatalf("Expected logs ordered by position, got %v after %v", logs[i].Index, logs[i-1].Index)
+		}
+	}
+
+	checkpoint, ok, err := ix.Checkpoint()
+	if err != nil || !ok || checkpoint != 199 {
+		t.Errorf("Expected checkpoint %v, got %v %v %v", 199, checkpoint, ok, err)
+	}
+}
+
+func TestBackfillResumes(t *testing.T) {
+	t.Parallel()
+	store, err := NewMemoryStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	client := &fakeFilterer{logs: testLogs(), maxResults: 1000, failAfter: 3}
+	cfg := Config{ChunkSize: 10, MaxRetries: 1, RetryDelay: time.Millisecond}
+	ix, err := New(client, store, "all", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ix.Backfill(context.Background(), 100, 199); err == nil {
+		t.Fatal("Expected the provider failure to stop the backfill")
+	}
+	checkpoint, _, err := ix.Checkpoint()
+	if err != nil || checkpoint != 129 {
+		t.Fatalf("Expected checkpoint %v, got %v %v", 129, checkpoint, err)
+	}
+
+	client.failAfter = 0
+	client.calls = 0
+	if err := ix.Backfill(context.Background(), 100, 199); err != nil {
+		t.Fatal(err)
+	}
+	if client.calls != 7 {
+		t.Errorf("Expected %v calls after resuming, got %v", 7, client.calls)
+	}
+
+	logs, err := ix.Logs(ethereum.FilterQuery{
+		FromBlock: big.NewInt(150),
+		ToBlock:   big.NewInt(159),
+		Topics:    [][]common.Hash{{approval}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 10 {
+		t.Errorf("Expected %v logs, got %v", 10, len(logs))
+	}
+	for _, vLog := range logs {
+		if vLog.Address != tokenB || vLog.BlockNumber < 150 || vLog.BlockNumber > 159 {
+			t.Errorf("Unexpected log %+v", vLog)
+		}
+	}
+}
+
+func TestBackfillSingleBlockLimit(t *testing.T) {
+	t.Parallel()
+	store, err := NewMemoryStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	client := &fakeFilterer{logs: testLogs(), maxResults: 2}
+	ix, err := New(client, store, "tiny", Config{ChunkSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ix.Backfill(context.Background(), 100, 110)
+	if err == nil || !IsRangeError(err) {
+		t.Errorf("Expected a range error, got %v", err)
+	}
+}
+
+func TestNewRejectsNames(t *testing.T) {
+	t.Parallel()
+	if _, err := New(&fakeFilterer{}, nil, "a/b", Config{}); err == nil {
+		t.Error("Expected an error for a name containing '/'")
+	}
+}
+
+func TestBackfillEarlierRange(t *testing.T) {
+	t.Parallel()
+	store, err := NewMemoryStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	client := &fakeFilterer{logs: testLogs(), maxResults: 1000}
+	ix, err := New(client, store, "all", Config{ChunkSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ix.Backfill(context.Background(), 150, 179); err != nil {
+		t.Fatal(err)
+	}
+	if err := ix.Backfill(context.Background(), 100, 119); err != nil {
+		t.Fatal(err)
+	}
+	ranges, err := ix.Ranges()
+	if err != nil || len(ranges) != 2 || ranges[0] != (Range{100, 119}) || ranges[1] != (Range{150, 179}) {
+		t.Errorf("Expected two ranges, got %v %v", ranges, err)
+	}
+
+	// only the gaps are fetched
+	client.calls = 0
+	if err := ix.Backfill(context.Background(), 100, 199); err != nil {
+		t.Fatal(err)
+	}
+	if client.calls != 2 {
+		t.Errorf("Expected %v calls, got %v", 2, client.calls)
+	}
+	ranges, _ = ix.Ranges()
+	if len(ranges) != 1 || ranges[0] != (Range{100, 199}) {
+		t.Errorf("Expected one range, got %v", ranges)
+	}
+	logs, err := ix.Logs(ethereum.FilterQuery{})
+	if err != nil || len(logs) != 300 {
+		t.Errorf("Expected %v logs, got %v %v", 300, len(logs), err)
+	}
+}
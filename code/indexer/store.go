@@ -0,0 +1,246 @@
+package indexer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+var (
+	logPrefix    = []byte("log/")
+	rangesPrefix = []byte("ranges/")
+)
+
+// Range is an inclusive range of blocks
+type Range struct {
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+}
+
+// Store persists indexed logs and the block ranges they cover in goleveldb.
+// Logs are keyed by index name, block number and log index, so re-indexing a
+// range overwrites rather than duplicates.
+type Store struct {
+	db *leveldb.DB
+	// mu serializes the updates of ranges
+	mu sync.Mutex
+}
+
+// OpenStore opens or creates a store at path
+func OpenStore(path string) (*Store, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+// NewMemoryStore returns a store that is not persisted to disk
+func NewMemoryStore() (*Store, error) {
+	db, err := leveldb.Open(storage.NewMemStorage(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the underlying database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Checkpoint returns the highest block indexed for name, blocks below it may
+// still be missing, see Ranges
+func (s *Store) Checkpoint(name string) (uint64, bool, error) {
+	ranges, err := s.Ranges(name)
+	if err != nil || len(ranges) == 0 {
+		return 0, false, err
+	}
+
+	return ranges[len(ranges)-1].To, true, nil
+}
+
+// Ranges returns the block ranges indexed for name, sorted and merged
+func (s *Store) Ranges(name string) ([]Range, error) {
+	value, err := s.db.Get(rangesKey(name), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ranges []Range
+	if err := json.Unmarshal(value, &ranges); err != nil {
+		return nil, err
+	}
+
+	return ranges, nil
+}
+
+// Put atomically stores the logs of the blocks from to to and marks the range
+// as indexed
+func (s *Store) Put(name string, logs []types.Log, from, to uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ranges, err := s.Ranges(name)
+	if err != nil {
+		return err
+	}
+	value, err := json.Marshal(addRange(ranges, Range{From: from, To: to}))
+	if err != nil {
+		return err
+	}
+
+	batch := new(leveldb.Batch)
+	for _, vLog := range logs {
+		value, err := json.Marshal(&vLog)
+		if err != nil {
+			return err
+		}
+		batch.Put(logKey(name, vLog.BlockNumber, vLog.Index), value)
+	}
+	batch.Put(rangesKey(name), value)
+
+	return s.db.Write(batch, nil)
+}
+
+// addRange adds r to sorted ranges, merging those it overlaps or touches
+func addRange(ranges []Range, r Range) []Range {
+	ranges = append(append([]Range{}, ranges...), r)
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].From < ranges[j].From })
+
+	merged := []Range{ranges[0]}
+	for _, next := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if last.To != ^uint64(0) && next.From > last.To+1 {
+			merged = append(merged, next)
+			continue
+		}
+		if next.To > last.To {
+			last.To = next.To
+		}
+	}
+
+	return merged
+}
+
+// missing returns the parts of from to to that no range covers
+func missing(ranges []Range, from, to uint64) []Range {
+	var gaps []Range
+	for _, r := range ranges {
+		if r.To < from {
+			continue
+		}
+		if r.From > to {
+			break
+		}
+		if r.From > from {
+			gaps = append(gaps, Range{From: from, To: r.From - 1})
+		}
+		if r.To >= to {
+			return gaps
+		}
+		from = r.To + 1
+	}
+	if from <= to {
+		gaps = append(gaps, Range{From: from, To: to})
+	}
+
+	return gaps
+}
+
+// Logs returns the indexed logs for name that match q, ordered by block and
+// log index. A nil FromBlock or ToBlock leaves that end of the range open.
+func (s *Store) Logs(name string, q ethereum.FilterQuery) ([]types.Log, error) {
+	start := logKey(name, 0, 0)
+	if q.FromBlock != nil {
+		start = logKey(name, q.FromBlock.Uint64(), 0)
+	}
+	limit := util.BytesPrefix(append(append([]byte{}, logPrefix...), name+"/"...)).Limit
+	if q.ToBlock != nil {
+		limit = logKey(name, q.ToBlock.Uint64()+1, 0)
+	}
+
+	iter := s.db.NewIterator(&util.Range{Start: start, Limit: limit}, nil)
+	defer iter.Release()
+
+	var logs []types.Log
+	for iter.Next() {
+		var vLog types.Log
+		if err := json.Unmarshal(iter.Value(), &vLog); err != nil {
+			return nil, err
+		}
+		if matches(vLog, q) {
+			logs = append(logs, vLog)
+		}
+	}
+
+	return logs, iter.Error()
+}
+
+// matches applies the address and topic rules of eth_getLogs to a log
+func matches(vLog types.Log, q ethereum.FilterQuery) bool {
+	if len(q.Addresses) > 0 {
+		found := false
+		for _, address := range q.Addresses {
+			if vLog.Address == address {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(q.Topics) > len(vLog.Topics) {
+		return false
+	}
+	for i, alternatives := range q.Topics {
+		if len(alternatives) == 0 {
+			continue
+		}
+		found := false
+		for _, topic := range alternatives {
+			if vLog.Topics[i] == topic {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// logKey is log/<name>/<block number><log index>, big endian so keys sort by position
+func logKey(name string, number uint64, index uint) []byte {
+	key := make([]byte, 0, len(logPrefix)+len(name)+1+12)
+	key = append(key, logPrefix...)
+	key = append(key, name...)
+	key = append(key, '/')
+
+	var pos [12]byte
+	binary.BigEndian.PutUint64(pos[:8], number)
+	binary.BigEndian.PutUint32(pos[8:], uint32(index))
+
+	return append(key, pos[:]...)
+}
+
+// rangesKey is ranges/<name>
+func rangesKey(name string) []byte {
+	return append(append([]byte{}, rangesPrefix...), name...)
+}
@@ -0,0 +1,283 @@
+package offline
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+
+	"ethereum-development-with-go/code/calldata"
+	"ethereum-development-with-go/code/rawtx"
+	"ethereum-development-with-go/code/util"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+/*
+ * Prepare transactions online, sign them on an air-gapped machine and
+ * broadcast the result online again.
+ */
+
+var (
+	// ErrWrongKey is returned when the signing key is not the prepared sender
+	ErrWrongKey = errors.New("offline: key does not match the transaction sender")
+	// ErrNonceTooLow is returned when the signed nonce was already used
+	ErrNonceTooLow = errors.New("offline: nonce already used")
+	// ErrInsufficientFunds is returned when the sender cannot pay value plus gas
+	ErrInsufficientFunds = errors.New("offline: insufficient funds for value plus gas")
+)
+
+// PrepareBackend is the part of a node client the prepare step needs
+type PrepareBackend interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error)
+}
+
+// BroadcastBackend is the part of a node client the broadcast step needs
+type BroadcastBackend interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+}
+
+// Request is what the sender wants to do
+type Request struct {
+	From common.Address
+	// To is nil for a contract creation
+	To    *common.Address
+	Value *big.Int
+	Data  []byte
+	// Gas is estimated when zero
+	Gas uint64
+	// Legacy forces a gas price transaction on London chains
+	Legacy bool
+}
+
+// Unsigned is a transaction with everything filled in but the signature,
+// it is the file carried to the offline machine
+type Unsigned struct {
+	ChainID *big.Int        `json:"chainId"`
+	From    common.Address  `json:"from"`
+	To      *common.Address `json:"to"`
+	Nonce   uint64          `json:"nonce"`
+	Value   *big.Int        `json:"value"`
+	Data    hexutil.Bytes   `json:"data"`
+	Gas     uint64          `json:"gas"`
+	// GasPrice is set for legacy transactions, the caps for EIP-1559 ones
+	GasPrice  *big.Int `json:"gasPrice,omitempty"`
+	GasTipCap *big.Int `json:"maxPriorityFeePerGas,omitempty"`
+	GasFeeCap *big.Int `json:"maxFeePerGas,omitempty"`
+}
+
+// Prepare fetches the nonce, fees, chain id and gas estimate for req
+func Prepare(ctx context.Context, backend PrepareBackend, req Request) (*Unsigned, error) {
+	chainID, err := backend.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("offline: chain id: %w", err)
+	}
+	nonce, err := backend.PendingNonceAt(ctx, req.From)
+	if err != nil {
+		return nil, fmt.Errorf("offline: nonce: %w", err)
+	}
+	head, err := backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("offline: head: %w", err)
+	}
+
+	u := &Unsigned{
+		ChainID: chainID,
+		From:    req.From,
+		To:      req.To,
+		Nonce:   nonce,
+		Value:   req.Value,
+		Data:    req.Data,
+		Gas:     req.Gas,
+	}
+	if u.Value == nil {
+		u.Value = new(big.Int)
+	}
+
+	msg := ethereum.CallMsg{From: req.From, To: req.To, Value: u.Value, Data: req.Data}
+	if head.BaseFee == nil || req.Legacy {
+		if u.GasPrice, err = backend.SuggestGasPrice(ctx); err != nil {
+			return nil, fmt.Errorf("offline: gas price: %w", err)
+		}
+		msg.GasPrice = u.GasPrice
+	} else {
+		if u.GasTipCap, err = backend.SuggestGasTipCap(ctx); err != nil {
+			return nil, fmt.Errorf("offline: gas tip cap: %w", err)
+		}
+		// the same headroom bind uses, enough for six full blocks in a row
+		u.GasFeeCap = new(big.Int).Add(u.GasTipCap, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+		msg.GasTipCap, msg.GasFeeCap = u.GasTipCap, u.GasFeeCap
+	}
+
+	if u.Gas == 0 {
+		if u.Gas, err = backend.EstimateGas(ctx, msg); err != nil {
+			return nil, fmt.Errorf("offline: estimating gas: %w", err)
+		}
+	}
+
+	return u, nil
+}
+
+// Tx returns the unsigned transaction
+func (u *Unsigned) Tx() *types.Transaction {
+	if u.GasPrice != nil {
+		return types.NewTx(&types.LegacyTx{
+			Nonce: u.Nonce, GasPrice: u.GasPrice, Gas: u.Gas, To: u.To, Value: u.Value, Data: u.Data,
+		})
+	}
+
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID: u.ChainID, Nonce: u.Nonce, GasTipCap: u.GasTipCap, GasFeeCap: u.GasFeeCap,
+		Gas: u.Gas, To: u.To, Value: u.Value, Data: u.Data,
+	})
+}
+
+// MaxCost is the most the transaction can cost the sender, value included
+func (u *Unsigned) MaxCost() *big.Int {
+	return u.Tx().Cost()
+}
+
+// Summary describes the transaction for a human to check before signing,
+// calls decodes the data and may be nil
+func (u *Unsigned) Summary(calls *calldata.Decoder) string {
+	var b strings.Builder
+	line := func(label string, format string, args ...interface{}) {
+		if label != "" {
+			label += ":"
+		}
+		fmt.Fprintf(&b, "%-10s "+format+"\n", append([]interface{}{label}, args...)...)
+	}
+
+	line("Chain ID", "%v", u.ChainID)
+	line("From", "%s", u.From.Hex())
+	if u.To == nil {
+		line("To", "(contract creation)")
+	} else {
+		line("To", "%s", u.To.Hex())
+	}
+	line("Value", "%s ETH", ether(u.Value))
+	line("Nonce", "%d", u.Nonce)
+	line("Gas limit", "%d", u.Gas)
+	if u.GasPrice != nil {
+		line("Gas price", "%s gwei", gwei(u.GasPrice))
+	} else {
+		line("Max fee", "%s gwei, tip %s gwei", gwei(u.GasFeeCap), gwei(u.GasTipCap))
+	}
+	line("Max cost", "%s ETH", ether(u.MaxCost()))
+
+	if len(u.Data) > 0 {
+		line("Data", "%d bytes", len(u.Data))
+		if calls != nil && u.To != nil {
+			if call, err := calls.Decode(u.Data); err == nil {
+				line("Call", "%s", call.Signature)
+				for _, arg := range call.Args {
+					line("", "%s %s = %v", arg.Type, arg.Name, arg.Value)
+				}
+			} else {
+				line("Call", "unknown (%v)", err)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// Sign signs the transaction, key must belong to the prepared sender
+func (u *Unsigned) Sign(key *ecdsa.PrivateKey) (*types.Transaction, error) {
+	if crypto.PubkeyToAddress(key.PublicKey) != u.From {
+		return nil, fmt.Errorf("%w: expected %s", ErrWrongKey, u.From.Hex())
+	}
+
+	return types.SignTx(u.Tx(), types.LatestSignerForChainID(u.ChainID), key)
+}
+
+// Broadcast decodes a signed raw transaction, checks its signature, chain id,
+// nonce and the sender's balance against the node and submits it
+func Broadcast(ctx context.Context, backend BroadcastBackend, raw []byte) (*rawtx.Decoded, error) {
+	chainID, err := backend.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("offline: chain id: %w", err)
+	}
+	decoded, err := rawtx.NewDecoder(chainID, nil).Decode(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := backend.PendingNonceAt(ctx, decoded.From)
+	if err != nil {
+		return nil, fmt.Errorf("offline: nonce: %w", err)
+	}
+	if decoded.Nonce < nonce {
+		return nil, fmt.Errorf("%w: nonce %d, next is %d", ErrNonceTooLow, decoded.Nonce, nonce)
+	}
+	if decoded.Nonce > nonce {
+		decoded.Warnings = append(decoded.Warnings, fmt.Sprintf("nonce %d leaves a gap, the next is %d", decoded.Nonce, nonce))
+	}
+
+	balance, err := backend.BalanceAt(ctx, decoded.From, nil)
+	if err != nil {
+		return nil, fmt.Errorf("offline: balance: %w", err)
+	}
+	if cost := decoded.Tx.Cost(); balance.Cmp(cost) < 0 {
+		return nil, fmt.Errorf("%w: have %s ETH, need %s ETH", ErrInsufficientFunds, ether(balance), ether(cost))
+	}
+
+	if err := backend.SendTransaction(ctx, decoded.Tx); err != nil {
+		return nil, err
+	}
+
+	return decoded, nil
+}
+
+// ReadUnsigned reads an unsigned transaction file
+func ReadUnsigned(path string) (*Unsigned, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	u := new(Unsigned)
+	if err := json.Unmarshal(data, u); err != nil {
+		return nil, fmt.Errorf("offline: invalid transaction file %s: %w", path, err)
+	}
+	if u.ChainID == nil || u.Value == nil || (u.GasPrice == nil && (u.GasTipCap == nil || u.GasFeeCap == nil)) {
+		return nil, fmt.Errorf("offline: incomplete transaction file %s", path)
+	}
+
+	return u, nil
+}
+
+// WriteUnsigned writes an unsigned transaction file
+func WriteUnsigned(path string, u *Unsigned) error {
+	data, err := json.MarshalIndent(u, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func ether(wei *big.Int) string {
+	s, _ := util.FormatUnits(wei, 18, nil)
+	return s
+}
+
+func gwei(wei *big.Int) string {
+	s, _ := util.FormatUnits(wei, 9, nil)
+	return s
+}
@@ -0,0 +1,138 @@
+package offline
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ethereum-development-with-go/code/calldata"
+	"ethereum-development-with-go/code/rawtx"
+	"ethereum-development-with-go/code/sigdb"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// simulated adds the chain id the simulated backend does not expose
+type simulated struct {
+	*backends.SimulatedBackend
+}
+
+func (s simulated) ChainID(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1337), nil
+}
+
+func TestPrepareSignBroadcast(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	client := simulated{backends.NewSimulatedBackend(core.GenesisAlloc{
+		from: {Balance: big.NewInt(2000000000000000000)},
+	}, 8000000)}
+	defer client.Close()
+
+	to := common.HexToAddress("0x4592d8f8d7b001e72cb26a73e4fa1806a51ac79d")
+	u, err := Prepare(ctx, client, Request{From: from, To: &to, Value: big.NewInt(1500000000000000000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Gas != 21000 || u.GasFeeCap == nil || u.GasPrice != nil || u.ChainID.Int64() != 1337 {
+		t.Errorf("Expected an EIP-1559 transfer with 21000 gas, got %+v", u)
+	}
+
+	// the file round trip the air gap implies
+	dir, err := ioutil.TempDir("", "offline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tx.json")
+	if err := WriteUnsigned(path, u); err != nil {
+		t.Fatal(err)
+	}
+	if u, err = ReadUnsigned(path); err != nil {
+		t.Fatal(err)
+	}
+
+	summary := u.Summary(nil)
+	for _, expected := range []string{"Value:     1.5 ETH", "To:        " + to.Hex(), "Gas limit: 21000"} {
+		if !strings.Contains(summary, expected) {
+			t.Errorf("Expected %q in\n%s", expected, summary)
+		}
+	}
+
+	other, _ := crypto.GenerateKey()
+	if _, err := u.Sign(other); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Expected %v, got %v", ErrWrongKey, err)
+	}
+	tx, err := u.Sign(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := tx.MarshalBinary()
+
+	decoded, err := Broadcast(ctx, client, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.From != from || decoded.Hash != tx.Hash() {
+		t.Errorf("Expected %v from %v, got %v from %v", tx.Hash().Hex(), from.Hex(), decoded.Hash.Hex(), decoded.From.Hex())
+	}
+	client.Commit()
+	if receipt, err := client.TransactionReceipt(ctx, tx.Hash()); err != nil || receipt.Status != 1 {
+		t.Errorf("Expected a successful receipt, got %v %v", receipt, err)
+	}
+
+	if _, err := Broadcast(ctx, client, raw); !errors.Is(err, ErrNonceTooLow) {
+		t.Errorf("Expected %v, got %v", ErrNonceTooLow, err)
+	}
+
+	// the balance left cannot cover a second 1.5 ETH transfer
+	if u, err = Prepare(ctx, client, Request{From: from, To: &to, Value: big.NewInt(1500000000000000000), Gas: 21000, Legacy: true}); err != nil {
+		t.Fatal(err)
+	}
+	if u.GasPrice == nil || u.GasFeeCap != nil {
+		t.Errorf("Expected a legacy transaction, got %+v", u)
+	}
+	tx, _ = u.Sign(key)
+	raw, _ = tx.MarshalBinary()
+	if _, err := Broadcast(ctx, client, raw); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("Expected %v, got %v", ErrInsufficientFunds, err)
+	}
+
+	u.ChainID = big.NewInt(1)
+	tx, _ = u.Sign(key)
+	raw, _ = tx.MarshalBinary()
+	if _, err := Broadcast(ctx, client, raw); !errors.Is(err, rawtx.ErrChainID) {
+		t.Errorf("Expected %v, got %v", rawtx.ErrChainID, err)
+	}
+}
+
+func TestSummaryDecodesCalls(t *testing.T) {
+	t.Parallel()
+	token := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	u := &Unsigned{
+		ChainID:   big.NewInt(1),
+		To:        &token,
+		Value:     new(big.Int),
+		Data:      hexutil.MustDecode("0xa9059cbb0000000000000000000000004592d8f8d7b001e72cb26a73e4fa1806a51ac79d00000000000000000000000000000000000000000000003635c9adc5dea00000"),
+		Gas:       60000,
+		GasTipCap: big.NewInt(2000000000),
+		GasFeeCap: big.NewInt(30000000000),
+	}
+
+	summary := u.Summary(calldata.NewDecoder(sigdb.Default()))
+	for _, expected := range []string{"transfer(address,uint256)", "Max fee:   30 gwei, tip 2 gwei", "Max cost:  0.0018 ETH"} {
+		if !strings.Contains(summary, expected) {
+			t.Errorf("Expected %q in\n%s", expected, summary)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package proof
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+/*
+ * Verify eth_getProof responses against a state root, so account and storage
+ * values from an untrusted provider can be trusted.
+ */
+
+var (
+	// ErrInvalidProof is returned when a proof does not lead to its root
+	ErrInvalidProof = errors.New("proof: invalid proof")
+	// ErrMismatch is returned when the proven value differs from the reported one
+	ErrMismatch = errors.New("proof: reported value differs from the proven one")
+)
+
+// emptyCodeHash is the code hash of accounts without code
+var emptyCodeHash = crypto.Keccak256Hash(nil)
+
+// Caller sends raw JSON-RPC requests, *rpc.Client implements it
+type Caller interface {
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// HeaderReader returns block headers, the state root must be trusted so it
+// should come from a source other than the provider being checked, or from a
+// header whose hash is checked against one
+type HeaderReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// StorageResult is a storage proof of an eth_getProof response
+type StorageResult struct {
+	// Key is echoed as requested, nodes differ in zero padding
+	Key   string          `json:"key"`
+	Value *hexutil.Big    `json:"value"`
+	Proof []hexutil.Bytes `json:"proof"`
+}
+
+// Response is an eth_getProof response
+type Response struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []hexutil.Bytes `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// Account is the verified state of an account
+type Account struct {
+	Address     common.Address
+	Balance     *big.Int
+	Nonce       uint64
+	CodeHash    common.Hash
+	StorageRoot common.Hash
+	// Storage holds the verified value of every requested slot
+	Storage map[common.Hash]common.Hash
+}
+
+// HasCode reports whether the account is a contract
+func (a *Account) HasCode() bool {
+	return a.CodeHash != emptyCodeHash
+}
+
+// GetProof calls eth_getProof for address and slots at block, nil is latest.
+// A response for another address is rejected, its proof would hold for that
+// address and say nothing about the requested one
+func GetProof(ctx context.Context, caller Caller, address common.Address, slots []common.Hash, block *big.Int) (*Response, error) {
+	keys := make([]string, len(slots))
+	for i, slot := range slots {
+		keys[i] = slot.Hex()
+	}
+	number := "latest"
+	if block != nil {
+		number = hexutil.EncodeBig(block)
+	}
+
+	var resp Response
+	if err := caller.CallContext(ctx, &resp, "eth_getProof", address, keys, number); err != nil {
+		return nil, err
+	}
+	if resp.Address != address {
+		return nil, fmt.Errorf("%w: address %s, requested %s", ErrMismatch, resp.Address.Hex(), address.Hex())
+	}
+	return &resp, nil
+}
+
+// Verify checks the account proof against stateRoot and every storage proof
+// against the proven storage root, and returns the proven values. It proves
+// r.Address, callers decoding responses themselves must compare it with the
+// requested address
+func (r *Response) Verify(stateRoot common.Hash) (*Account, error) {
+	value, err := verify(stateRoot, crypto.Keccak256(r.Address.Bytes()), r.AccountProof)
+	if err != nil {
+		return nil, fmt.Errorf("%w: account %s: %v", ErrInvalidProof, r.Address.Hex(), err)
+	}
+
+	account := &Account{
+		Address:     r.Address,
+		Balance:     new(big.Int),
+		CodeHash:    emptyCodeHash,
+		StorageRoot: types.EmptyRootHash,
+		Storage:     make(map[common.Hash]common.Hash),
+	}
+	// a missing account is proven by the path ending before it
+	if value != nil {
+		var state types.StateAccount
+		if err := rlp.DecodeBytes(value, &state); err != nil {
+			return nil, fmt.Errorf("%w: account %s: %v", ErrInvalidProof, r.Address.Hex(), err)
+		}
+		account.Balance = state.Balance
+		account.Nonce = state.Nonce
+		account.CodeHash = common.BytesToHash(state.CodeHash)
+		account.StorageRoot = state.Root
+	}
+
+	switch {
+	case r.Balance == nil || r.Balance.ToInt().Cmp(account.Balance) != 0:
+		return nil, fmt.Errorf("%w: balance %v, proven %v", ErrMismatch, r.Balance, account.Balance)
+	case uint64(r.Nonce) != account.Nonce:
+		return nil, fmt.Errorf("%w: nonce %d, proven %d", ErrMismatch, r.Nonce, account.Nonce)
+	case r.CodeHash != account.CodeHash:
+		return nil, fmt.Errorf("%w: code hash %s, proven %s", ErrMismatch, r.CodeHash.Hex(), account.CodeHash.Hex())
+	case r.StorageHash != account.StorageRoot:
+		return nil, fmt.Errorf("%w: storage hash %s, proven %s", ErrMismatch, r.StorageHash.Hex(), account.StorageRoot.Hex())
+	}
+
+	for _, slot := range r.StorageProof {
+		key := common.HexToHash(slot.Key)
+		value, err := verify(account.StorageRoot, crypto.Keccak256(key.Bytes()), slot.Proof)
+		if err != nil {
+			return nil, fmt.Errorf("%w: slot %s: %v", ErrInvalidProof, key.Hex(), err)
+		}
+
+		// slots hold the RLP encoding of the value without leading zeros
+		var proven []byte
+		if value != nil {
+			_, content, _, err := rlp.Split(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: slot %s: %v", ErrInvalidProof, key.Hex(), err)
+			}
+			proven = content
+		}
+		if slot.Value == nil || !bytes.Equal(slot.Value.ToInt().Bytes(), proven) {
+			return nil, fmt.Errorf("%w: slot %s value %v, proven %x", ErrMismatch, key.Hex(), slot.Value, proven)
+		}
+		account.Storage[key] = common.BytesToHash(proven)
+	}
+
+	return account, nil
+}
+
+// verify walks proof from root along key and returns the value, nil when the
+// proof shows the key is absent
+func verify(root common.Hash, key []byte, proof []hexutil.Bytes) ([]byte, error) {
+	db := memorydb.New()
+	for _, node := range proof {
+		if err := db.Put(crypto.Keccak256(node), node); err != nil {
+			return nil, err
+		}
+	}
+
+	return trie.VerifyProof(root, key, db)
+}
+
+// VerifiedAccount fetches the state root of block from headers and the proof
+// from caller, and returns the account only when the proof holds
+func VerifiedAccount(ctx context.Context, headers HeaderReader, caller Caller, address common.Address, slots []common.Hash, block *big.Int) (*Account, error) {
+	header, err := headers.HeaderByNumber(ctx, block)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := GetProof(ctx, caller, address, slots, header.Number)
+	if err != nil {
+		return nil, err
+	}
+	account, err := resp.Verify(header.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	// a provider leaving a slot out proves nothing about it
+	for _, slot := range slots {
+		if _, ok := account.Storage[slot]; !ok {
+			return nil, fmt.Errorf("%w: slot %s missing", ErrMismatch, slot.Hex())
+		}
+	}
+
+	return account, nil
+}
@@ -0,0 +1,204 @@
+package proof
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	store "ethereum-development-with-go/code/contracts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// fakeEth serves eth_getProof from the head state of a simulated chain,
+// tamper and redirect let a test play a lying provider
+type fakeEth struct {
+	chain  *core.BlockChain
+	tamper func(*Response)
+	// redirect proves another account than the requested one
+	redirect *common.Address
+}
+
+func (f *fakeEth) GetProof(address common.Address, keys []string, block string) (*Response, error) {
+	if f.redirect != nil {
+		address = *f.redirect
+	}
+	statedb, err := f.chain.State()
+	if err != nil {
+		return nil, err
+	}
+	accountProof, err := statedb.GetProof(address)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &Response{
+		Address:      address,
+		AccountProof: toHex(accountProof),
+		Balance:      (*hexutil.Big)(statedb.GetBalance(address)),
+		CodeHash:     emptyCodeHash,
+		Nonce:        hexutil.Uint64(statedb.GetNonce(address)),
+		StorageHash:  types.EmptyRootHash,
+	}
+	if statedb.Exist(address) {
+		resp.CodeHash = statedb.GetCodeHash(address)
+		if storage := statedb.StorageTrie(address); storage != nil {
+			resp.StorageHash = storage.Hash()
+		}
+	}
+	for _, key := range keys {
+		slotProof, err := statedb.GetStorageProof(address, common.HexToHash(key))
+		if err != nil {
+			return nil, err
+		}
+		resp.StorageProof = append(resp.StorageProof, StorageResult{
+			Key:   key,
+			Value: (*hexutil.Big)(statedb.GetState(address, common.HexToHash(key)).Big()),
+			Proof: toHex(slotProof),
+		})
+	}
+
+	if f.tamper != nil {
+		f.tamper(resp)
+	}
+	return resp, nil
+}
+
+func toHex(nodes [][]byte) []hexutil.Bytes {
+	out := make([]hexutil.Bytes, len(nodes))
+	for i, node := range nodes {
+		out[i] = node
+	}
+	return out
+}
+
+type fixture struct {
+	backend *backends.SimulatedBackend
+	eth     *fakeEth
+	client  *rpc.Client
+	key     *ecdsa.PrivateKey
+	store   common.Address
+}
+
+func newFixture(t *testing.T) *fixture {
+	key, _ := crypto.GenerateKey()
+	auth, _ := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{
+		auth.From: {Balance: big.NewInt(1000000000000000000)},
+	}, 8000000)
+
+	address, _, instance, err := store.DeployStore(auth, backend, "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.Commit()
+	if _, err := instance.SetItem(auth, [32]byte{1}, [32]byte{2}); err != nil {
+		t.Fatal(err)
+	}
+	backend.Commit()
+
+	eth := &fakeEth{chain: backend.Blockchain()}
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", eth); err != nil {
+		t.Fatal(err)
+	}
+
+	return &fixture{backend: backend, eth: eth, client: rpc.DialInProc(server), key: key, store: address}
+}
+
+func (f *fixture) close() {
+	f.client.Close()
+	f.backend.Close()
+}
+
+// itemSlot is the slot of items[key], the mapping is the second variable
+func itemSlot(key [32]byte) common.Hash {
+	return crypto.Keccak256Hash(key[:], common.LeftPadBytes([]byte{1}, 32))
+}
+
+func TestVerifiedAccount(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	defer f.close()
+	ctx := context.Background()
+
+	set, unset := itemSlot([32]byte{1}), itemSlot([32]byte{3})
+	account, err := VerifiedAccount(ctx, f.backend, f.client, f.store, []common.Hash{set, unset}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !account.HasCode() || account.Storage[set] != (common.Hash{2}) || account.Storage[unset] != (common.Hash{}) {
+		t.Errorf("Expected a contract with %v at %v, got %v", common.Hash{2}.Hex(), set.Hex(), account.Storage)
+	}
+
+	sender := crypto.PubkeyToAddress(f.key.PublicKey)
+	balance, _ := f.backend.BalanceAt(ctx, sender, nil)
+	if account, err = VerifiedAccount(ctx, f.backend, f.client, sender, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if account.HasCode() || account.Nonce != 2 || account.Balance.Cmp(balance) != 0 {
+		t.Errorf("Expected nonce 2 and balance %v, got %v and %v", balance, account.Nonce, account.Balance)
+	}
+
+	// absence is proven too
+	if account, err = VerifiedAccount(ctx, f.backend, f.client, common.HexToAddress("0x1234"), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if account.HasCode() || account.Balance.Sign() != 0 || account.StorageRoot != types.EmptyRootHash {
+		t.Errorf("Expected an empty account, got %+v", account)
+	}
+}
+
+func TestLyingProvider(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	defer f.close()
+	ctx := context.Background()
+	set := itemSlot([32]byte{1})
+
+	for name, c := range map[string]struct {
+		tamper   func(*Response)
+		expected error
+	}{
+		"balance": {func(r *Response) { r.Balance = (*hexutil.Big)(big.NewInt(1)) }, ErrMismatch},
+		"storage": {func(r *Response) { r.StorageProof[0].Value = (*hexutil.Big)(big.NewInt(3)) }, ErrMismatch},
+		"missing": {func(r *Response) { r.StorageProof = nil }, ErrMismatch},
+		"node": {func(r *Response) {
+			last := r.AccountProof[len(r.AccountProof)-1]
+			last[len(last)-1] ^= 1
+		}, ErrInvalidProof},
+		"slot node": {func(r *Response) {
+			r.StorageProof[0].Proof = r.StorageProof[0].Proof[:len(r.StorageProof[0].Proof)-1]
+		}, ErrInvalidProof},
+	} {
+		f.eth.tamper = c.tamper
+		if _, err := VerifiedAccount(ctx, f.backend, f.client, f.store, []common.Hash{set}, nil); !errors.Is(err, c.expected) {
+			t.Errorf("Expected %v for a tampered %v, got %v", c.expected, name, err)
+		}
+	}
+	f.eth.tamper = nil
+
+	// a valid proof of another account
+	sender := crypto.PubkeyToAddress(f.key.PublicKey)
+	f.eth.redirect = &sender
+	if _, err := VerifiedAccount(ctx, f.backend, f.client, f.store, nil, nil); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Expected %v for the proof of %v, got %v", ErrMismatch, sender.Hex(), err)
+	}
+	f.eth.redirect = nil
+
+	resp, err := GetProof(ctx, f.client, f.store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resp.Verify(common.Hash{1}); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("Expected %v for the wrong root, got %v", ErrInvalidProof, err)
+	}
+}
@@ -0,0 +1,187 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+/*
+ * Detect proxy contracts and resolve their implementation at a block, so the
+ * implementation ABI can be bound to the proxy address.
+ */
+
+// Backend reads code, storage and calls, ethclient and the simulated backend
+// implement it
+type Backend interface {
+	bind.ContractCaller
+	StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error)
+}
+
+// Kind is the standard a proxy follows
+type Kind string
+
+const (
+	EIP1967       Kind = "EIP-1967"
+	EIP1967Beacon Kind = "EIP-1967 beacon"
+	EIP1822       Kind = "EIP-1822"
+	OpenZeppelin  Kind = "OpenZeppelin"
+	EIP1167       Kind = "EIP-1167"
+)
+
+// slots where the proxies keep their implementation, beacon and admin
+var (
+	ImplementationSlot       = eip1967Slot("eip1967.proxy.implementation")
+	BeaconSlot               = eip1967Slot("eip1967.proxy.beacon")
+	AdminSlot                = eip1967Slot("eip1967.proxy.admin")
+	ProxiableSlot            = crypto.Keccak256Hash([]byte("PROXIABLE"))
+	LegacyImplementationSlot = crypto.Keccak256Hash([]byte("org.zeppelinos.proxy.implementation"))
+	LegacyAdminSlot          = crypto.Keccak256Hash([]byte("org.zeppelinos.proxy.admin"))
+)
+
+var (
+	ErrNotProxy = errors.New("proxy: not a proxy")
+	ErrNoCode   = errors.New("proxy: no code")
+	ErrBeacon   = errors.New("proxy: beacon without implementation")
+)
+
+// eip1967Slot is keccak256(id) - 1, a slot without a known preimage
+func eip1967Slot(id string) common.Hash {
+	slot := new(big.Int).SetBytes(crypto.Keccak256([]byte(id)))
+	return common.BigToHash(slot.Sub(slot, common.Big1))
+}
+
+// the runtime of an EIP-1167 clone around the 20 bytes of its implementation
+var (
+	clonePrefix = common.FromHex("0x363d3d373d3d3d363d73")
+	cloneSuffix = common.FromHex("0x5af43d82803e903d91602b57fd5bf3")
+)
+
+// implementationSelector is that of implementation() on a beacon
+var implementationSelector = crypto.Keccak256([]byte("implementation()"))[:4]
+
+// Proxy is a detected proxy
+type Proxy struct {
+	Kind    Kind
+	Address common.Address
+	// Implementation is the contract the proxy delegates to
+	Implementation common.Address
+	// Beacon is set for beacon proxies, Admin when the proxy stores one
+	Beacon common.Address
+	Admin  common.Address
+}
+
+// Detect detects the proxy at address at block, nil being the latest. It
+// returns ErrNotProxy when the contract is none of the known kinds
+func Detect(ctx context.Context, backend Backend, address common.Address, block *big.Int) (*Proxy, error) {
+	code, err := backend.CodeAt(ctx, address, block)
+	if err != nil {
+		return nil, err
+	}
+	if len(code) == 0 {
+		return nil, fmt.Errorf("%w at %s", ErrNoCode, address.Hex())
+	}
+	if implementation, ok := Clone(code); ok {
+		return &Proxy{Kind: EIP1167, Address: address, Implementation: implementation}, nil
+	}
+
+	p := &Proxy{Address: address}
+	read := func(slot common.Hash) (common.Address, error) {
+		value, err := backend.StorageAt(ctx, address, slot, block)
+		if err != nil {
+			return common.Address{}, err
+		}
+		return common.BytesToAddress(value), nil
+	}
+
+	if p.Implementation, err = read(ImplementationSlot); err != nil {
+		return nil, err
+	}
+	if p.Implementation != (common.Address{}) {
+		p.Kind = EIP1967
+	} else {
+		if p.Beacon, err = read(BeaconSlot); err != nil {
+			return nil, err
+		}
+		if p.Beacon != (common.Address{}) {
+			p.Kind = EIP1967Beacon
+			if p.Implementation, err = beaconImplementation(ctx, backend, p.Beacon, block); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if p.Kind != "" {
+		if p.Admin, err = read(AdminSlot); err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+
+	if p.Implementation, err = read(ProxiableSlot); err != nil {
+		return nil, err
+	}
+	if p.Implementation != (common.Address{}) {
+		p.Kind = EIP1822
+		return p, nil
+	}
+
+	if p.Implementation, err = read(LegacyImplementationSlot); err != nil {
+		return nil, err
+	}
+	if p.Implementation != (common.Address{}) {
+		p.Kind = OpenZeppelin
+		if p.Admin, err = read(LegacyAdminSlot); err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotProxy, address.Hex())
+}
+
+// beaconImplementation calls implementation() on beacon
+func beaconImplementation(ctx context.Context, backend Backend, beacon common.Address, block *big.Int) (common.Address, error) {
+	output, err := backend.CallContract(ctx, ethereum.CallMsg{To: &beacon, Data: implementationSelector}, block)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if len(output) < 32 || common.BytesToAddress(output[:32]) == (common.Address{}) {
+		return common.Address{}, fmt.Errorf("%w: %s", ErrBeacon, beacon.Hex())
+	}
+	return common.BytesToAddress(output[:32]), nil
+}
+
+// Clone returns the implementation of EIP-1167 clone code
+func Clone(code []byte) (common.Address, bool) {
+	if len(code) != len(clonePrefix)+common.AddressLength+len(cloneSuffix) ||
+		!bytes.HasPrefix(code, clonePrefix) || !bytes.HasSuffix(code, cloneSuffix) {
+		return common.Address{}, false
+	}
+	return common.BytesToAddress(code[len(clonePrefix) : len(clonePrefix)+common.AddressLength]), true
+}
+
+// maxDepth bounds the proxies followed by Resolve
+const maxDepth = 8
+
+// Resolve follows proxies from address, a clone of a proxy for instance, and
+// returns the contract finally delegated to. An address that is no proxy is
+// returned as is
+func Resolve(ctx context.Context, backend Backend, address common.Address, block *big.Int) (common.Address, error) {
+	for i := 0; i < maxDepth; i++ {
+		p, err := Detect(ctx, backend, address, block)
+		if errors.Is(err, ErrNotProxy) {
+			return address, nil
+		}
+		if err != nil {
+			return common.Address{}, err
+		}
+		address = p.Implementation
+	}
+	return common.Address{}, fmt.Errorf("proxy: more than %d proxies from %s", maxDepth, address.Hex())
+}
@@ -0,0 +1,142 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestSlots(t *testing.T) {
+	t.Parallel()
+	expected := map[common.Hash]string{
+		ImplementationSlot:       "0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc",
+		BeaconSlot:               "0xa3f0ad74e5423aebfd80d3ef4346578335a9a72aeaee59ff6cb3582b35133d50",
+		AdminSlot:                "0xb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d6103",
+		ProxiableSlot:            "0xc5f16f0fcc639fa48a6947836d9850f504798523bf8c9a3a87d5876cf622bcf7",
+		LegacyImplementationSlot: "0x7050c9e0f4ca769c69bd3a8ef740bc37934f8e2c036e5a723fd8ee048ed3f8c3",
+		LegacyAdminSlot:          "0x10d6a54a4754c8869d6886b5f5d7fbfa5b4522237ea5c60d11bc4e7a1ff9390b",
+	}
+	for slot, hex := range expected {
+		if slot.Hex() != hex {
+			t.Errorf("Expected %v, got %v", hex, slot.Hex())
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	t.Parallel()
+	code := common.FromHex("0x363d3d373d3d3d363d73bebebebebebebebebebebebebebebebebebebebe5af43d82803e903d91602b57fd5bf3")
+	if implementation, ok := Clone(code); !ok || implementation != common.HexToAddress("0xbebebebebebebebebebebebebebebebebebebebe") {
+		t.Errorf("Expected the clone implementation, got %v", implementation.Hex())
+	}
+	if _, ok := Clone(code[:len(code)-1]); ok {
+		t.Errorf("Expected no clone")
+	}
+}
+
+// account is a genesis contract
+func account(code []byte, storage map[common.Hash]common.Hash) core.GenesisAccount {
+	return core.GenesisAccount{Code: code, Storage: storage, Balance: new(big.Int)}
+}
+
+func TestDetect(t *testing.T) {
+	t.Parallel()
+	key, _ := crypto.GenerateKey()
+	auth, _ := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+
+	var (
+		implementation = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		upgraded       = common.HexToAddress("0x1000000000000000000000000000000000000002")
+		admin          = common.HexToAddress("0x2000000000000000000000000000000000000001")
+		transparent    = common.HexToAddress("0x3000000000000000000000000000000000000001")
+		beaconProxy    = common.HexToAddress("0x3000000000000000000000000000000000000002")
+		beacon         = common.HexToAddress("0x3000000000000000000000000000000000000003")
+		uups           = common.HexToAddress("0x3000000000000000000000000000000000000004")
+		legacy         = common.HexToAddress("0x3000000000000000000000000000000000000005")
+		clone          = common.HexToAddress("0x3000000000000000000000000000000000000006")
+		plain          = common.HexToAddress("0x3000000000000000000000000000000000000007")
+	)
+	stop := []byte{0x00}
+	// stores its calldata in the implementation slot, an upgrade
+	upgrade := append(append(common.FromHex("0x6000357f"), ImplementationSlot[:]...), 0x55, 0x00)
+	// implementation() returns the address in slot 0
+	returnSlot0 := common.FromHex("0x60005460005260206000f3")
+	cloneCode := append(append(append([]byte{}, clonePrefix...), transparent[:]...), cloneSuffix...)
+
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{
+		auth.From:      {Balance: big.NewInt(1000000000000000000)},
+		implementation: account(stop, nil),
+		upgraded:       account(stop, nil),
+		transparent: account(upgrade, map[common.Hash]common.Hash{
+			ImplementationSlot: implementation.Hash(),
+			AdminSlot:          admin.Hash(),
+		}),
+		beaconProxy: account(stop, map[common.Hash]common.Hash{BeaconSlot: beacon.Hash()}),
+		beacon:      account(returnSlot0, map[common.Hash]common.Hash{{}: implementation.Hash()}),
+		uups:        account(stop, map[common.Hash]common.Hash{ProxiableSlot: implementation.Hash()}),
+		legacy: account(stop, map[common.Hash]common.Hash{
+			LegacyImplementationSlot: implementation.Hash(),
+			LegacyAdminSlot:          admin.Hash(),
+		}),
+		clone: account(cloneCode, nil),
+		plain: account(stop, nil),
+	}, 8000000)
+	defer backend.Close()
+
+	ctx := context.Background()
+	expected := []Proxy{
+		{Kind: EIP1967, Address: transparent, Implementation: implementation, Admin: admin},
+		{Kind: EIP1967Beacon, Address: beaconProxy, Implementation: implementation, Beacon: beacon},
+		{Kind: EIP1822, Address: uups, Implementation: implementation},
+		{Kind: OpenZeppelin, Address: legacy, Implementation: implementation, Admin: admin},
+		{Kind: EIP1167, Address: clone, Implementation: transparent},
+	}
+	for _, e := range expected {
+		p, err := Detect(ctx, backend, e.Address, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *p != e {
+			t.Errorf("Expected %+v, got %+v", e, *p)
+		}
+	}
+
+	if _, err := Detect(ctx, backend, plain, nil); !errors.Is(err, ErrNotProxy) {
+		t.Errorf("Expected %v, got %v", ErrNotProxy, err)
+	}
+	if _, err := Detect(ctx, backend, admin, nil); !errors.Is(err, ErrNoCode) {
+		t.Errorf("Expected %v, got %v", ErrNoCode, err)
+	}
+
+	// upgrade the transparent proxy, the old implementation stays at block 0
+	proxy := bind.NewBoundContract(transparent, abi.ABI{}, backend, backend, backend)
+	if _, err := proxy.RawTransact(auth, upgraded.Hash().Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	backend.Commit()
+	for block, e := range map[int64]common.Address{0: implementation, 1: upgraded} {
+		p, err := Detect(ctx, backend, transparent, big.NewInt(block))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Implementation != e {
+			t.Errorf("Expected %v at block %d, got %v", e.Hex(), block, p.Implementation.Hex())
+		}
+	}
+
+	// the clone of the proxy resolves to the implementation behind both
+	if address, err := Resolve(ctx, backend, clone, nil); err != nil || address != upgraded {
+		t.Errorf("Expected %v, got %v %v", upgraded.Hex(), address.Hex(), err)
+	}
+	if address, err := Resolve(ctx, backend, plain, nil); err != nil || address != plain {
+		t.Errorf("Expected %v, got %v %v", plain.Hex(), address.Hex(), err)
+	}
+}
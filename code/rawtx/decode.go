@@ -0,0 +1,230 @@
+package rawtx
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"ethereum-development-with-go/code/calldata"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+/*
+ * Decode raw transactions of every type and check their signatures.
+ */
+
+var (
+	// ErrInvalidSignature is returned for V, R or S values no valid signer produces
+	ErrInvalidSignature = errors.New("rawtx: invalid signature values")
+	// ErrMalleable is returned for signatures with S in the upper half of the
+	// curve order, rejected since EIP-2
+	ErrMalleable = errors.New("rawtx: malleable signature, s is in the upper half of the curve order")
+	// ErrChainID is returned when a replay protected transaction targets another chain
+	ErrChainID = errors.New("rawtx: chain id mismatch")
+
+	curveOrder     = crypto.S256().Params().N
+	halfCurveOrder = new(big.Int).Rsh(curveOrder, 1)
+)
+
+// Signer names reported in Decoded
+const (
+	SignerHomestead = "homestead"
+	SignerEIP155    = "eip155"
+	SignerEIP2930   = "eip2930"
+	SignerLondon    = "london"
+)
+
+// typeNames maps transaction types to their EIP
+var typeNames = map[uint8]string{
+	types.LegacyTxType:     "legacy",
+	types.AccessListTxType: "eip2930",
+	types.DynamicFeeTxType: "eip1559",
+}
+
+// Decoded is the breakdown of a raw transaction
+type Decoded struct {
+	Type       uint8              `json:"type"`
+	TypeName   string             `json:"typeName"`
+	Hash       common.Hash        `json:"hash"`
+	Size       common.StorageSize `json:"size"`
+	ChainID    *big.Int           `json:"chainId,omitempty"`
+	Nonce      uint64             `json:"nonce"`
+	GasPrice   *big.Int           `json:"gasPrice,omitempty"`
+	GasTipCap  *big.Int           `json:"maxPriorityFeePerGas,omitempty"`
+	GasFeeCap  *big.Int           `json:"maxFeePerGas,omitempty"`
+	Gas        uint64             `json:"gas"`
+	To         *common.Address    `json:"to"`
+	Value      *big.Int           `json:"value"`
+	Data       hexutil.Bytes      `json:"input"`
+	AccessList types.AccessList   `json:"accessList,omitempty"`
+	V          *big.Int           `json:"v"`
+	R          *big.Int           `json:"r"`
+	S          *big.Int           `json:"s"`
+	// Protected reports EIP-155 replay protection, always true for typed transactions
+	Protected bool           `json:"protected"`
+	Signer    string         `json:"signer"`
+	From      common.Address `json:"from"`
+	// Call is the decoded calldata when the selector is known
+	Call     *calldata.Call `json:"call,omitempty"`
+	Warnings []string       `json:"warnings,omitempty"`
+	// Tx is the decoded transaction
+	Tx *types.Transaction `json:"-"`
+}
+
+// JSON returns the indented JSON breakdown
+func (d *Decoded) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// Decoder decodes raw transactions
+type Decoder struct {
+	// ChainID, when set, is the chain replay protected transactions must target
+	ChainID *big.Int
+	// Calls, when set, decodes the calldata
+	Calls *calldata.Decoder
+}
+
+// NewDecoder returns a decoder checking chainID, which may be nil, and
+// decoding calldata with calls, which may be nil
+func NewDecoder(chainID *big.Int, calls *calldata.Decoder) *Decoder {
+	return &Decoder{ChainID: chainID, Calls: calls}
+}
+
+// DecodeHex decodes a hex raw transaction, with or without 0x
+func (d *Decoder) DecodeHex(s string) (*Decoded, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "0x")
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("rawtx: invalid hex: %w", err)
+	}
+
+	return d.Decode(raw)
+}
+
+// Decode decodes a raw transaction in its binary encoding, a legacy RLP list
+// or an EIP-2718 typed envelope. A typed transaction wrapped in an RLP
+// string, as found inside blocks, is accepted too.
+func (d *Decoder) Decode(raw []byte) (*Decoded, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		if len(raw) == 0 || raw[0] < 0x80 || raw[0] >= 0xc0 || rlp.DecodeBytes(raw, tx) != nil {
+			return nil, fmt.Errorf("rawtx: %w", err)
+		}
+	}
+
+	signer, name := SignerFor(tx)
+	if err := ValidateSignature(tx); err != nil {
+		return nil, err
+	}
+
+	out := &Decoded{
+		Type:       tx.Type(),
+		TypeName:   typeNames[tx.Type()],
+		Hash:       tx.Hash(),
+		Size:       tx.Size(),
+		Nonce:      tx.Nonce(),
+		Gas:        tx.Gas(),
+		To:         tx.To(),
+		Value:      tx.Value(),
+		Data:       tx.Data(),
+		AccessList: tx.AccessList(),
+		Protected:  tx.Protected(),
+		Signer:     name,
+		Tx:         tx,
+	}
+	out.V, out.R, out.S = tx.RawSignatureValues()
+	if tx.Protected() {
+		out.ChainID = tx.ChainId()
+	}
+	if tx.Type() == types.DynamicFeeTxType {
+		out.GasTipCap = tx.GasTipCap()
+		out.GasFeeCap = tx.GasFeeCap()
+	} else {
+		out.GasPrice = tx.GasPrice()
+	}
+
+	if d.ChainID != nil {
+		if !tx.Protected() {
+			out.Warnings = append(out.Warnings, "not replay protected, valid on every chain")
+		} else if tx.ChainId().Cmp(d.ChainID) != 0 {
+			return nil, fmt.Errorf("%w: transaction is for chain %v, expected %v", ErrChainID, tx.ChainId(), d.ChainID)
+		}
+	}
+
+	from, err := types.Sender(signer, tx)
+	if err != nil {
+		return nil, fmt.Errorf("rawtx: recovering sender: %w", err)
+	}
+	out.From = from
+
+	if tx.To() == nil {
+		out.Warnings = append(out.Warnings, "contract creation")
+	} else if d.Calls != nil && len(tx.Data()) >= 4 {
+		if call, err := d.Calls.Decode(tx.Data()); err == nil {
+			out.Call = call
+		} else {
+			out.Warnings = append(out.Warnings, "calldata: "+err.Error())
+		}
+	}
+
+	return out, nil
+}
+
+// SignerFor returns the signer a transaction was signed with and its name
+func SignerFor(tx *types.Transaction) (types.Signer, string) {
+	switch {
+	case tx.Type() == types.DynamicFeeTxType:
+		return types.NewLondonSigner(tx.ChainId()), SignerLondon
+	case tx.Type() == types.AccessListTxType:
+		return types.NewEIP2930Signer(tx.ChainId()), SignerEIP2930
+	case tx.Protected():
+		return types.NewEIP155Signer(tx.ChainId()), SignerEIP155
+	default:
+		return types.HomesteadSigner{}, SignerHomestead
+	}
+}
+
+// RecoveryID returns the 0 or 1 recovery id encoded in V
+func RecoveryID(tx *types.Transaction) (byte, error) {
+	v, _, _ := tx.RawSignatureValues()
+
+	id := new(big.Int).Set(v)
+	switch {
+	case tx.Type() != types.LegacyTxType:
+	case tx.Protected():
+		// v = chainId * 2 + 35 + id
+		id.Sub(id, new(big.Int).Mul(tx.ChainId(), big.NewInt(2)))
+		id.Sub(id, big.NewInt(35))
+	default:
+		id.Sub(id, big.NewInt(27))
+	}
+	if !id.IsUint64() || id.Uint64() > 1 {
+		return 0, fmt.Errorf("%w: v=%v", ErrInvalidSignature, v)
+	}
+
+	return byte(id.Uint64()), nil
+}
+
+// ValidateSignature checks V, R and S, rejecting malleable signatures
+func ValidateSignature(tx *types.Transaction) error {
+	_, r, s := tx.RawSignatureValues()
+	id, err := RecoveryID(tx)
+	if err != nil {
+		return err
+	}
+	if s.Cmp(halfCurveOrder) > 0 && s.Cmp(curveOrder) < 0 {
+		return ErrMalleable
+	}
+	if !crypto.ValidateSignatureValues(id, r, s, true) {
+		return fmt.Errorf("%w: r or s out of range", ErrInvalidSignature)
+	}
+
+	return nil
+}
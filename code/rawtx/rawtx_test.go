@@ -0,0 +1,204 @@
+package rawtx
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"ethereum-development-with-go/code/calldata"
+	"ethereum-development-with-go/code/sigdb"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	key, _  = crypto.HexToECDSA("fad9c8855b740a0b7ed4c221dbad0f33a83a49cad6b3fe8d5817ac83d38b6a19")
+	from    = crypto.PubkeyToAddress(key.PublicKey)
+	to      = common.HexToAddress("0x4592d8f8d7b001e72cb26a73e4fa1806a51ac79d")
+	chainID = big.NewInt(1337)
+
+	// transfer(0x4592…c79d, 1000 tokens)
+	transfer = hexutil.MustDecode("0xa9059cbb0000000000000000000000004592d8f8d7b001e72cb26a73e4fa1806a51ac79d00000000000000000000000000000000000000000000003635c9adc5dea00000")
+)
+
+// signed returns a transaction of every type, keyed by the signer name
+func signed(t *testing.T) map[string]*types.Transaction {
+	legacy := &types.LegacyTx{Nonce: 1, GasPrice: big.NewInt(1e9), Gas: 60000, To: &to, Data: transfer}
+	txs := map[string]*types.Transaction{}
+	for name, pair := range map[string]struct {
+		signer types.Signer
+		data   types.TxData
+	}{
+		SignerHomestead: {types.HomesteadSigner{}, legacy},
+		SignerEIP155:    {types.NewEIP155Signer(chainID), legacy},
+		SignerEIP2930: {types.NewEIP2930Signer(chainID), &types.AccessListTx{
+			ChainID: chainID, Nonce: 2, GasPrice: big.NewInt(1e9), Gas: 60000, To: &to, Data: transfer,
+			AccessList: types.AccessList{{Address: to, StorageKeys: []common.Hash{{1}}}},
+		}},
+		SignerLondon: {types.NewLondonSigner(chainID), &types.DynamicFeeTx{
+			ChainID: chainID, Nonce: 3, GasTipCap: big.NewInt(2e9), GasFeeCap: big.NewInt(3e10), Gas: 60000, To: &to, Data: transfer,
+		}},
+	} {
+		tx, err := types.SignNewTx(key, pair.signer, pair.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs[name] = tx
+	}
+
+	return txs
+}
+
+func TestDecodeTypes(t *testing.T) {
+	t.Parallel()
+	decoder := NewDecoder(chainID, calldata.NewDecoder(sigdb.Default()))
+
+	for name, tx := range signed(t) {
+		raw, err := tx.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := decoder.Decode(raw)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if decoded.Signer != name || decoded.From != from || decoded.Hash != tx.Hash() || decoded.Type != tx.Type() {
+			t.Errorf("Expected %v from %v with hash %v, got %v from %v with hash %v", name, from.Hex(), tx.Hash().Hex(), decoded.Signer, decoded.From.Hex(), decoded.Hash.Hex())
+		}
+		if decoded.Call == nil || decoded.Call.Method != "transfer" {
+			t.Errorf("Expected transfer calldata for %v, got %v", name, decoded.Call)
+		}
+		if protected := name != SignerHomestead; decoded.Protected != protected || (len(decoded.Warnings) > 0) == protected {
+			t.Errorf("Expected protected %v for %v, got %v with warnings %v", protected, name, decoded.Protected, decoded.Warnings)
+		}
+	}
+}
+
+func TestDecodeBlockEncoding(t *testing.T) {
+	t.Parallel()
+	tx := signed(t)[SignerLondon]
+
+	// typed transactions inside blocks are wrapped in an RLP string
+	data, _ := tx.MarshalBinary()
+	raw := append([]byte{0xb8, byte(len(data))}, data...)
+	decoded, err := NewDecoder(nil, nil).Decode(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Hash != tx.Hash() || decoded.GasFeeCap.Cmp(tx.GasFeeCap()) != 0 || decoded.GasPrice != nil {
+		t.Errorf("Expected %v, got %v", tx.Hash().Hex(), decoded.Hash.Hex())
+	}
+}
+
+func TestDecodeLegacyVector(t *testing.T) {
+	t.Parallel()
+	// the transaction transaction_raw_decode.go decodes, signed for Rinkeby
+	decoded, err := NewDecoder(nil, nil).DecodeHex("0xf86d82069e843b9aca16825208944592d8f8d7b001e72cb26a73e4fa1806a51ac79d880de0b6b3a7640000802ba0cbd7194eeebccae26b92033d7495c2f63940afae88d4ae7e69af4617b3ea8b79a042ab7bed0b6a37b8e550c3a681dcd16a3eba037dc8bddeb91a2b3aff13c96141")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := common.HexToAddress("0x96216849c49358B10257cb55b28eA603c874b05E"); decoded.From != expected {
+		t.Errorf("Expected %v, got %v", expected.Hex(), decoded.From.Hex())
+	}
+	if decoded.Signer != SignerEIP155 || decoded.ChainID.Int64() != 4 {
+		t.Errorf("Expected eip155 on chain 4, got %v on %v", decoded.Signer, decoded.ChainID)
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	t.Parallel()
+	txs := signed(t)
+
+	raw, _ := txs[SignerLondon].MarshalBinary()
+	if _, err := NewDecoder(big.NewInt(1), nil).Decode(raw); !errors.Is(err, ErrChainID) {
+		t.Errorf("Expected %v, got %v", ErrChainID, err)
+	}
+
+	for name, tx := range txs {
+		// the same signature with s' = n - s and the recovery id flipped
+		signer, _ := SignerFor(tx)
+		_, r, s := tx.RawSignatureValues()
+		id, _ := RecoveryID(tx)
+		sig := make([]byte, 65)
+		r.FillBytes(sig[:32])
+		new(big.Int).Sub(curveOrder, s).FillBytes(sig[32:64])
+		sig[64] = 1 - id
+		malleated, err := tx.WithSignature(signer, sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, _ := malleated.MarshalBinary()
+		if _, err := NewDecoder(nil, nil).Decode(raw); !errors.Is(err, ErrMalleable) {
+			t.Errorf("Expected %v for %v, got %v", ErrMalleable, name, err)
+		}
+
+		if name == SignerLondon {
+			sig[64] = 2
+			broken, _ := tx.WithSignature(signer, sig)
+			raw, _ := broken.MarshalBinary()
+			if _, err := NewDecoder(nil, nil).Decode(raw); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Expected %v, got %v", ErrInvalidSignature, err)
+			}
+		}
+	}
+
+	if _, err := NewDecoder(nil, nil).DecodeHex("0x02c0"); err == nil {
+		t.Error("Expected a decoding error")
+	}
+}
+
+// fakeReader serves transactions by hash
+type fakeReader map[common.Hash]*types.Transaction
+
+func (f fakeReader) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	tx, ok := f[hash]
+	if !ok {
+		return nil, false, ethereum.NotFound
+	}
+	return tx, false, nil
+}
+
+func TestRecoverPublicKey(t *testing.T) {
+	t.Parallel()
+	// transactions signed with key, one per signer variant
+	vectors := map[string]string{
+		SignerHomestead: "0xf8a801843b9aca0082ea60944592d8f8d7b001e72cb26a73e4fa1806a51ac79d80b844a9059cbb0000000000000000000000004592d8f8d7b001e72cb26a73e4fa1806a51ac79d00000000000000000000000000000000000000000000003635c9adc5dea000001ba0fdf986d42f48bbba2d616c5ed0941e3fe59664d8b8543ea7ea54541fa21b20b0a0167530a402f651506f967b804ab622f8e665346b75bfa8d37b2eecf7e0816ba1",
+		SignerEIP155:    "0xf8aa01843b9aca0082ea60944592d8f8d7b001e72cb26a73e4fa1806a51ac79d80b844a9059cbb0000000000000000000000004592d8f8d7b001e72cb26a73e4fa1806a51ac79d00000000000000000000000000000000000000000000003635c9adc5dea00000820a95a09594f24e0cc245f976774744f7db0f89b65c82d4dab793d9074c539826211179a0215d0c5eb3999953374fda4502f484d562b695f427a928a59277ceabc04ad302",
+		SignerEIP2930:   "0x01f8e582053902843b9aca0082ea60944592d8f8d7b001e72cb26a73e4fa1806a51ac79d80b844a9059cbb0000000000000000000000004592d8f8d7b001e72cb26a73e4fa1806a51ac79d00000000000000000000000000000000000000000000003635c9adc5dea00000f838f7944592d8f8d7b001e72cb26a73e4fa1806a51ac79de1a0010000000000000000000000000000000000000000000000000000000000000001a04ec7c220131ff8ebd9e1c421a71db2a2e2e758ad18dbd22854357f7af3b5d682a05d43e800952eb187dc0510d1980beb2b8464d70c163fb9b385d46c3bfe760c1d",
+		SignerLondon:    "0x02f8b28205390384773594008506fc23ac0082ea60944592d8f8d7b001e72cb26a73e4fa1806a51ac79d80b844a9059cbb0000000000000000000000004592d8f8d7b001e72cb26a73e4fa1806a51ac79d00000000000000000000000000000000000000000000003635c9adc5dea00000c080a0d985cd99ce266469a8f0335dee7006930428beec01fe050f5b59f68cbbf0e742a042591dc296fe652c9c1490f7c2c5261364c11006af551716b55a72ca5b67056d",
+	}
+	expected := "0x049a7df67f79246283fdc93af76d4f8cdd62c4886e8cd870944e817dd0b97934fdd7719d0810951e03418205868a5c1b40b192451367f28e0088dd75e15de40c05"
+
+	reader := fakeReader{}
+	for name, raw := range vectors {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(hexutil.MustDecode(raw)); err != nil {
+			t.Fatal(err)
+		}
+		if _, signer := SignerFor(tx); signer != name {
+			t.Errorf("Expected signer %v, got %v", name, signer)
+		}
+		pub, err := RecoverPublicKey(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hexutil.Encode(crypto.FromECDSAPub(pub)); got != expected {
+			t.Errorf("Expected %v for %v, got %v", expected, name, got)
+		}
+		reader[tx.Hash()] = tx
+	}
+
+	for hash := range reader {
+		pub, address, err := PublicKeyByHash(context.Background(), reader, hash)
+		if err != nil || address != from || !pub.Equal(&key.PublicKey) {
+			t.Errorf("Expected %v, got %v %v", from.Hex(), address.Hex(), err)
+		}
+	}
+	if _, _, err := PublicKeyByHash(context.Background(), reader, common.Hash{}); err != ethereum.NotFound {
+		t.Errorf("Expected %v, got %v", ethereum.NotFound, err)
+	}
+}
@@ -0,0 +1,66 @@
+package rawtx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// TransactionReader fetches transactions by hash, as ethclient.Client does
+type TransactionReader interface {
+	TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error)
+}
+
+// Signature returns the 65 byte [R || S || recovery id] signature of a
+// transaction, whatever V encoding its type uses
+func Signature(tx *types.Transaction) ([]byte, error) {
+	if err := ValidateSignature(tx); err != nil {
+		return nil, err
+	}
+	id, err := RecoveryID(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, r, s := tx.RawSignatureValues()
+	sig := make([]byte, crypto.SignatureLength)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:64])
+	sig[crypto.RecoveryIDOffset] = id
+
+	return sig, nil
+}
+
+// RecoverPublicKey returns the public key that signed a transaction of any type
+func RecoverPublicKey(tx *types.Transaction) (*ecdsa.PublicKey, error) {
+	sig, err := Signature(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, _ := SignerFor(tx)
+	pub, err := crypto.SigToPub(signer.Hash(tx).Bytes(), sig)
+	if err != nil {
+		return nil, fmt.Errorf("rawtx: recovering public key: %w", err)
+	}
+
+	return pub, nil
+}
+
+// PublicKeyByHash fetches a transaction and returns its sender's public key and address
+func PublicKeyByHash(ctx context.Context, reader TransactionReader, hash common.Hash) (*ecdsa.PublicKey, common.Address, error) {
+	tx, _, err := reader.TransactionByHash(ctx, hash)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	pub, err := RecoverPublicKey(tx)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+
+	return pub, crypto.PubkeyToAddress(*pub), nil
+}
@@ -0,0 +1,341 @@
+package reverts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"ethereum-development-with-go/code/calldata"
+	"ethereum-development-with-go/code/sigdb"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+/*
+ * Decode revert reasons, panics and custom errors from failed calls.
+ */
+
+// Kind classifies a revert
+type Kind string
+
+// Revert kinds
+const (
+	KindError   Kind = "Error"
+	KindPanic   Kind = "Panic"
+	KindCustom  Kind = "Custom"
+	KindUnknown Kind = "Unknown"
+)
+
+var (
+	errorSelector = sigdb.SelectorOf("Error(string)")
+	panicSelector = sigdb.SelectorOf("Panic(uint256)")
+
+	// ErrNotReverted is returned when replaying a transaction that succeeded
+	ErrNotReverted = errors.New("transaction did not revert")
+	// ErrNotReproduced is returned when a reverted transaction succeeds on replay
+	ErrNotReproduced = errors.New("revert not reproduced on replay")
+)
+
+// PanicReasons maps Solidity panic codes to their meaning
+var PanicReasons = map[uint64]string{
+	0x00: "generic compiler panic",
+	0x01: "assertion failed",
+	0x11: "arithmetic overflow or underflow",
+	0x12: "division or modulo by zero",
+	0x21: "invalid enum conversion",
+	0x22: "invalid storage byte array encoding",
+	0x31: "pop on empty array",
+	0x32: "array index out of bounds",
+	0x41: "out of memory",
+	0x51: "call to zero-initialized internal function",
+}
+
+// RevertError is a decoded execution revert
+type RevertError struct {
+	Kind      Kind
+	Name      string
+	Signature string
+	// Reason is the Error(string) message or the panic description
+	Reason string
+	// Code is the Panic(uint256) code
+	Code *big.Int
+	// Args holds the custom error arguments by name, Values in order
+	Args   map[string]interface{}
+	Values []interface{}
+	Data   []byte
+	// TxHash is set when the revert was obtained by replaying a transaction
+	TxHash common.Hash
+	cause  error
+}
+
+// Error implements the error interface
+func (e *RevertError) Error() string {
+	switch e.Kind {
+	case KindError:
+		return "execution reverted: " + e.Reason
+	case KindPanic:
+		return fmt.Sprintf("execution reverted: panic 0x%x (%s)", e.Code, e.Reason)
+	case KindCustom:
+		names := make([]string, 0, len(e.Args))
+		for name := range e.Args {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		args := make([]string, len(names))
+		for i, name := range names {
+			args[i] = fmt.Sprintf("%s=%v", name, e.Args[name])
+		}
+		return fmt.Sprintf("execution reverted: %s(%s)", e.Name, strings.Join(args, ", "))
+	default:
+		if len(e.Data) == 0 {
+			return "execution reverted"
+		}
+		return "execution reverted: " + hexutil.Encode(e.Data)
+	}
+}
+
+// Unwrap returns the original node error
+func (e *RevertError) Unwrap() error {
+	return e.cause
+}
+
+// Decoder resolves revert data against builtin errors, registered ABIs and
+// a signature database
+type Decoder struct {
+	mu     sync.RWMutex
+	errors map[sigdb.Selector][]abi.Error
+	sigs   *sigdb.DB
+}
+
+// NewDecoder returns a decoder falling back to sigs for custom errors; sigs may be nil
+func NewDecoder(sigs *sigdb.DB) *Decoder {
+	return &Decoder{
+		errors: make(map[sigdb.Selector][]abi.Error),
+		sigs:   sigs,
+	}
+}
+
+// Register adds the custom errors of a contract ABI
+func (d *Decoder) Register(contractAbi abi.ABI) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, e := range contractAbi.Errors {
+		var selector sigdb.Selector
+		copy(selector[:], e.ID[:4])
+		d.errors[selector] = append(d.errors[selector], e)
+	}
+}
+
+// Decode decodes revert data into a RevertError
+func (d *Decoder) Decode(data []byte) *RevertError {
+	revert := &RevertError{Kind: KindUnknown, Data: data}
+	if len(data) < 4 {
+		return revert
+	}
+
+	var selector sigdb.Selector
+	copy(selector[:], data[:4])
+
+	switch selector {
+	case errorSelector:
+		if reason, err := abi.UnpackRevert(data); err == nil {
+			revert.Kind = KindError
+			revert.Name = "Error"
+			revert.Signature = "Error(string)"
+			revert.Reason = reason
+			return revert
+		}
+	case panicSelector:
+		if len(data) == 36 {
+			code := new(big.Int).SetBytes(data[4:])
+			revert.Kind = KindPanic
+			revert.Name = "Panic"
+			revert.Signature = "Panic(uint256)"
+			revert.Code = code
+			revert.Reason = "unknown panic code"
+			if code.IsUint64() {
+				if reason, ok := PanicReasons[code.Uint64()]; ok {
+					revert.Reason = reason
+				}
+			}
+			return revert
+		}
+	}
+
+	d.mu.RLock()
+	registered := d.errors[selector]
+	d.mu.RUnlock()
+
+	for _, e := range registered {
+		if decodeCustom(revert, e.Name, e.Sig, e.Inputs, data, false) {
+			return revert
+		}
+	}
+
+	if d.sigs != nil {
+		for _, sig := range d.sigs.Functions(selector) {
+			name, inputs, err := calldata.ParseSignature(sig)
+			if err != nil {
+				continue
+			}
+			if decodeCustom(revert, name, sig, inputs, data, true) {
+				return revert
+			}
+		}
+	}
+
+	return revert
+}
+
+// decodeCustom fills revert with a custom error if inputs decode the data.
+// Guessed signatures must re-encode to exactly the same bytes.
+func decodeCustom(revert *RevertError, name, sig string, inputs abi.Arguments, data []byte, strict bool) bool {
+	values, err := inputs.Unpack(data[4:])
+	if err != nil {
+		return false
+	}
+	if strict {
+		packed, err := inputs.Pack(values...)
+		if err != nil || !bytes.Equal(packed, data[4:]) {
+			return false
+		}
+	}
+
+	revert.Kind = KindCustom
+	revert.Name = name
+	revert.Signature = sig
+	revert.Values = values
+	revert.Args = make(map[string]interface{}, len(values))
+	for i, input := range inputs {
+		revert.Args[input.Name] = values[i]
+	}
+
+	return true
+}
+
+// RevertData extracts the revert data carried by a node error
+func RevertData(err error) ([]byte, bool) {
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return nil, false
+	}
+
+	switch v := dataErr.ErrorData().(type) {
+	case string:
+		data, err := hexutil.Decode(v)
+		if err != nil {
+			return nil, false
+		}
+		return data, true
+	case []byte:
+		return v, true
+	case hexutil.Bytes:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
+// FromError converts a node error into a *RevertError when it describes an
+// execution revert, returning any other error unchanged
+func (d *Decoder) FromError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var revert *RevertError
+	if errors.As(err, &revert) {
+		return err
+	}
+
+	data, ok := RevertData(err)
+	if !ok && !strings.Contains(err.Error(), "execution reverted") {
+		return err
+	}
+
+	revert = d.Decode(data)
+	revert.cause = err
+	return revert
+}
+
+// CallContract runs an eth_call and decodes a revert into a *RevertError
+func (d *Decoder) CallContract(ctx context.Context, caller ethereum.ContractCaller, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	out, err := caller.CallContract(ctx, msg, blockNumber)
+	return out, d.FromError(err)
+}
+
+// EstimateGas runs eth_estimateGas and decodes a revert into a *RevertError
+func (d *Decoder) EstimateGas(ctx context.Context, estimator ethereum.GasEstimator, msg ethereum.CallMsg) (uint64, error) {
+	gas, err := estimator.EstimateGas(ctx, msg)
+	return gas, d.FromError(err)
+}
+
+// TransactionBackend is what Replay needs from a node
+type TransactionBackend interface {
+	ethereum.ContractCaller
+	ethereum.TransactionReader
+}
+
+// Replay re-executes a mined transaction that reverted as an eth_call at its
+// parent block and returns the decoded revert. Transactions that precede it
+// in the same block are not replayed, so state they changed is not visible.
+func (d *Decoder) Replay(ctx context.Context, backend TransactionBackend, txHash common.Hash) (*RevertError, error) {
+	receipt, err := backend.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+	if receipt.Status == types.ReceiptStatusSuccessful {
+		return nil, ErrNotReverted
+	}
+
+	tx, _, err := backend.TransactionByHash(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := ethereum.CallMsg{
+		From:       from,
+		To:         tx.To(),
+		Gas:        tx.Gas(),
+		Value:      tx.Value(),
+		Data:       tx.Data(),
+		AccessList: tx.AccessList(),
+	}
+	if tx.Type() == types.DynamicFeeTxType {
+		msg.GasFeeCap = tx.GasFeeCap()
+		msg.GasTipCap = tx.GasTipCap()
+	} else {
+		msg.GasPrice = tx.GasPrice()
+	}
+
+	var parent *big.Int
+	if receipt.BlockNumber != nil && receipt.BlockNumber.Sign() > 0 {
+		parent = new(big.Int).Sub(receipt.BlockNumber, big.NewInt(1))
+	}
+
+	_, err = d.CallContract(ctx, backend, msg, parent)
+	if err == nil {
+		return nil, ErrNotReproduced
+	}
+
+	var revert *RevertError
+	if !errors.As(err, &revert) {
+		return nil, err
+	}
+	revert.TxHash = txHash
+	return revert, nil
+}
@@ -0,0 +1,185 @@
+package reverts
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"ethereum-development-with-go/code/sigdb"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const errorsABI = `[{"inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}],"name":"InsufficientBalance","type":"error"}]`
+
+// echoRevert reverts with its calldata:
+// CALLDATASIZE PUSH1 0 PUSH1 0 CALLDATACOPY CALLDATASIZE PUSH1 0 REVERT
+var (
+	echoRevert        = hexutil.MustDecode("0x366000600037366000fd")
+	echoRevertAddress = common.HexToAddress("0x000000000000000000000000000000000000ec40")
+)
+
+// latestCaller lets Replay run on a simulated backend, which only serves the latest block
+type latestCaller struct {
+	*backends.SimulatedBackend
+}
+
+func (c latestCaller) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return c.SimulatedBackend.CallContract(ctx, msg, nil)
+}
+
+func newDecoder(t *testing.T) *Decoder {
+	parsed, err := abi.JSON(strings.NewReader(errorsABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder := NewDecoder(sigdb.Default())
+	decoder.Register(parsed)
+
+	return decoder
+}
+
+func errorData(t *testing.T, sig string, args ...interface{}) []byte {
+	parsed, err := abi.JSON(strings.NewReader(errorsABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := parsed.Errors["InsufficientBalance"].Inputs.Pack(args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	selector := sigdb.SelectorOf(sig)
+	return append(selector[:], data...)
+}
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+	decoder := newDecoder(t)
+
+	{
+		data := hexutil.MustDecode("0x08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000e6e6f7420656e6f7567682065746800000000000000000000000000000000000000")
+		revert := decoder.Decode(data)
+		if revert.Kind != KindError || revert.Reason != "not enough eth" {
+			t.Errorf("Expected Error(not enough eth), got %v", revert)
+		}
+	}
+
+	{
+		data := hexutil.MustDecode("0x4e487b710000000000000000000000000000000000000000000000000000000000000011")
+		revert := decoder.Decode(data)
+		if revert.Kind != KindPanic || revert.Code.Uint64() != 0x11 || revert.Reason != "arithmetic overflow or underflow" {
+			t.Errorf("Expected overflow panic, got %v", revert)
+		}
+		if revert.Error() != "execution reverted: panic 0x11 (arithmetic overflow or underflow)" {
+			t.Errorf("Unexpected message %q", revert.Error())
+		}
+	}
+
+	{
+		revert := decoder.Decode(errorData(t, "InsufficientBalance(uint256,uint256)", big.NewInt(1), big.NewInt(2)))
+		if revert.Kind != KindCustom || revert.Name != "InsufficientBalance" {
+			t.Errorf("Expected InsufficientBalance, got %v", revert)
+		}
+		if revert.Args["required"].(*big.Int).Cmp(big.NewInt(2)) != 0 {
+			t.Errorf("Expected required=2, got %v", revert.Args["required"])
+		}
+		if revert.Error() != "execution reverted: InsufficientBalance(available=1, required=2)" {
+			t.Errorf("Unexpected message %q", revert.Error())
+		}
+	}
+
+	{
+		db := sigdb.New()
+		db.AddFunction("Unauthorized(address)")
+		data, err := abi.Arguments{{Type: mustType(t, "address")}}.Pack(echoRevertAddress)
+		if err != nil {
+			t.Fatal(err)
+		}
+		selector := sigdb.SelectorOf("Unauthorized(address)")
+		revert := NewDecoder(db).Decode(append(selector[:], data...))
+		if revert.Kind != KindCustom || revert.Values[0] != echoRevertAddress {
+			t.Errorf("Expected Unauthorized from sigdb, got %v", revert)
+		}
+	}
+
+	{
+		revert := decoder.Decode(hexutil.MustDecode("0xdeadbeef"))
+		if revert.Kind != KindUnknown || revert.Error() != "execution reverted: 0xdeadbeef" {
+			t.Errorf("Expected unknown revert, got %v", revert)
+		}
+	}
+}
+
+func mustType(t *testing.T, name string) abi.Type {
+	typ, err := abi.NewType(name, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return typ
+}
+
+func TestCallAndReplay(t *testing.T) {
+	t.Parallel()
+	decoder := newDecoder(t)
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	client := backends.NewSimulatedBackend(core.GenesisAlloc{
+		from:              {Balance: big.NewInt(1000000000000000000)},
+		echoRevertAddress: {Code: echoRevert, Balance: big.NewInt(0)},
+	}, 8000000)
+	defer client.Close()
+
+	data := errorData(t, "InsufficientBalance(uint256,uint256)", big.NewInt(1), big.NewInt(2))
+	msg := ethereum.CallMsg{From: from, To: &echoRevertAddress, Data: data}
+
+	_, err = decoder.CallContract(context.Background(), client, msg, nil)
+	var revert *RevertError
+	if !errors.As(err, &revert) || revert.Name != "InsufficientBalance" {
+		t.Fatalf("Expected InsufficientBalance, got %v", err)
+	}
+
+	_, err = decoder.EstimateGas(context.Background(), client, msg)
+	if !errors.As(err, &revert) || revert.Name != "InsufficientBalance" {
+		t.Fatalf("Expected InsufficientBalance, got %v", err)
+	}
+
+	gasPrice, err := client.SuggestGasPrice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := types.SignTx(types.NewTransaction(0, echoRevertAddress, big.NewInt(0), 100000, gasPrice, data), types.LatestSignerForChainID(big.NewInt(1337)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SendTransaction(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+	client.Commit()
+
+	revert, err = decoder.Replay(context.Background(), latestCaller{client}, tx.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revert.Name != "InsufficientBalance" || revert.TxHash != tx.Hash() {
+		t.Errorf("Expected InsufficientBalance for %v, got %v for %v", tx.Hash(), revert.Name, revert.TxHash)
+	}
+
+	other := errors.New("nonce too low")
+	if decoder.FromError(other) != other {
+		t.Errorf("Expected unrelated errors to pass through")
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"ethereum-development-with-go/code/rlptree"
+)
+
+// go run rlp_inspect.go c77b8568656c6c6f
+// go run rlp_inspect.go -json 0x02f86c...
+// cat header.hex | go run rlp_inspect.go
+func main() {
+	asJSON := flag.Bool("json", false, "print JSON instead of a tree")
+	flag.Parse()
+
+	input := flag.Arg(0)
+	if input == "" {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		input = string(data)
+	}
+
+	node, err := rlptree.DecodeHex(input)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *asJSON {
+		out, err := json.MarshalIndent(node, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	// list (2 items)
+	//   [0] 0x7b  uint 123
+	//   [1] 0x68656c6c6f  uint 448378203247, text "hello"
+	if err := rlptree.WriteTree(os.Stdout, node); err != nil {
+		log.Fatal(err)
+	}
+}
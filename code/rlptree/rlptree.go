@@ -0,0 +1,230 @@
+package rlptree
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+/*
+ * Decode RLP without a schema into a tree of lists and byte strings, with
+ * guesses at what each item holds.
+ */
+
+var (
+	// ErrInvalid is returned for input that is not RLP
+	ErrInvalid = errors.New("rlptree: invalid RLP")
+)
+
+// Kinds of node
+const (
+	KindList     = "list"
+	KindBytes    = "bytes"
+	KindEnvelope = "envelope"
+)
+
+// envelopes names the EIP-2718 types, the same byte prefixes transactions
+// and their receipts
+var envelopes = map[byte]string{
+	0x01: "EIP-2930 access list",
+	0x02: "EIP-1559 dynamic fee",
+	0x03: "EIP-4844 blob",
+	0x04: "EIP-7702 set code",
+}
+
+// Node is an item of an RLP tree. Offset and Size locate its encoding in the
+// outermost input, nested RLP included
+type Node struct {
+	Kind   string `json:"kind"`
+	Offset int    `json:"offset"`
+	Size   int    `json:"size"`
+	// Type is the envelope type byte
+	Type        *hexutil.Uint64 `json:"type,omitempty"`
+	Value       hexutil.Bytes   `json:"value,omitempty"`
+	Annotations []string        `json:"annotations,omitempty"`
+	// Items are the list elements, or the payload of an envelope
+	Items []*Node `json:"items,omitempty"`
+	// Nested is the tree of a byte string that is itself RLP
+	Nested *Node `json:"nested,omitempty"`
+}
+
+// Decode decodes data, a single RLP item or a typed envelope
+func Decode(data []byte) (*Node, error) {
+	if node := envelope(data, 0); node != nil {
+		return node, nil
+	}
+	node, rest, err := decode(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes at offset %d", ErrInvalid, len(rest), len(data)-len(rest))
+	}
+	return node, nil
+}
+
+// DecodeHex decodes 0x-prefixed or plain hex
+func DecodeHex(s string) (*Node, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		s = "0x" + s
+	}
+	data, err := hexutil.Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data)
+}
+
+// envelope returns the tree of a type byte followed by a list that takes the
+// rest of data, nil when data is anything else
+func envelope(data []byte, offset int) *Node {
+	if len(data) < 2 || data[0] > 0x7f {
+		return nil
+	}
+	payload, rest, err := decode(data[1:], offset+1)
+	if err != nil || len(rest) > 0 || payload.Kind != KindList {
+		return nil
+	}
+
+	typ := hexutil.Uint64(data[0])
+	node := &Node{Kind: KindEnvelope, Offset: offset, Size: len(data), Type: &typ, Items: []*Node{payload}}
+	if name, ok := envelopes[data[0]]; ok {
+		node.Annotations = []string{name}
+	}
+	return node
+}
+
+// decode decodes the first item of data, offset is where data starts
+func decode(data []byte, offset int) (*Node, []byte, error) {
+	kind, content, rest, err := rlp.Split(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: offset %d: %v", ErrInvalid, offset, err)
+	}
+	size := len(data) - len(rest)
+	start := offset + size - len(content)
+
+	if kind != rlp.List {
+		node := &Node{Kind: KindBytes, Offset: offset, Size: size, Value: common.CopyBytes(content)}
+		node.Annotations, node.Nested = annotate(content, start)
+		return node, rest, nil
+	}
+
+	node := &Node{Kind: KindList, Offset: offset, Size: size, Items: []*Node{}}
+	for items, at := content, start; len(items) > 0; {
+		item, remaining, err := decode(items, at)
+		if err != nil {
+			return nil, nil, err
+		}
+		node.Items = append(node.Items, item)
+		at += len(items) - len(remaining)
+		items = remaining
+	}
+	return node, rest, nil
+}
+
+// annotate guesses what a byte string holds
+func annotate(b []byte, offset int) ([]string, *Node) {
+	var notes []string
+	switch len(b) {
+	case 0:
+		return []string{"empty", "uint 0"}, nil
+	case 20:
+		notes = append(notes, "address "+common.BytesToAddress(b).Hex())
+	case 32:
+		notes = append(notes, "hash")
+	case 64:
+		notes = append(notes, "public key")
+	case 65:
+		notes = append(notes, "signature")
+	}
+	// canonical integers have no leading zeros
+	if len(b) < 32 && len(b) != 20 && b[0] != 0 {
+		notes = append(notes, "uint "+new(big.Int).SetBytes(b).String())
+	}
+	if len(b) >= minText && text(b) {
+		notes = append(notes, fmt.Sprintf("text %q", b))
+	}
+
+	// byte strings wrapping a list or an envelope are nested RLP, like the
+	// typed transactions of a block body
+	nested := envelope(b, offset)
+	if nested == nil && b[0] >= 0xc0 {
+		if node, rest, err := decode(b, offset); err == nil && len(rest) == 0 {
+			nested = node
+		}
+	}
+	if nested != nil {
+		notes = append(notes, "rlp")
+	}
+	return notes, nested
+}
+
+// minText keeps short integers from being read as text
+const minText = 3
+
+// text reports whether b is printable ASCII
+func text(b []byte) bool {
+	for _, c := range b {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// maxValue is the number of bytes a tree line shows of a value
+const maxValue = 64
+
+// WriteTree writes node as an indented tree
+func WriteTree(w io.Writer, node *Node) error {
+	return writeTree(w, node, "", "")
+}
+
+// Tree returns node as an indented tree
+func (n *Node) Tree() string {
+	var b strings.Builder
+	WriteTree(&b, n)
+	return b.String()
+}
+
+func writeTree(w io.Writer, node *Node, indent, label string) error {
+	var line string
+	switch node.Kind {
+	case KindEnvelope:
+		line = fmt.Sprintf("envelope type %d", uint64(*node.Type))
+	case KindList:
+		line = fmt.Sprintf("list (%d items)", len(node.Items))
+	default:
+		line = hexutil.Encode(node.Value)
+		if len(node.Value) > maxValue {
+			line = fmt.Sprintf("%s... (%d bytes)", hexutil.Encode(node.Value[:maxValue]), len(node.Value))
+		}
+	}
+	if len(node.Annotations) > 0 {
+		line += "  " + strings.Join(node.Annotations, ", ")
+	}
+	if _, err := fmt.Fprintf(w, "%s%s%s\n", indent, label, line); err != nil {
+		return err
+	}
+
+	for i, item := range node.Items {
+		label := fmt.Sprintf("[%d] ", i)
+		if node.Kind == KindEnvelope {
+			label = ""
+		}
+		if err := writeTree(w, item, indent+"  ", label); err != nil {
+			return err
+		}
+	}
+	if node.Nested != nil {
+		return writeTree(w, node.Nested, indent+"  ", "rlp: ")
+	}
+	return nil
+}
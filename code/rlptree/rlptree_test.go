@@ -0,0 +1,131 @@
+package rlptree
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var to = common.HexToAddress("0x4592d8f8d7b001e72cb26a73e4fa1806a51ac79d")
+
+func signedTx(t *testing.T) *types.Transaction {
+	key, _ := crypto.HexToECDSA("fad9c8855b740a0b7ed4c221dbad0f33a83a49cad6b3fe8d5817ac83d38b6a19")
+	tx, err := types.SignNewTx(key, types.NewLondonSigner(big.NewInt(1337)), &types.DynamicFeeTx{
+		ChainID:   big.NewInt(1337),
+		Nonce:     7,
+		GasTipCap: big.NewInt(1e9),
+		GasFeeCap: big.NewInt(2e9),
+		Gas:       21000,
+		To:        &to,
+		Value:     big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestDecodeStruct(t *testing.T) {
+	t.Parallel()
+	// the struct of rlp.go, {A: 123, B: "hello"}
+	node, err := DecodeHex("c77b8568656c6c6f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Kind != KindList || len(node.Items) != 2 {
+		t.Fatalf("Expected a list of 2, got %v", node.Tree())
+	}
+	if notes := node.Items[0].Annotations; len(notes) != 1 || notes[0] != "uint 123" {
+		t.Errorf("Expected uint 123, got %v", notes)
+	}
+	if notes := node.Items[1].Annotations; notes[len(notes)-1] != `text "hello"` {
+		t.Errorf("Expected text hello, got %v", notes)
+	}
+}
+
+func TestDecodeEnvelope(t *testing.T) {
+	t.Parallel()
+	tx := signedTx(t)
+	data, _ := tx.MarshalBinary()
+
+	node, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Kind != KindEnvelope || uint64(*node.Type) != 2 || node.Annotations[0] != "EIP-1559 dynamic fee" {
+		t.Fatalf("Expected a dynamic fee envelope, got %v", node.Tree())
+	}
+	payload := node.Items[0]
+	if len(payload.Items) != 12 {
+		t.Fatalf("Expected 12 fields, got %d", len(payload.Items))
+	}
+	recipient := payload.Items[5]
+	if recipient.Annotations[0] != "address "+to.Hex() {
+		t.Errorf("Expected the recipient, got %v", recipient.Annotations)
+	}
+	// offsets point into the input
+	if encoded := data[recipient.Offset : recipient.Offset+recipient.Size]; !bytes.Equal(encoded[1:], to.Bytes()) {
+		t.Errorf("Expected %x at offset %d, got %x", to.Bytes(), recipient.Offset, encoded)
+	}
+
+	// block bodies wrap typed transactions in byte strings
+	body, _ := rlp.EncodeToBytes(types.Transactions{tx})
+	if node, err = Decode(body); err != nil {
+		t.Fatal(err)
+	}
+	nested := node.Items[0].Nested
+	if nested == nil || nested.Kind != KindEnvelope || nested.Offset != node.Items[0].Offset+2 {
+		t.Errorf("Expected the envelope nested in the body, got %v", node.Tree())
+	}
+	if recipient := nested.Items[0].Items[5]; !bytes.Equal(body[recipient.Offset+1:recipient.Offset+recipient.Size], to.Bytes()) {
+		t.Errorf("Expected nested offsets to point into the input, got %d", recipient.Offset)
+	}
+}
+
+func TestDecodeHeader(t *testing.T) {
+	t.Parallel()
+	header := &types.Header{ParentHash: common.Hash{1}, Number: big.NewInt(14582340), Difficulty: big.NewInt(0), GasLimit: 30000000, Extra: []byte("geth")}
+	data, _ := rlp.EncodeToBytes(header)
+	node, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(node.Items) != 15 || node.Items[0].Annotations[0] != "hash" || node.Items[8].Annotations[0] != "uint 14582340" {
+		t.Errorf("Expected a header, got %v", node.Tree())
+	}
+
+	tree := node.Tree()
+	for _, line := range []string{"list (15 items)\n", `  [12] 0x67657468  uint 1734702184, text "geth"`} {
+		if !strings.Contains(tree, line) {
+			t.Errorf("Expected %q in %v", line, tree)
+		}
+	}
+
+	out, err := json.Marshal(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(out), `{"kind":"list","offset":0,"size":`+strconv.Itoa(len(data))) {
+		t.Errorf("Expected a list of %d bytes, got %s", len(data), out[:40])
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	t.Parallel()
+	for _, input := range []string{"c77b8568656c6c", "c77b8568656c6c6f00", "8100", "zz"} {
+		if _, err := DecodeHex(input); err == nil {
+			t.Errorf("Expected an error for %v", input)
+		} else if input != "zz" && !errors.Is(err, ErrInvalid) {
+			t.Errorf("Expected %v for %v, got %v", ErrInvalid, input, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+)
+
+// Writer receives the scan results
+type Writer interface {
+	Write(result Result) error
+	// Flush is called before every checkpoint
+	Flush() error
+}
+
+// CSVWriter writes results as CSV rows
+type CSVWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+// NewCSVWriter returns a CSV writer, header adds a header row before the
+// first result and is usually false when appending to a resumed file
+func NewCSVWriter(w io.Writer, header bool) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w), header: header}
+}
+
+// Write implements Writer
+func (c *CSVWriter) Write(result Result) error {
+	if c.header {
+		c.header = false
+		if err := c.w.Write([]string{"label", "address", "token", "token_address", "balance", "amount"}); err != nil {
+			return err
+		}
+	}
+
+	return c.w.Write([]string{
+		result.Label,
+		result.Address.Hex(),
+		result.Token,
+		result.TokenAddress.Hex(),
+		result.Balance.String(),
+		result.Amount,
+	})
+}
+
+// Flush implements Writer
+func (c *CSVWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// JSONWriter writes one JSON object per result
+type JSONWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewJSONWriter returns a JSON lines writer
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	buf := bufio.NewWriter(w)
+	return &JSONWriter{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+// Write implements Writer
+func (j *JSONWriter) Write(result Result) error {
+	return j.enc.Encode(result)
+}
+
+// Flush implements Writer
+func (j *JSONWriter) Flush() error {
+	return j.buf.Flush()
+}
@@ -0,0 +1,472 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	token "ethereum-development-with-go/code/contracts_erc20"
+	"ethereum-development-with-go/code/util"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+ * Scan token balances of many addresses with bounded concurrency.
+ */
+
+// Defaults used when Config fields are left zero
+const (
+	DefaultWorkers         = 8
+	DefaultMaxRetries      = 5
+	DefaultRetryDelay      = 500 * time.Millisecond
+	DefaultCheckpointEvery = 100
+)
+
+// Backend is the part of a node client the scanner needs
+type Backend interface {
+	bind.ContractCaller
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+}
+
+// Token is an ERC20 token or, with a zero Address, the native coin
+type Token struct {
+	Address  common.Address
+	Symbol   string
+	Decimals int
+}
+
+// Native reports whether the token is the chain's coin
+func (t Token) Native() bool {
+	return t.Address == (common.Address{})
+}
+
+// ParseToken parses "ETH", "0x<address>" or "0x<address>:<symbol>:<decimals>".
+// Decimals is -1 when missing and must be filled by ResolveToken.
+func ParseToken(spec string) (Token, error) {
+	parts := strings.Split(strings.TrimSpace(spec), ":")
+	if len(parts) == 1 && strings.EqualFold(parts[0], "eth") {
+		return Token{Symbol: "ETH", Decimals: 18}, nil
+	}
+	if !util.IsValidAddress(parts[0]) {
+		return Token{}, fmt.Errorf("scanner: invalid token %q", spec)
+	}
+
+	t := Token{Address: common.HexToAddress(parts[0]), Decimals: -1}
+	switch len(parts) {
+	case 1:
+	case 3:
+		decimals, err := strconv.Atoi(parts[2])
+		if err != nil || decimals < 0 || decimals > 77 {
+			return Token{}, fmt.Errorf("scanner: invalid decimals in %q", spec)
+		}
+		t.Symbol, t.Decimals = parts[1], decimals
+	default:
+		return Token{}, fmt.Errorf("scanner: invalid token %q", spec)
+	}
+
+	return t, nil
+}
+
+// ResolveToken reads the symbol and decimals a token spec left out
+func ResolveToken(ctx context.Context, caller bind.ContractCaller, t *Token) error {
+	if t.Native() || (t.Symbol != "" && t.Decimals >= 0) {
+		return nil
+	}
+	instance, err := token.NewTokenCaller(t.Address, caller)
+	if err != nil {
+		return err
+	}
+
+	opts := &bind.CallOpts{Context: ctx}
+	if t.Decimals < 0 {
+		decimals, err := instance.Decimals(opts)
+		if err != nil {
+			return fmt.Errorf("scanner: decimals of %s: %w", t.Address.Hex(), err)
+		}
+		t.Decimals = int(decimals)
+	}
+	if t.Symbol == "" {
+		symbol, err := instance.Symbol(opts)
+		if err != nil {
+			// some early tokens return bytes32, the address is still unambiguous
+			symbol = t.Address.Hex()
+		}
+		t.Symbol = strings.TrimRight(symbol, "\x00")
+	}
+
+	return nil
+}
+
+// Result is a non-zero balance
+type Result struct {
+	Label   string         `json:"label"`
+	Address common.Address `json:"address"`
+	Token   string         `json:"token"`
+	// TokenAddress is the zero address for the native coin
+	TokenAddress common.Address `json:"tokenAddress"`
+	// Balance is in base units, Amount has the decimals applied
+	Balance *big.Int `json:"balance"`
+	Amount  string   `json:"amount"`
+}
+
+// Config configures a Scanner
+type Config struct {
+	Tokens []Token
+	// Workers bounds the addresses scanned concurrently
+	Workers int
+	// RequestsPerSecond limits the calls to the node, zero is unlimited
+	RequestsPerSecond float64
+	// MaxRetries bounds retries of a failed call, RetryDelay is the initial
+	// backoff between them, doubled each time
+	MaxRetries int
+	RetryDelay time.Duration
+	// BlockNumber pins the scan to a block, nil scans the latest one
+	BlockNumber *big.Int
+	// Checkpoint is a file recording how many addresses of the source are
+	// done, so a later run with the same source resumes after them
+	Checkpoint string
+	// CheckpointEvery is the number of addresses between checkpoint writes
+	CheckpointEvery int
+	// Output is the file the Writer writes to, if any. Its size is saved with
+	// the checkpoint, and a resumed run truncates the rows flushed after it
+	// so they are not written twice
+	Output *os.File
+	// OnProgress is called with the number of addresses done
+	OnProgress func(done uint64)
+}
+
+// Scanner checks the balances of many addresses
+type Scanner struct {
+	backend Backend
+	cfg     Config
+	callers map[common.Address]*token.TokenCaller
+}
+
+// New returns a scanner, tokens must already be resolved
+func New(backend Backend, cfg Config) (*Scanner, error) {
+	if cfg.Workers == 0 {
+		cfg.Workers = DefaultWorkers
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = DefaultMaxRetries
+	}
+	if cfg.RetryDelay == 0 {
+		cfg.RetryDelay = DefaultRetryDelay
+	}
+	if cfg.CheckpointEvery == 0 {
+		cfg.CheckpointEvery = DefaultCheckpointEvery
+	}
+
+	callers := make(map[common.Address]*token.TokenCaller)
+	for _, t := range cfg.Tokens {
+		if t.Decimals < 0 {
+			return nil, fmt.Errorf("scanner: token %s is not resolved", t.Address.Hex())
+		}
+		if t.Native() {
+			continue
+		}
+		caller, err := token.NewTokenCaller(t.Address, backend)
+		if err != nil {
+			return nil, err
+		}
+		callers[t.Address] = caller
+	}
+
+	return &Scanner{backend: backend, cfg: cfg, callers: callers}, nil
+}
+
+// job is a target and its position in the source
+type job struct {
+	seq    uint64
+	target Target
+}
+
+// done is the outcome of a job
+type done struct {
+	seq     uint64
+	results []Result
+	err     error
+}
+
+// Run scans every address of src and writes the non-zero balances to out in
+// source order. The checkpoint only moves past addresses whose results are
+// written, so after a failure or cancellation Run can be called again.
+func (s *Scanner) Run(ctx context.Context, src Source, out Writer) error {
+	cp, err := readCheckpoint(s.cfg.Checkpoint)
+	if err != nil {
+		return err
+	}
+	start := cp.Next
+	if s.cfg.Output != nil && cp.Offset != nil {
+		if err := s.cfg.Output.Truncate(*cp.Offset); err != nil {
+			return err
+		}
+		if _, err := s.cfg.Output.Seek(*cp.Offset, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wait := func() error { return nil }
+	if s.cfg.RequestsPerSecond > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / s.cfg.RequestsPerSecond))
+		defer ticker.Stop()
+		wait = func() error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+				return nil
+			}
+		}
+	}
+
+	jobs := make(chan job)
+	dones := make(chan done)
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		for seq := uint64(0); ; seq++ {
+			target, err := src.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				select {
+				case dones <- done{seq: seq, err: err}:
+				case <-ctx.Done():
+				}
+				return
+			}
+			if seq < start {
+				continue
+			}
+			select {
+			case jobs <- job{seq, target}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < s.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				results, err := s.scan(ctx, j.target, wait)
+				select {
+				case dones <- done{j.seq, results, err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(dones)
+	}()
+
+	// results are written in source order, pending holds those that
+	// finished ahead of an earlier address
+	next, saved := start, start
+	pending := make(map[uint64][]Result)
+	var firstErr, writeErr error
+	for d := range dones {
+		if d.err != nil {
+			if firstErr == nil {
+				firstErr = d.err
+				cancel()
+			}
+			continue
+		}
+		// addresses finished before a failure still count
+		if writeErr != nil {
+			continue
+		}
+
+		pending[d.seq] = d.results
+		for writeErr == nil {
+			results, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			for _, result := range results {
+				if writeErr = out.Write(result); writeErr != nil {
+					break
+				}
+			}
+			if writeErr == nil {
+				next++
+			}
+		}
+		if writeErr == nil && next-saved >= uint64(s.cfg.CheckpointEvery) {
+			writeErr = s.save(out, next)
+			saved = next
+		}
+		if writeErr != nil {
+			if firstErr == nil {
+				firstErr = writeErr
+			}
+			cancel()
+			continue
+		}
+		if s.cfg.OnProgress != nil {
+			s.cfg.OnProgress(next)
+		}
+	}
+
+	if writeErr == nil {
+		if err := s.save(out, next); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+
+	return firstErr
+}
+
+// save flushes out and then records next and the output size as the
+// checkpoint
+func (s *Scanner) save(out Writer, next uint64) error {
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	cp := checkpoint{Next: next}
+	if s.cfg.Output != nil {
+		info, err := s.cfg.Output.Stat()
+		if err != nil {
+			return err
+		}
+		size := info.Size()
+		cp.Offset = &size
+	}
+	return writeCheckpoint(s.cfg.Checkpoint, cp)
+}
+
+// scan reads the balances of one address
+func (s *Scanner) scan(ctx context.Context, target Target, wait func() error) ([]Result, error) {
+	var results []Result
+	for _, t := range s.cfg.Tokens {
+		var balance *big.Int
+		err := s.retry(ctx, wait, func() error {
+			var err error
+			if t.Native() {
+				balance, err = s.backend.BalanceAt(ctx, target.Address, s.cfg.BlockNumber)
+			} else {
+				balance, err = s.callers[t.Address].BalanceOf(&bind.CallOpts{Context: ctx, BlockNumber: s.cfg.BlockNumber}, target.Address)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("scanner: %s balance of %s: %w", t.Symbol, target.Address.Hex(), err)
+		}
+		if balance.Sign() == 0 {
+			continue
+		}
+
+		amount, err := util.FormatUnits(balance, t.Decimals, nil)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, Result{
+			Label:        target.Label,
+			Address:      target.Address,
+			Token:        t.Symbol,
+			TokenAddress: t.Address,
+			Balance:      balance,
+			Amount:       amount,
+		})
+	}
+
+	return results, nil
+}
+
+// retry runs call until it succeeds, fails permanently or runs out of retries
+func (s *Scanner) retry(ctx context.Context, wait func() error, call func() error) error {
+	delay := s.cfg.RetryDelay
+	for attempt := 0; ; attempt++ {
+		if err := wait(); err != nil {
+			return err
+		}
+		err := call()
+		if err == nil || ctx.Err() != nil || permanent(err) || attempt >= s.cfg.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// permanent reports errors retrying cannot fix, such as a token without code
+func permanent(err error) bool {
+	return errors.Is(err, bind.ErrNoCode) || strings.Contains(err.Error(), "execution reverted")
+}
+
+// checkpoint is the content of a checkpoint file
+type checkpoint struct {
+	// Next is the number of source addresses already done
+	Next uint64 `json:"next"`
+	// Offset is the output size then, nil when unknown
+	Offset *int64 `json:"offset,omitempty"`
+}
+
+// readCheckpoint reads the checkpoint at path, a zero one when missing
+func readCheckpoint(path string) (checkpoint, error) {
+	var cp checkpoint
+	if path == "" {
+		return cp, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return cp, err
+	}
+
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return cp, fmt.Errorf("scanner: invalid checkpoint %s: %w", path, err)
+	}
+	return cp, nil
+}
+
+// writeCheckpoint replaces the checkpoint file atomically
+func writeCheckpoint(path string, cp checkpoint) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
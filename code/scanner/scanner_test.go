@@ -0,0 +1,295 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	token "ethereum-development-with-go/code/contracts_erc20"
+	"ethereum-development-with-go/code/hdwallet"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	usdt     = common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	tokenABI = mustABI()
+)
+
+func mustABI() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(token.TokenABI))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// fakeBackend gives address n a balance of n ETH wei and n*1000000 USDT base
+// units when n is even. Every first call for an address fails transiently.
+type fakeBackend struct {
+	mu     sync.Mutex
+	failed map[common.Address]bool
+	broken common.Address
+}
+
+func (f *fakeBackend) flaky(account common.Address) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if account == f.broken {
+		return errors.New("execution reverted")
+	}
+	if !f.failed[account] {
+		f.failed[account] = true
+		return errors.New("429 Too Many Requests")
+	}
+	return nil
+}
+
+func amountOf(account common.Address) int64 {
+	n := new(big.Int).SetBytes(account.Bytes()).Int64()
+	if n%2 == 1 {
+		return 0
+	}
+	return n
+}
+
+func (f *fakeBackend) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	if err := f.flaky(account); err != nil {
+		return nil, err
+	}
+	return big.NewInt(amountOf(account)), nil
+}
+
+func (f *fakeBackend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (f *fakeBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	method, err := tokenABI.MethodById(call.Data)
+	if err != nil {
+		return nil, err
+	}
+	switch method.Name {
+	case "decimals":
+		return method.Outputs.Pack(uint8(6))
+	case "symbol":
+		return method.Outputs.Pack("USDT")
+	case "balanceOf":
+		args, err := method.Inputs.Unpack(call.Data[4:])
+		if err != nil {
+			return nil, err
+		}
+		return method.Outputs.Pack(big.NewInt(amountOf(args[0].(common.Address)) * 1000000))
+	}
+	return nil, errors.New("execution reverted")
+}
+
+func addressList(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "%d#%s\n", i, common.BigToAddress(big.NewInt(int64(i))).Hex())
+	}
+	return b.String()
+}
+
+func TestParseToken(t *testing.T) {
+	t.Parallel()
+	for spec, expected := range map[string]Token{
+		"eth":                  {Symbol: "ETH", Decimals: 18},
+		usdt.Hex():             {Address: usdt, Decimals: -1},
+		usdt.Hex() + ":USDT:6": {Address: usdt, Symbol: "USDT", Decimals: 6},
+	} {
+		got, err := ParseToken(spec)
+		if err != nil || got != expected {
+			t.Errorf("Expected %v for %q, got %v %v", expected, spec, got, err)
+		}
+	}
+	if _, err := ParseToken("0x1234:X"); err == nil {
+		t.Error("Expected an invalid token error")
+	}
+
+	parsed, _ := ParseToken(usdt.Hex())
+	if err := ResolveToken(context.Background(), &fakeBackend{}, &parsed); err != nil || parsed.Symbol != "USDT" || parsed.Decimals != 6 {
+		t.Errorf("Expected USDT with 6 decimals, got %v %v", parsed, err)
+	}
+}
+
+func TestLineSource(t *testing.T) {
+	t.Parallel()
+	first := common.BigToAddress(big.NewInt(1)).Hex()
+	second := common.BigToAddress(big.NewInt(2)).Hex()
+	input := "address,note\n7#" + first + "\n\n" + second + ",cold wallet"
+
+	src := NewLineSource(strings.NewReader(input))
+	for _, expected := range []Target{{"7", common.HexToAddress(first)}, {"4", common.HexToAddress(second)}} {
+		got, err := src.Next()
+		if err != nil || got != expected {
+			t.Errorf("Expected %v, got %v %v", expected, got, err)
+		}
+	}
+	if _, err := src.Next(); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+
+	if _, err := NewLineSource(strings.NewReader("1#" + first + "\n2#0x12")).Next(); err != nil {
+		t.Fatal(err)
+	}
+	bad := NewLineSource(strings.NewReader("1#" + first + "\n2#0x12"))
+	bad.Next()
+	if _, err := bad.Next(); err == nil {
+		t.Error("Expected an invalid address error")
+	}
+}
+
+func TestHDSource(t *testing.T) {
+	t.Parallel()
+	wallet, err := hdwallet.NewFromMnemonic("test test test test test test test test test test test junk", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := NewHDSource(wallet, accounts.DefaultBaseDerivationPath, 2)
+
+	var got []Target
+	for {
+		target, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, target)
+	}
+	if len(got) != 2 || got[1].Label != "m/44'/60'/0'/0/1" || got[1].Address != common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8") {
+		t.Errorf("Unexpected targets %v", got)
+	}
+}
+
+func TestRunResumes(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "scanner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := Config{
+		Tokens: []Token{
+			{Symbol: "ETH", Decimals: 18},
+			{Address: usdt, Symbol: "USDT", Decimals: 6},
+		},
+		Workers:           4,
+		RequestsPerSecond: 10000,
+		RetryDelay:        time.Millisecond,
+		Checkpoint:        filepath.Join(dir, "scan.checkpoint"),
+		CheckpointEvery:   3,
+	}
+
+	// address 15 fails permanently on the first run
+	backend := &fakeBackend{failed: make(map[common.Address]bool), broken: common.BigToAddress(big.NewInt(15))}
+	s, err := New(backend, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	err = s.Run(context.Background(), NewLineSource(strings.NewReader(addressList(20))), NewCSVWriter(&out, true))
+	if err == nil || !strings.Contains(err.Error(), "execution reverted") {
+		t.Fatalf("Expected the broken address to stop the scan, got %v", err)
+	}
+	if cp, _ := readCheckpoint(cfg.Checkpoint); cp.Next > 14 {
+		t.Fatalf("Expected the checkpoint before the broken address, got %v", cp.Next)
+	}
+
+	backend.broken = common.Address{}
+	s, err = New(backend, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Run(context.Background(), NewLineSource(strings.NewReader(addressList(20))), NewCSVWriter(&out, false)); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 21 {
+		t.Fatalf("Expected %v lines, got %v:\n%s", 21, len(lines), out.String())
+	}
+	for i, n := 1, 2; n <= 20; i, n = i+2, n+2 {
+		address := common.BigToAddress(big.NewInt(int64(n))).Hex()
+		eth := fmt.Sprintf("%d,%s,ETH,%s,%d,0.%018d", n, address, common.Address{}.Hex(), n, n)
+		usd := fmt.Sprintf("%d,%s,USDT,%s,%d,%d", n, address, usdt.Hex(), n*1000000, n)
+		eth = strings.TrimRight(eth, "0")
+		if lines[i] != eth || lines[i+1] != usd {
+			t.Errorf("Expected\n%s\n%s\ngot\n%s\n%s", eth, usd, lines[i], lines[i+1])
+		}
+	}
+}
+
+func TestRunTruncatesOutput(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "scanner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "balances.csv")
+	open := func() *os.File {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	cfg := Config{
+		Tokens:     []Token{{Symbol: "ETH", Decimals: 18}},
+		RetryDelay: time.Millisecond,
+		Checkpoint: filepath.Join(dir, "balances.csv.checkpoint"),
+	}
+	backend := &fakeBackend{failed: make(map[common.Address]bool)}
+
+	file := open()
+	cfg.Output = file
+	s, err := New(backend, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Run(context.Background(), NewLineSource(strings.NewReader(addressList(4))), NewCSVWriter(file, true)); err != nil {
+		t.Fatal(err)
+	}
+	// rows flushed after the checkpoint by a run that crashed
+	if _, err := file.WriteString("6,0x0000000000000000000000000000000000000006,ETH\n"); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	file = open()
+	defer file.Close()
+	cfg.Output = file
+	if s, err = New(backend, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Run(context.Background(), NewLineSource(strings.NewReader(addressList(8))), NewCSVWriter(file, false)); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 5 || strings.Count(string(data), ",0x0000000000000000000000000000000000000006,") != 1 {
+		t.Errorf("Expected a header and four rows, got:\n%s", data)
+	}
+}
@@ -0,0 +1,106 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"ethereum-development-with-go/code/hdwallet"
+	"ethereum-development-with-go/code/util"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Target is an address to scan. Label is the caller's identifier for it, the
+// index of an index#address line or the derivation path.
+type Target struct {
+	Label   string
+	Address common.Address
+}
+
+// Source yields the addresses to scan in a stable order, Next returns
+// io.EOF when done
+type Source interface {
+	Next() (Target, error)
+}
+
+// LineSource reads one address per line. Lines may be a bare address,
+// "label#address" as written by reval.go, or CSV whose first column is the
+// address. Blank lines are skipped.
+type LineSource struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewLineSource returns a source reading r, a file or os.Stdin
+func NewLineSource(r io.Reader) *LineSource {
+	return &LineSource{scanner: bufio.NewScanner(r)}
+}
+
+// Next implements Source
+func (s *LineSource) Next() (Target, error) {
+	for s.scanner.Scan() {
+		s.line++
+		line := strings.TrimSpace(s.scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		label, address := strconv.Itoa(s.line), line
+		if i := strings.LastIndex(line, "#"); i >= 0 {
+			label, address = strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		} else if i := strings.IndexAny(line, ",;\t "); i >= 0 {
+			address = line[:i]
+		}
+		if !util.IsValidAddress(address) {
+			// a CSV header is not an error
+			if s.line == 1 && !strings.HasPrefix(address, "0x") {
+				continue
+			}
+			return Target{}, fmt.Errorf("scanner: line %d: invalid address %q", s.line, address)
+		}
+
+		return Target{Label: label, Address: common.HexToAddress(address)}, nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return Target{}, err
+	}
+
+	return Target{}, io.EOF
+}
+
+// HDSource derives count addresses from a wallet, incrementing the last
+// component of base
+type HDSource struct {
+	wallet *hdwallet.Wallet
+	next   func() accounts.DerivationPath
+	left   uint64
+}
+
+// NewHDSource returns a source of count addresses starting at base,
+// e.g. accounts.DefaultBaseDerivationPath for m/44'/60'/0'/0/0
+func NewHDSource(wallet *hdwallet.Wallet, base accounts.DerivationPath, count uint64) *HDSource {
+	return &HDSource{
+		wallet: wallet,
+		next:   accounts.DefaultIterator(base),
+		left:   count,
+	}
+}
+
+// Next implements Source
+func (s *HDSource) Next() (Target, error) {
+	if s.left == 0 {
+		return Target{}, io.EOF
+	}
+	s.left--
+
+	path := s.next()
+	address, err := s.wallet.Address(path)
+	if err != nil {
+		return Target{}, err
+	}
+
+	return Target{Label: path.String(), Address: address}, nil
+}
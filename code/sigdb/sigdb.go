@@ -0,0 +1,348 @@
+package sigdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	store "ethereum-development-with-go/code/contracts"
+	exchange "ethereum-development-with-go/code/contracts_0xprotocol"
+	token "ethereum-development-with-go/code/contracts_erc20"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+/*
+ * Offline database of function selectors and event topics.
+ */
+
+// Selector is a 4-byte function or error selector
+type Selector [4]byte
+
+// Hex returns the 0x prefixed selector
+func (s Selector) Hex() string {
+	return hexutil.Encode(s[:])
+}
+
+// SeedABIs are the contract ABIs shipped with the repo and loaded by Default
+var SeedABIs = []string{
+	store.StoreABI,
+	token.TokenABI,
+	exchange.ExchangeABI,
+}
+
+// SeedSignatures are well known signatures loaded by Default
+var SeedSignatures = []string{
+	"Error(string)",
+	"Panic(uint256)",
+	"approve(address,uint256)",
+	"transfer(address,uint256)",
+	"transferFrom(address,address,uint256)",
+	"multicall(bytes[])",
+	"execute(bytes)",
+}
+
+// SeedEventSignatures are well known event signatures loaded by Default
+var SeedEventSignatures = []string{
+	"Transfer(address,address,uint256)",
+	"Approval(address,address,uint256)",
+}
+
+var intAlias = regexp.MustCompile(`\b(u?int)\b`)
+
+// DB maps selectors and topics back to their human-readable signatures.
+// Distinct signatures that collide on a selector are all kept.
+type DB struct {
+	mu        sync.RWMutex
+	functions map[Selector][]string
+	events    map[common.Hash][]string
+}
+
+// New returns an empty signature database
+func New() *DB {
+	return &DB{
+		functions: make(map[Selector][]string),
+		events:    make(map[common.Hash][]string),
+	}
+}
+
+// Default returns a signature database seeded from the repo ABIs
+func Default() *DB {
+	db := New()
+	for _, raw := range SeedABIs {
+		parsed, err := abi.JSON(strings.NewReader(raw))
+		if err != nil {
+			panic(fmt.Sprintf("sigdb: invalid seed ABI: %v", err))
+		}
+		db.AddABI(parsed)
+	}
+	for _, sig := range SeedSignatures {
+		db.AddFunction(sig)
+	}
+	for _, sig := range SeedEventSignatures {
+		db.AddEvent(sig)
+	}
+
+	return db
+}
+
+// SelectorOf returns the selector of a function or error signature
+func SelectorOf(sig string) Selector {
+	var s Selector
+	copy(s[:], crypto.Keccak256([]byte(Canonical(sig))))
+	return s
+}
+
+// TopicOf returns the topic of an event signature
+func TopicOf(sig string) common.Hash {
+	return crypto.Keccak256Hash([]byte(Canonical(sig)))
+}
+
+// Canonical strips whitespace and expands the int and uint aliases
+func Canonical(sig string) string {
+	sig = strings.Join(strings.Fields(sig), "")
+	return intAlias.ReplaceAllString(sig, "${1}256")
+}
+
+// AddABI adds every method, event and custom error of an ABI
+func (db *DB) AddABI(contractAbi abi.ABI) {
+	for _, method := range contractAbi.Methods {
+		db.AddFunction(method.Sig)
+	}
+	for _, event := range contractAbi.Events {
+		db.AddEvent(event.Sig)
+	}
+	for _, e := range contractAbi.Errors {
+		db.AddFunction(e.Sig)
+	}
+}
+
+// AddFunction adds a function or error signature and returns its selector
+func (db *DB) AddFunction(sig string) Selector {
+	sig = Canonical(sig)
+	selector := SelectorOf(sig)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.functions[selector] = insert(db.functions[selector], sig)
+
+	return selector
+}
+
+// AddEvent adds an event signature and returns its topic
+func (db *DB) AddEvent(sig string) common.Hash {
+	sig = Canonical(sig)
+	topic := TopicOf(sig)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.events[topic] = insert(db.events[topic], sig)
+
+	return topic
+}
+
+// Functions returns every known signature for a selector
+func (db *DB) Functions(selector Selector) []string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return append([]string(nil), db.functions[selector]...)
+}
+
+// FunctionsByData returns every known signature for the selector of calldata
+func (db *DB) FunctionsByData(data []byte) []string {
+	if len(data) < 4 {
+		return nil
+	}
+
+	var selector Selector
+	copy(selector[:], data[:4])
+	return db.Functions(selector)
+}
+
+// Events returns every known signature for an event topic
+func (db *DB) Events(topic common.Hash) []string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return append([]string(nil), db.events[topic]...)
+}
+
+// Len returns the number of function and event signatures
+func (db *DB) Len() int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	n := 0
+	for _, sigs := range db.functions {
+		n += len(sigs)
+	}
+	for _, sigs := range db.events {
+		n += len(sigs)
+	}
+
+	return n
+}
+
+// fourByteEntry is a single 4byte.directory signature record
+type fourByteEntry struct {
+	TextSignature string `json:"text_signature"`
+	HexSignature  string `json:"hex_signature"`
+}
+
+// Import loads a 4byte style dump and returns the number of signatures added.
+// It accepts a 4byte.directory API page ({"results": [...]}), a JSON array of
+// {"text_signature", "hex_signature"} records, a JSON object mapping hex to
+// signatures, or plain text lines of "<hex> <signature>" as written by Export.
+// Records whose hex does not match their signature are skipped without an
+// error and left out of the returned count, dumps carry such forgeries.
+func (db *DB) Import(r io.Reader) (int, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return 0, nil
+	}
+
+	var entries []fourByteEntry
+	switch trimmed[0] {
+	case '[':
+		if err := json.Unmarshal(trimmed, &entries); err != nil {
+			return 0, err
+		}
+	case '{':
+		var page struct {
+			Results []fourByteEntry `json:"results"`
+		}
+		if err := json.Unmarshal(trimmed, &page); err == nil && page.Results != nil {
+			entries = page.Results
+			break
+		}
+		var dump map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &dump); err != nil {
+			return 0, err
+		}
+		for hex, raw := range dump {
+			var sigs []string
+			if err := json.Unmarshal(raw, &sigs); err != nil {
+				var sig string
+				if err := json.Unmarshal(raw, &sig); err != nil {
+					return 0, fmt.Errorf("sigdb: invalid entry for %s", hex)
+				}
+				sigs = []string{sig}
+			}
+			for _, sig := range sigs {
+				entries = append(entries, fourByteEntry{TextSignature: sig, HexSignature: hex})
+			}
+		}
+	default:
+		scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			i := strings.IndexAny(line, " \t,")
+			if i < 0 {
+				return 0, fmt.Errorf("sigdb: invalid line %q", line)
+			}
+			entries = append(entries, fourByteEntry{HexSignature: line[:i], TextSignature: line[i+1:]})
+		}
+		if err := scanner.Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	added := 0
+	for _, entry := range entries {
+		ok, err := db.importEntry(entry)
+		if err != nil {
+			return added, err
+		}
+		if ok {
+			added++
+		}
+	}
+
+	return added, nil
+}
+
+// importEntry adds a single record, reporting whether it was new
+func (db *DB) importEntry(entry fourByteEntry) (bool, error) {
+	hash, err := hexutil.Decode(strings.TrimSpace(entry.HexSignature))
+	if err != nil {
+		return false, fmt.Errorf("sigdb: invalid hex signature %q: %v", entry.HexSignature, err)
+	}
+
+	sig := Canonical(entry.TextSignature)
+	switch len(hash) {
+	case 4:
+		selector := SelectorOf(sig)
+		if !bytes.Equal(selector[:], hash) {
+			return false, nil
+		}
+		before := len(db.Functions(selector))
+		db.AddFunction(sig)
+		return len(db.Functions(selector)) > before, nil
+	case common.HashLength:
+		topic := TopicOf(sig)
+		if !bytes.Equal(topic[:], hash) {
+			return false, nil
+		}
+		before := len(db.Events(topic))
+		db.AddEvent(sig)
+		return len(db.Events(topic)) > before, nil
+	default:
+		return false, fmt.Errorf("sigdb: unexpected hex signature length %d", len(hash))
+	}
+}
+
+// Export writes the database as sorted "<hex> <signature>" lines that Import reads back
+func (db *DB) Export(w io.Writer) error {
+	db.mu.RLock()
+	var lines []string
+	for selector, sigs := range db.functions {
+		for _, sig := range sigs {
+			lines = append(lines, selector.Hex()+" "+sig)
+		}
+	}
+	for topic, sigs := range db.events {
+		for _, sig := range sigs {
+			lines = append(lines, topic.Hex()+" "+sig)
+		}
+	}
+	db.mu.RUnlock()
+
+	sort.Strings(lines)
+	bw := bufio.NewWriter(w)
+	for _, line := range lines {
+		if _, err := bw.WriteString(line + "\n"); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// insert adds sig to a sorted set of signatures
+func insert(sigs []string, sig string) []string {
+	i := sort.SearchStrings(sigs, sig)
+	if i < len(sigs) && sigs[i] == sig {
+		return sigs
+	}
+
+	sigs = append(sigs, "")
+	copy(sigs[i+1:], sigs[i:])
+	sigs[i] = sig
+	return sigs
+}
@@ -0,0 +1,128 @@
+package sigdb
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestDefault(t *testing.T) {
+	t.Parallel()
+	db := Default()
+
+	tests := []struct {
+		selector string
+		expected string
+	}{
+		{"0xa9059cbb", "transfer(address,uint256)"},
+		{"0xf56256c7", "setItem(bytes32,bytes32)"},
+		{"0x08c379a0", "Error(string)"},
+		{"0x4e487b71", "Panic(uint256)"},
+	}
+	for _, tt := range tests {
+		var selector Selector
+		copy(selector[:], hexutil.MustDecode(tt.selector))
+		got := db.Functions(selector)
+		if len(got) != 1 || got[0] != tt.expected {
+			t.Errorf("Expected %v for %s, got %v", tt.expected, tt.selector, got)
+		}
+	}
+
+	// NOTE: keccak256("LogFill(address,address,address,address,address,uint256,uint256,uint256,uint256,bytes32,bytes32)")
+	logFill := common.HexToHash("0d0b9391970d9a25552f37d436d2aae2925e2bfe1b2a923754bada030c498cb3")
+	got := db.Events(logFill)
+	if len(got) != 1 || !strings.HasPrefix(got[0], "LogFill(") {
+		t.Errorf("Expected LogFill, got %v", got)
+	}
+
+	transfer := common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+	if got := db.Events(transfer); len(got) != 1 || got[0] != "Transfer(address,address,uint256)" {
+		t.Errorf("Expected Transfer, got %v", got)
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	t.Parallel()
+	got := Canonical("transfer(address, uint)")
+	if got != "transfer(address,uint256)" {
+		t.Errorf("Expected %v, got %v", "transfer(address,uint256)", got)
+	}
+	if SelectorOf("transfer(address, uint)").Hex() != "0xa9059cbb" {
+		t.Errorf("Expected %v, got %v", "0xa9059cbb", SelectorOf("transfer(address, uint)").Hex())
+	}
+}
+
+func TestCollisions(t *testing.T) {
+	t.Parallel()
+	db := New()
+	a := db.AddFunction("burn(uint256)")
+	b := db.AddFunction("collate_propagate_storage(bytes16)")
+	if a != b {
+		t.Fatalf("Expected colliding selectors, got %s and %s", a.Hex(), b.Hex())
+	}
+
+	got := db.Functions(a)
+	if len(got) != 2 || got[0] != "burn(uint256)" || got[1] != "collate_propagate_storage(bytes16)" {
+		t.Errorf("Expected both candidates, got %v", got)
+	}
+
+	db.AddFunction("burn(uint256)")
+	if db.Len() != 2 {
+		t.Errorf("Expected %v, got %v", 2, db.Len())
+	}
+}
+
+func TestImport(t *testing.T) {
+	t.Parallel()
+	db := New()
+
+	page := `{"count": 2, "next": null, "results": [
+		{"id": 1, "text_signature": "transfer(address,uint256)", "hex_signature": "0xa9059cbb"},
+		{"id": 2, "text_signature": "forged(uint256)", "hex_signature": "0xa9059cbb"}
+	]}`
+	added, err := db.Import(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 1 {
+		t.Errorf("Expected %v, got %v", 1, added)
+	}
+	// the forged record is skipped
+	if sigs := db.Functions(SelectorOf("transfer(address,uint256)")); len(sigs) != 1 {
+		t.Errorf("Expected only transfer, got %v", sigs)
+	}
+
+	dump := `{"0x42966c68": ["burn(uint256)", "collate_propagate_storage(bytes16)"]}`
+	added, err = db.Import(strings.NewReader(dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 2 {
+		t.Errorf("Expected %v, got %v", 2, added)
+	}
+
+	lines := "# events\n0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef Transfer(address,address,uint256)\n"
+	added, err = db.Import(strings.NewReader(lines))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 1 {
+		t.Errorf("Expected %v, got %v", 1, added)
+	}
+
+	var buf bytes.Buffer
+	if err := db.Export(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New()
+	added, err = restored.Import(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != db.Len() {
+		t.Errorf("Expected %v, got %v", db.Len(), added)
+	}
+}
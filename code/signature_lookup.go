@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"ethereum-development-with-go/code/sigdb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func main() {
+	// seeded from the Store, ERC20 and 0x Exchange ABIs, no network needed
+	db := sigdb.Default()
+
+	// import a 4byte.directory dump, e.g. curl https://www.4byte.directory/api/v1/signatures/ > 4byte.json
+	if file, err := os.Open("4byte.json"); err == nil {
+		added, err := db.Import(file)
+		file.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("imported:", added)
+	}
+
+	data := hexutil.MustDecode("0xa9059cbb0000000000000000000000004592d8f8d7b001e72cb26a73e4fa1806a51ac79d00000000000000000000000000000000000000000000003635c9adc5dea00000")
+	fmt.Println(db.FunctionsByData(data)) // [transfer(address,uint256)]
+
+	logFill := common.HexToHash("0x0d0b9391970d9a25552f37d436d2aae2925e2bfe1b2a923754bada030c498cb3")
+	fmt.Println(db.Events(logFill)) // [LogFill(address,address,address,address,address,uint256,uint256,uint256,uint256,bytes32,bytes32)]
+
+	fmt.Println(sigdb.SelectorOf("transfer(address,uint256)").Hex()) // 0xa9059cbb
+
+	if err := db.Export(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,363 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+/*
+ * Locate and read the state variables of a contract from the storage layout
+ * solc emits with --storage-layout, getters or not.
+ */
+
+var (
+	// ErrNotFound is returned for unknown variables and struct members
+	ErrNotFound = errors.New("storage: no such variable")
+	// ErrPath is returned when a path step does not fit the type it applies to
+	ErrPath = errors.New("storage: path does not match the type")
+)
+
+// Encodings of the layout types
+const (
+	EncodingInplace      = "inplace"
+	EncodingMapping      = "mapping"
+	EncodingDynamicArray = "dynamic_array"
+	EncodingBytes        = "bytes"
+)
+
+// Variable is a state variable or a struct member
+type Variable struct {
+	Label string `json:"label"`
+	// Slot is decimal, relative to the struct for members
+	Slot   string `json:"slot"`
+	Offset int    `json:"offset"`
+	Type   string `json:"type"`
+}
+
+// Type is an entry of the layout types
+type Type struct {
+	Encoding      string     `json:"encoding"`
+	Label         string     `json:"label"`
+	NumberOfBytes string     `json:"numberOfBytes"`
+	Key           string     `json:"key,omitempty"`
+	Value         string     `json:"value,omitempty"`
+	Base          string     `json:"base,omitempty"`
+	Members       []Variable `json:"members,omitempty"`
+}
+
+// Size returns the number of bytes a value of the type takes in place
+func (t *Type) Size() int {
+	size, _ := strconv.Atoi(t.NumberOfBytes)
+	return size
+}
+
+// Length returns the length of a static array, parsed from the outer
+// brackets of the label
+func (t *Type) Length() (int, bool) {
+	if t.Base == "" || t.Encoding != EncodingInplace || !strings.HasSuffix(t.Label, "]") {
+		return 0, false
+	}
+	open := strings.LastIndex(t.Label, "[")
+	length, err := strconv.Atoi(t.Label[open+1 : len(t.Label)-1])
+	return length, err == nil
+}
+
+// Layout is the storage layout of a contract
+type Layout struct {
+	Storage []Variable       `json:"storage"`
+	Types   map[string]*Type `json:"types"`
+}
+
+// ParseLayout parses the storageLayout output of solc. Every type a variable,
+// key, value, element or member refers to must be defined with a size, so
+// walking the layout cannot fail on it later
+func ParseLayout(data []byte) (*Layout, error) {
+	var layout Layout
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return nil, err
+	}
+	for _, v := range layout.Storage {
+		if layout.Types[v.Type] == nil {
+			return nil, fmt.Errorf("storage: %s has undefined type %s", v.Label, v.Type)
+		}
+	}
+
+	names := make([]string, 0, len(layout.Types))
+	for name := range layout.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := layout.check(name); err != nil {
+			return nil, err
+		}
+	}
+	return &layout, nil
+}
+
+// check validates the type name and the types it refers to
+func (l *Layout) check(name string) error {
+	t := l.Types[name]
+	if t == nil {
+		return fmt.Errorf("storage: type %s is null", name)
+	}
+	// element and key encoding divide by and compare with the size
+	if t.Size() <= 0 {
+		return fmt.Errorf("storage: type %s has numberOfBytes %q", name, t.NumberOfBytes)
+	}
+
+	switch t.Encoding {
+	case EncodingMapping:
+		if t.Key == "" || t.Value == "" {
+			return fmt.Errorf("storage: mapping %s needs a key and a value type", name)
+		}
+	case EncodingDynamicArray:
+		if t.Base == "" {
+			return fmt.Errorf("storage: array %s needs a base type", name)
+		}
+	}
+	for _, ref := range []struct{ field, name string }{{"key", t.Key}, {"value", t.Value}, {"base", t.Base}} {
+		if ref.name != "" && l.Types[ref.name] == nil {
+			return fmt.Errorf("storage: %s of %s is undefined type %s", ref.field, name, ref.name)
+		}
+	}
+	for _, m := range t.Members {
+		if l.Types[m.Type] == nil {
+			return fmt.Errorf("storage: member %s of %s has undefined type %s", m.Label, name, m.Type)
+		}
+	}
+	return nil
+}
+
+// Location is where a value lives, Offset counts bytes from the right end of
+// the slot
+type Location struct {
+	Slot   common.Hash
+	Offset int
+	Type   string
+}
+
+// Locate returns the location of label followed by path. Steps are mapping
+// keys, array indexes and struct member names, in the order Solidity takes
+// them, so balances[owner] is Locate("balances", owner). Indexes of dynamic
+// arrays are not checked against their length
+func (l *Layout) Locate(label string, path ...interface{}) (*Location, error) {
+	loc, err := l.variable(label)
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range path {
+		if loc, err = l.step(loc, step); err != nil {
+			return nil, err
+		}
+	}
+	return loc, nil
+}
+
+func (l *Layout) variable(label string) (*Location, error) {
+	for _, v := range l.Storage {
+		if v.Label == label {
+			return member(common.Hash{}, v)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, label)
+}
+
+// step moves from loc into a mapping value, array element or struct member
+func (l *Layout) step(loc *Location, step interface{}) (*Location, error) {
+	t := l.Types[loc.Type]
+	switch {
+	case t.Encoding == EncodingMapping:
+		key, err := l.encodeKey(l.Types[t.Key], step)
+		if err != nil {
+			return nil, err
+		}
+		return &Location{Slot: MappingSlot(loc.Slot, key), Type: t.Value}, nil
+
+	case t.Encoding == EncodingDynamicArray:
+		index, err := toIndex(step)
+		if err != nil {
+			return nil, fmt.Errorf("%w: index of %s: %v", ErrPath, t.Label, err)
+		}
+		return l.element(ArraySlot(loc.Slot), t.Base, index), nil
+
+	case t.Base != "":
+		index, err := toIndex(step)
+		if err != nil {
+			return nil, fmt.Errorf("%w: index of %s: %v", ErrPath, t.Label, err)
+		}
+		if length, ok := t.Length(); ok && index >= uint64(length) {
+			return nil, fmt.Errorf("%w: index %d out of %s", ErrPath, index, t.Label)
+		}
+		return l.element(loc.Slot, t.Base, index), nil
+
+	case len(t.Members) > 0:
+		name, ok := step.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s needs a member name, got %T", ErrPath, t.Label, step)
+		}
+		for _, m := range t.Members {
+			if m.Label == name {
+				return member(loc.Slot, m)
+			}
+		}
+		return nil, fmt.Errorf("%w: %s.%s", ErrNotFound, t.Label, name)
+	}
+
+	return nil, fmt.Errorf("%w: %s takes no key, index or member", ErrPath, t.Label)
+}
+
+// member returns the location of a variable declared at base
+func member(base common.Hash, v Variable) (*Location, error) {
+	slot, ok := new(big.Int).SetString(v.Slot, 10)
+	if !ok {
+		return nil, fmt.Errorf("storage: %s has slot %q", v.Label, v.Slot)
+	}
+	return &Location{Slot: addSlot(base, slot), Offset: v.Offset, Type: v.Type}, nil
+}
+
+// element returns the location of the element index of an array of base
+// starting at start. Elements of up to 16 bytes share slots
+func (l *Layout) element(start common.Hash, base string, index uint64) *Location {
+	size := l.Types[base].Size()
+	if size <= 16 {
+		perSlot := uint64(32 / size)
+		return &Location{
+			Slot:   addSlot(start, new(big.Int).SetUint64(index/perSlot)),
+			Offset: int(index%perSlot) * size,
+			Type:   base,
+		}
+	}
+	slots := new(big.Int).SetUint64(uint64((size + 31) / 32))
+	return &Location{Slot: addSlot(start, slots.Mul(slots, new(big.Int).SetUint64(index))), Type: base}
+}
+
+// MappingSlot returns the slot of the value under an encoded key of the
+// mapping at slot
+func MappingSlot(slot common.Hash, key []byte) common.Hash {
+	return crypto.Keccak256Hash(key, slot.Bytes())
+}
+
+// ArraySlot returns the slot of the first element of the dynamic array, or
+// the data of the long bytes or string, at slot
+func ArraySlot(slot common.Hash) common.Hash {
+	return crypto.Keccak256Hash(slot.Bytes())
+}
+
+func addSlot(slot common.Hash, n *big.Int) common.Hash {
+	sum := new(big.Int).Add(slot.Big(), n)
+	return common.BigToHash(math.U256(sum))
+}
+
+// encodeKey encodes a mapping key the way Solidity hashes it: value types
+// padded to 32 bytes, strings and bytes as they are
+func (l *Layout) encodeKey(t *Type, key interface{}) ([]byte, error) {
+	label := t.Label
+	switch {
+	case label == "string" || label == "bytes":
+		switch k := key.(type) {
+		case string:
+			if label == "bytes" {
+				return hexOrText(k)
+			}
+			return []byte(k), nil
+		case []byte:
+			return k, nil
+		}
+
+	case label == "bool":
+		if k, ok := key.(bool); ok {
+			if k {
+				return common.LeftPadBytes([]byte{1}, 32), nil
+			}
+			return make([]byte, 32), nil
+		}
+
+	case label == "address" || label == "address payable" || strings.HasPrefix(label, "contract "):
+		switch k := key.(type) {
+		case common.Address:
+			return common.LeftPadBytes(k.Bytes(), 32), nil
+		case string:
+			if common.IsHexAddress(k) {
+				return common.LeftPadBytes(common.HexToAddress(k).Bytes(), 32), nil
+			}
+		}
+
+	case strings.HasPrefix(label, "bytes"):
+		var b []byte
+		switch k := key.(type) {
+		case []byte:
+			b = k
+		case common.Hash:
+			b = k.Bytes()
+		case [32]byte:
+			b = k[:]
+		case string:
+			var err error
+			if b, err = hexOrText(k); err != nil {
+				return nil, err
+			}
+		}
+		if b != nil && len(b) <= t.Size() {
+			return common.RightPadBytes(b, 32), nil
+		}
+
+	case strings.HasPrefix(label, "uint") || strings.HasPrefix(label, "int") || strings.HasPrefix(label, "enum "):
+		n, err := toBig(key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: key of type %s: %v", ErrPath, label, err)
+		}
+		// signed keys are hashed sign extended
+		return math.U256Bytes(new(big.Int).Set(n)), nil
+	}
+
+	return nil, fmt.Errorf("%w: cannot use %T as a key of type %s", ErrPath, key, label)
+}
+
+func hexOrText(s string) ([]byte, error) {
+	if strings.HasPrefix(s, "0x") {
+		return common.FromHex(s), nil
+	}
+	return []byte(s), nil
+}
+
+func toBig(v interface{}) (*big.Int, error) {
+	switch n := v.(type) {
+	case *big.Int:
+		return n, nil
+	case int:
+		return big.NewInt(int64(n)), nil
+	case int64:
+		return big.NewInt(n), nil
+	case uint64:
+		return new(big.Int).SetUint64(n), nil
+	case uint8:
+		return big.NewInt(int64(n)), nil
+	case string:
+		if b, ok := new(big.Int).SetString(n, 0); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("%q is not a number", n)
+	}
+	return nil, fmt.Errorf("%T is not a number", v)
+}
+
+func toIndex(v interface{}) (uint64, error) {
+	n, err := toBig(v)
+	if err != nil {
+		return 0, err
+	}
+	if n.Sign() < 0 || !n.IsUint64() {
+		return 0, fmt.Errorf("%v is not an index", n)
+	}
+	return n.Uint64(), nil
+}
@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// maxElements bounds the arrays and strings read whole
+const maxElements = 1 << 16
+
+// StorageReader reads storage slots, ethclient and the simulated backend
+// implement it
+type StorageReader interface {
+	StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error)
+}
+
+// Reader reads the state variables of one contract
+type Reader struct {
+	backend StorageReader
+	address common.Address
+	layout  *Layout
+	block   *big.Int
+}
+
+// NewReader returns a reader of the contract at address, reading the latest
+// block
+func NewReader(backend StorageReader, address common.Address, layout *Layout) *Reader {
+	return &Reader{backend: backend, address: address, layout: layout}
+}
+
+// At returns a reader of the same contract at block
+func (r *Reader) At(block *big.Int) *Reader {
+	at := *r
+	at.block = block
+	return &at
+}
+
+// Read returns the value of label followed by path, see Layout.Locate.
+// Values decode to Go types: integers and enums to *big.Int, bool, addresses
+// to common.Address, bytesN and bytes to []byte, string, arrays to
+// []interface{} and structs to map[string]interface{} without their mappings
+func (r *Reader) Read(ctx context.Context, label string, path ...interface{}) (interface{}, error) {
+	loc, err := r.Locate(ctx, label, path...)
+	if err != nil {
+		return nil, err
+	}
+	return r.ReadAt(ctx, loc)
+}
+
+// Locate is Layout.Locate with indexes of dynamic arrays checked against
+// their length at the block read
+func (r *Reader) Locate(ctx context.Context, label string, path ...interface{}) (*Location, error) {
+	loc, err := r.layout.variable(label)
+	if err != nil {
+		return nil, err
+	}
+	slots := make(map[common.Hash][]byte)
+	for _, step := range path {
+		if t := r.layout.Types[loc.Type]; t.Encoding == EncodingDynamicArray {
+			length, err := r.length(ctx, slots, loc.Slot)
+			if err != nil {
+				return nil, err
+			}
+			if index, err := toIndex(step); err == nil && index >= length {
+				return nil, fmt.Errorf("%w: index %d out of %d elements of %s", ErrPath, index, length, t.Label)
+			}
+		}
+		if loc, err = r.layout.step(loc, step); err != nil {
+			return nil, err
+		}
+	}
+	return loc, nil
+}
+
+// ReadAt decodes the value at loc
+func (r *Reader) ReadAt(ctx context.Context, loc *Location) (interface{}, error) {
+	return r.decode(ctx, make(map[common.Hash][]byte), loc)
+}
+
+// slot reads a slot once per Read, packed values share them
+func (r *Reader) slot(ctx context.Context, slots map[common.Hash][]byte, slot common.Hash) ([]byte, error) {
+	if word, ok := slots[slot]; ok {
+		return word, nil
+	}
+	word, err := r.backend.StorageAt(ctx, r.address, slot, r.block)
+	if err != nil {
+		return nil, err
+	}
+	word = common.LeftPadBytes(word, 32)
+	slots[slot] = word
+	return word, nil
+}
+
+func (r *Reader) length(ctx context.Context, slots map[common.Hash][]byte, slot common.Hash) (uint64, error) {
+	word, err := r.slot(ctx, slots, slot)
+	if err != nil {
+		return 0, err
+	}
+	length := new(big.Int).SetBytes(word)
+	if !length.IsUint64() {
+		return 0, fmt.Errorf("storage: length %v at %s", length, slot.Hex())
+	}
+	return length.Uint64(), nil
+}
+
+func (r *Reader) decode(ctx context.Context, slots map[common.Hash][]byte, loc *Location) (interface{}, error) {
+	t := r.layout.Types[loc.Type]
+	if t == nil {
+		return nil, fmt.Errorf("storage: undefined type %s", loc.Type)
+	}
+
+	switch t.Encoding {
+	case EncodingMapping:
+		return nil, fmt.Errorf("%w: %s needs a key", ErrPath, t.Label)
+
+	case EncodingBytes:
+		data, err := r.bytes(ctx, slots, loc.Slot)
+		if err != nil {
+			return nil, err
+		}
+		if t.Label == "string" {
+			return string(data), nil
+		}
+		return data, nil
+
+	case EncodingDynamicArray:
+		length, err := r.length(ctx, slots, loc.Slot)
+		if err != nil {
+			return nil, err
+		}
+		return r.array(ctx, slots, ArraySlot(loc.Slot), t.Base, length)
+	}
+
+	if length, ok := t.Length(); ok {
+		return r.array(ctx, slots, loc.Slot, t.Base, uint64(length))
+	}
+	if len(t.Members) > 0 {
+		values := make(map[string]interface{})
+		for _, m := range t.Members {
+			if r.layout.Types[m.Type].Encoding == EncodingMapping {
+				continue
+			}
+			mloc, err := member(loc.Slot, m)
+			if err != nil {
+				return nil, err
+			}
+			if values[m.Label], err = r.decode(ctx, slots, mloc); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+
+	word, err := r.slot(ctx, slots, loc.Slot)
+	if err != nil {
+		return nil, err
+	}
+	size := t.Size()
+	if loc.Offset+size > 32 {
+		return nil, fmt.Errorf("storage: %s at offset %d overflows its slot", t.Label, loc.Offset)
+	}
+	return value(t.Label, word[32-loc.Offset-size:32-loc.Offset]), nil
+}
+
+func (r *Reader) array(ctx context.Context, slots map[common.Hash][]byte, start common.Hash, base string, length uint64) (interface{}, error) {
+	if length > maxElements {
+		return nil, fmt.Errorf("storage: %d elements, read them one by one", length)
+	}
+	values := make([]interface{}, length)
+	for i := range values {
+		v, err := r.decode(ctx, slots, r.layout.element(start, base, uint64(i)))
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// bytes reads a bytes or string. Up to 31 bytes live in the slot with twice
+// the length in the lowest byte, longer ones from the hash of the slot with
+// twice the length plus one in the slot
+func (r *Reader) bytes(ctx context.Context, slots map[common.Hash][]byte, slot common.Hash) ([]byte, error) {
+	word, err := r.slot(ctx, slots, slot)
+	if err != nil {
+		return nil, err
+	}
+	if word[31]&1 == 0 {
+		length := int(word[31] / 2)
+		if length > 31 {
+			return nil, fmt.Errorf("storage: short bytes of length %d at %s", length, slot.Hex())
+		}
+		return common.CopyBytes(word[:length]), nil
+	}
+
+	length := new(big.Int).SetBytes(word)
+	length.Rsh(length, 1)
+	if !length.IsUint64() || length.Uint64() > maxElements*32 {
+		return nil, fmt.Errorf("storage: bytes of length %v at %s", length, slot.Hex())
+	}
+	data := make([]byte, 0, length.Uint64()+31)
+	start := ArraySlot(slot)
+	for i := uint64(0); uint64(len(data)) < length.Uint64(); i++ {
+		chunk, err := r.slot(ctx, slots, addSlot(start, new(big.Int).SetUint64(i)))
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, chunk...)
+	}
+	return data[:length.Uint64()], nil
+}
+
+// value decodes a value type by its label
+func value(label string, b []byte) interface{} {
+	switch {
+	case label == "bool":
+		return b[len(b)-1] != 0
+	case label == "address" || label == "address payable" || strings.HasPrefix(label, "contract "):
+		return common.BytesToAddress(b)
+	case strings.HasPrefix(label, "uint") || strings.HasPrefix(label, "enum "):
+		return new(big.Int).SetBytes(b)
+	case strings.HasPrefix(label, "int"):
+		n := new(big.Int).SetBytes(b)
+		// two's complement of the type width
+		if len(b) > 0 && b[0]&0x80 != 0 {
+			n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+		}
+		return n
+	}
+	return common.CopyBytes(b)
+}
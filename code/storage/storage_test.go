@@ -0,0 +1,322 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	store "ethereum-development-with-go/code/contracts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// storeLayout is the layout solc gives Store.sol
+const storeLayout = `{
+	"storage": [
+		{"astId": 5, "contract": "Store.sol:Store", "label": "version", "offset": 0, "slot": "0", "type": "t_string_storage"},
+		{"astId": 9, "contract": "Store.sol:Store", "label": "items", "offset": 0, "slot": "1", "type": "t_mapping(t_bytes32,t_bytes32)"}
+	],
+	"types": {
+		"t_bytes32": {"encoding": "inplace", "label": "bytes32", "numberOfBytes": "32"},
+		"t_mapping(t_bytes32,t_bytes32)": {"encoding": "mapping", "key": "t_bytes32", "label": "mapping(bytes32 => bytes32)", "numberOfBytes": "32", "value": "t_bytes32"},
+		"t_string_storage": {"encoding": "bytes", "label": "string", "numberOfBytes": "32"}
+	}
+}`
+
+// vaultLayout covers packing, nesting and both bytes encodings
+const vaultLayout = `{
+	"storage": [
+		{"label": "owner", "offset": 0, "slot": "0", "type": "t_address"},
+		{"label": "paused", "offset": 20, "slot": "0", "type": "t_bool"},
+		{"label": "decimals", "offset": 21, "slot": "0", "type": "t_uint8"},
+		{"label": "delta", "offset": 22, "slot": "0", "type": "t_int64"},
+		{"label": "balances", "offset": 0, "slot": "1", "type": "t_mapping(t_address,t_uint256)"},
+		{"label": "allowances", "offset": 0, "slot": "2", "type": "t_mapping(t_address,t_mapping(t_address,t_uint256))"},
+		{"label": "holders", "offset": 0, "slot": "3", "type": "t_array(t_address)dyn_storage"},
+		{"label": "small", "offset": 0, "slot": "4", "type": "t_array(t_uint16)dyn_storage"},
+		{"label": "config", "offset": 0, "slot": "5", "type": "t_struct(Config)_storage"},
+		{"label": "configs", "offset": 0, "slot": "7", "type": "t_mapping(t_uint256,t_struct(Config)_storage)"},
+		{"label": "name", "offset": 0, "slot": "8", "type": "t_string_storage"},
+		{"label": "data", "offset": 0, "slot": "9", "type": "t_bytes_storage"},
+		{"label": "fixed", "offset": 0, "slot": "10", "type": "t_array(t_uint8)3_storage"},
+		{"label": "list", "offset": 0, "slot": "11", "type": "t_array(t_struct(Config)_storage)dyn_storage"},
+		{"label": "names", "offset": 0, "slot": "12", "type": "t_mapping(t_string_memory_ptr,t_uint256)"}
+	],
+	"types": {
+		"t_address": {"encoding": "inplace", "label": "address", "numberOfBytes": "20"},
+		"t_bool": {"encoding": "inplace", "label": "bool", "numberOfBytes": "1"},
+		"t_uint8": {"encoding": "inplace", "label": "uint8", "numberOfBytes": "1"},
+		"t_uint16": {"encoding": "inplace", "label": "uint16", "numberOfBytes": "2"},
+		"t_int64": {"encoding": "inplace", "label": "int64", "numberOfBytes": "8"},
+		"t_uint128": {"encoding": "inplace", "label": "uint128", "numberOfBytes": "16"},
+		"t_uint256": {"encoding": "inplace", "label": "uint256", "numberOfBytes": "32"},
+		"t_bytes32": {"encoding": "inplace", "label": "bytes32", "numberOfBytes": "32"},
+		"t_string_storage": {"encoding": "bytes", "label": "string", "numberOfBytes": "32"},
+		"t_string_memory_ptr": {"encoding": "bytes", "label": "string", "numberOfBytes": "32"},
+		"t_bytes_storage": {"encoding": "bytes", "label": "bytes", "numberOfBytes": "32"},
+		"t_mapping(t_address,t_uint256)": {"encoding": "mapping", "key": "t_address", "label": "mapping(address => uint256)", "numberOfBytes": "32", "value": "t_uint256"},
+		"t_mapping(t_address,t_mapping(t_address,t_uint256))": {"encoding": "mapping", "key": "t_address", "label": "mapping(address => mapping(address => uint256))", "numberOfBytes": "32", "value": "t_mapping(t_address,t_uint256)"},
+		"t_mapping(t_uint256,t_struct(Config)_storage)": {"encoding": "mapping", "key": "t_uint256", "label": "mapping(uint256 => struct Vault.Config)", "numberOfBytes": "32", "value": "t_struct(Config)_storage"},
+		"t_mapping(t_string_memory_ptr,t_uint256)": {"encoding": "mapping", "key": "t_string_memory_ptr", "label": "mapping(string => uint256)", "numberOfBytes": "32", "value": "t_uint256"},
+		"t_array(t_address)dyn_storage": {"base": "t_address", "encoding": "dynamic_array", "label": "address[]", "numberOfBytes": "32"},
+		"t_array(t_uint16)dyn_storage": {"base": "t_uint16", "encoding": "dynamic_array", "label": "uint16[]", "numberOfBytes": "32"},
+		"t_array(t_uint8)3_storage": {"base": "t_uint8", "encoding": "inplace", "label": "uint8[3]", "numberOfBytes": "32"},
+		"t_array(t_struct(Config)_storage)dyn_storage": {"base": "t_struct(Config)_storage", "encoding": "dynamic_array", "label": "struct Vault.Config[]", "numberOfBytes": "32"},
+		"t_struct(Config)_storage": {"encoding": "inplace", "label": "struct Vault.Config", "numberOfBytes": "64", "members": [
+			{"label": "a", "offset": 0, "slot": "0", "type": "t_uint128"},
+			{"label": "b", "offset": 16, "slot": "0", "type": "t_uint128"},
+			{"label": "c", "offset": 0, "slot": "1", "type": "t_bytes32"}
+		]}
+	}
+}`
+
+var (
+	vault   = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	owner   = common.HexToAddress("0x96216849c49358B10257cb55b28eA603c874b05E")
+	spender = common.HexToAddress("0x4592d8f8d7b001e72cb26a73e4fa1806a51ac79d")
+)
+
+func pad(n int64) []byte {
+	return common.LeftPadBytes(big.NewInt(n).Bytes(), 32)
+}
+
+func plus(slot common.Hash, n int64) common.Hash {
+	return common.BigToHash(new(big.Int).Add(slot.Big(), big.NewInt(n)))
+}
+
+// vaultStorage lays the values out by hand, the way solc would
+func vaultStorage() map[common.Hash]common.Hash {
+	s := make(map[common.Hash]common.Hash)
+
+	var packed common.Hash
+	copy(packed[12:], owner.Bytes())
+	packed[11] = 1                                                             // paused
+	packed[10] = 18                                                            // decimals
+	copy(packed[2:10], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfb}) // delta -5
+	s[common.Hash{}] = packed
+
+	s[crypto.Keccak256Hash(common.LeftPadBytes(owner.Bytes(), 32), pad(1))] = common.BigToHash(big.NewInt(100))
+	inner := crypto.Keccak256(common.LeftPadBytes(owner.Bytes(), 32), pad(2))
+	s[crypto.Keccak256Hash(common.LeftPadBytes(spender.Bytes(), 32), inner)] = common.BigToHash(big.NewInt(7))
+
+	s[common.BytesToHash(pad(3))] = common.BigToHash(big.NewInt(2))
+	holders := crypto.Keccak256Hash(pad(3))
+	s[holders] = common.BytesToHash(owner.Bytes())
+	s[plus(holders, 1)] = common.BytesToHash(spender.Bytes())
+
+	// 16 uint16 share a slot, element 1 sits two bytes from the right
+	s[common.BytesToHash(pad(4))] = common.BigToHash(big.NewInt(17))
+	small := crypto.Keccak256Hash(pad(4))
+	s[small] = common.BigToHash(big.NewInt(0x01020000))
+	s[plus(small, 1)] = common.BigToHash(big.NewInt(9))
+
+	var config common.Hash
+	config[15], config[31] = 2, 1
+	s[common.BytesToHash(pad(5))] = config
+	s[common.BytesToHash(pad(6))] = common.Hash{3}
+
+	configs := crypto.Keccak256Hash(pad(42), pad(7))
+	s[configs] = common.BigToHash(big.NewInt(4))
+	s[plus(configs, 1)] = common.Hash{5}
+
+	var name common.Hash
+	copy(name[:], "vault")
+	name[31] = 10
+	s[common.BytesToHash(pad(8))] = name
+
+	s[common.BytesToHash(pad(9))] = common.BigToHash(big.NewInt(40*2 + 1))
+	data := crypto.Keccak256Hash(pad(9))
+	s[data] = common.BytesToHash(bytes.Repeat([]byte{0xaa}, 32))
+	s[plus(data, 1)] = common.BytesToHash(common.RightPadBytes(bytes.Repeat([]byte{0xbb}, 8), 32))
+
+	s[common.BytesToHash(pad(10))] = common.BigToHash(big.NewInt(0x090807))
+
+	s[common.BytesToHash(pad(11))] = common.BigToHash(big.NewInt(1))
+	list := crypto.Keccak256Hash(pad(11))
+	s[list] = common.BigToHash(big.NewInt(10))
+	s[plus(list, 1)] = common.Hash{11}
+
+	s[crypto.Keccak256Hash([]byte("alice"), pad(12))] = common.BigToHash(big.NewInt(99))
+	return s
+}
+
+// same is reflect.DeepEqual with integers compared by value
+func same(a, b interface{}) bool {
+	switch a := a.(type) {
+	case *big.Int:
+		b, ok := b.(*big.Int)
+		return ok && a.Cmp(b) == 0
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !same(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k := range a {
+			if !same(a[k], b[k]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func TestReadStore(t *testing.T) {
+	t.Parallel()
+	key, _ := crypto.GenerateKey()
+	auth, _ := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{
+		auth.From: {Balance: big.NewInt(1000000000000000000)},
+	}, 8000000)
+	defer backend.Close()
+
+	address, _, instance, err := store.DeployStore(auth, backend, "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.Commit()
+	if _, err := instance.SetItem(auth, [32]byte{1}, [32]byte{2}); err != nil {
+		t.Fatal(err)
+	}
+	backend.Commit()
+
+	layout, err := ParseLayout([]byte(storeLayout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := NewReader(backend, address, layout)
+	ctx := context.Background()
+
+	if version, err := reader.Read(ctx, "version"); err != nil || version != "1.0" {
+		t.Errorf("Expected 1.0, got %v and %v", version, err)
+	}
+	item, err := reader.Read(ctx, "items", [32]byte{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(item.([]byte), common.Hash{2}.Bytes()) {
+		t.Errorf("Expected %x, got %x", common.Hash{2}, item)
+	}
+	// the item was set in block 2
+	if item, _ := reader.At(big.NewInt(1)).Read(ctx, "items", [32]byte{1}); !bytes.Equal(item.([]byte), make([]byte, 32)) {
+		t.Errorf("Expected no item at block 1, got %x", item)
+	}
+}
+
+func TestReadVault(t *testing.T) {
+	t.Parallel()
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{
+		vault: {Balance: new(big.Int), Code: []byte{0x00}, Storage: vaultStorage()},
+	}, 8000000)
+	defer backend.Close()
+
+	layout, err := ParseLayout([]byte(vaultLayout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := NewReader(backend, vault, layout)
+	ctx := context.Background()
+
+	for _, c := range []struct {
+		path     []interface{}
+		expected interface{}
+	}{
+		{[]interface{}{"owner"}, owner},
+		{[]interface{}{"paused"}, true},
+		{[]interface{}{"decimals"}, big.NewInt(18)},
+		{[]interface{}{"delta"}, big.NewInt(-5)},
+		{[]interface{}{"balances", owner}, big.NewInt(100)},
+		{[]interface{}{"balances", spender.Hex()}, big.NewInt(0)},
+		{[]interface{}{"allowances", owner, spender}, big.NewInt(7)},
+		{[]interface{}{"holders"}, []interface{}{owner, spender}},
+		{[]interface{}{"holders", 1}, spender},
+		{[]interface{}{"small", 1}, big.NewInt(0x0102)},
+		{[]interface{}{"small", 16}, big.NewInt(9)},
+		{[]interface{}{"config", "b"}, big.NewInt(2)},
+		{[]interface{}{"config"}, map[string]interface{}{"a": big.NewInt(1), "b": big.NewInt(2), "c": common.Hash{3}.Bytes()}},
+		{[]interface{}{"configs", 42, "c"}, common.Hash{5}.Bytes()},
+		{[]interface{}{"configs", big.NewInt(42), "a"}, big.NewInt(4)},
+		{[]interface{}{"name"}, "vault"},
+		{[]interface{}{"data"}, append(bytes.Repeat([]byte{0xaa}, 32), bytes.Repeat([]byte{0xbb}, 8)...)},
+		{[]interface{}{"fixed"}, []interface{}{big.NewInt(7), big.NewInt(8), big.NewInt(9)}},
+		{[]interface{}{"list", 0, "a"}, big.NewInt(10)},
+		{[]interface{}{"names", "alice"}, big.NewInt(99)},
+	} {
+		value, err := reader.Read(ctx, c.path[0].(string), c.path[1:]...)
+		if err != nil {
+			t.Errorf("Expected %v for %v, got %v", c.expected, c.path, err)
+			continue
+		}
+		if !same(value, c.expected) {
+			t.Errorf("Expected %v for %v, got %v", c.expected, c.path, value)
+		}
+	}
+
+	for name, path := range map[string][]interface{}{
+		"mapping without key": {"balances"},
+		"past the length":     {"holders", 2},
+		"past the size":       {"fixed", 3},
+		"wrong key type":      {"balances", true},
+		"unknown member":      {"config", "d"},
+		"index of a value":    {"owner", 0},
+	} {
+		if _, err := reader.Read(ctx, path[0].(string), path[1:]...); !errors.Is(err, ErrPath) && !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected an error for %v, got %v", name, err)
+		}
+	}
+	if _, err := reader.Read(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected %v, got %v", ErrNotFound, err)
+	}
+}
+
+func TestLocate(t *testing.T) {
+	t.Parallel()
+	layout, _ := ParseLayout([]byte(vaultLayout))
+
+	loc, err := layout.Locate("small", 17)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loc.Slot != plus(crypto.Keccak256Hash(pad(4)), 1) || loc.Offset != 2 {
+		t.Errorf("Expected the second slot at offset 2, got %v at %d", loc.Slot.Hex(), loc.Offset)
+	}
+
+	// elements of two slots are two slots apart
+	if loc, _ = layout.Locate("list", 3, "c"); loc.Slot != plus(crypto.Keccak256Hash(pad(11)), 7) {
+		t.Errorf("Expected the seventh slot after the start, got %v", loc.Slot.Hex())
+	}
+
+	for name, types := range map[string]string{
+		"variable": `{}`,
+		"key":      `{"t_x": {"encoding": "mapping", "key": "t_k", "value": "t_x", "numberOfBytes": "32"}}`,
+		"value":    `{"t_x": {"encoding": "mapping", "key": "t_x", "value": "t_v", "numberOfBytes": "32"}}`,
+		"no value": `{"t_x": {"encoding": "mapping", "key": "t_x", "numberOfBytes": "32"}}`,
+		"base":     `{"t_x": {"encoding": "dynamic_array", "base": "t_b", "numberOfBytes": "32"}}`,
+		"member":   `{"t_x": {"encoding": "inplace", "numberOfBytes": "32", "members": [{"label": "m", "slot": "0", "type": "t_m"}]}}`,
+		"null":     `{"t_x": {"encoding": "inplace", "numberOfBytes": "32"}, "t_y": null}`,
+		"zero":     `{"t_x": {"encoding": "inplace", "numberOfBytes": "0"}}`,
+		"size":     `{"t_x": {"encoding": "inplace"}}`,
+	} {
+		data := `{"storage": [{"label": "x", "slot": "0", "type": "t_x"}], "types": ` + types + `}`
+		if _, err := ParseLayout([]byte(data)); err == nil {
+			t.Errorf("Expected an error for an undefined or empty %v type", name)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package subscriber
+
+import (
+	"context"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Client is the part of a node connection the subscriber needs. The filter
+// methods are only used when the provider cannot push notifications.
+type Client interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+	SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error)
+	NewFilter(ctx context.Context, q ethereum.FilterQuery) (string, error)
+	FilterChanges(ctx context.Context, id string) ([]types.Log, error)
+	UninstallFilter(ctx context.Context, id string) error
+	Close()
+}
+
+// Dialer opens a new connection, it is called again after every disconnect
+type Dialer func(ctx context.Context) (Client, error)
+
+// RPCClient adds the eth_newFilter family, which ethclient does not expose,
+// to an ethclient.Client
+type RPCClient struct {
+	*ethclient.Client
+	rpc *rpc.Client
+}
+
+// NewRPCClient wraps an rpc connection
+func NewRPCClient(c *rpc.Client) *RPCClient {
+	return &RPCClient{Client: ethclient.NewClient(c), rpc: c}
+}
+
+// Dial returns a Dialer connecting to a WebSocket, IPC or HTTP endpoint
+func Dial(rawurl string) Dialer {
+	return func(ctx context.Context) (Client, error) {
+		c, err := rpc.DialContext(ctx, rawurl)
+		if err != nil {
+			return nil, err
+		}
+		return NewRPCClient(c), nil
+	}
+}
+
+// NewFilter installs a log filter for new blocks and returns its id
+func (c *RPCClient) NewFilter(ctx context.Context, q ethereum.FilterQuery) (string, error) {
+	arg := map[string]interface{}{
+		"address":   q.Addresses,
+		"topics":    q.Topics,
+		"fromBlock": "latest",
+	}
+
+	var id string
+	err := c.rpc.CallContext(ctx, &id, "eth_newFilter", arg)
+	return id, err
+}
+
+// FilterChanges returns the logs, including removed ones, seen by a filter
+// since the previous call
+func (c *RPCClient) FilterChanges(ctx context.Context, id string) ([]types.Log, error) {
+	var logs []types.Log
+	err := c.rpc.CallContext(ctx, &logs, "eth_getFilterChanges", id)
+	return logs, err
+}
+
+// UninstallFilter removes a filter
+func (c *RPCClient) UninstallFilter(ctx context.Context, id string) error {
+	var ok bool
+	return c.rpc.CallContext(ctx, &ok, "eth_uninstallFilter", id)
+}
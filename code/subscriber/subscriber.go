@@ -0,0 +1,358 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+	"time"
+
+	"ethereum-development-with-go/code/indexer"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+/*
+ * Log subscriptions that survive disconnects, gaps and reorgs.
+ */
+
+// Defaults used when Config fields are left zero
+const (
+	DefaultReorgDepth   = 12
+	DefaultMinBackoff   = time.Second
+	DefaultMaxBackoff   = time.Minute
+	DefaultPollInterval = 4 * time.Second
+)
+
+// Config configures a Subscriber
+type Config struct {
+	// ReorgDepth is how many blocks behind the last one seen are re-checked
+	// after a reconnect, and how long delivered logs are remembered
+	ReorgDepth uint64
+	// MinBackoff and MaxBackoff bound the delay between reconnects
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// PollInterval is the eth_getFilterChanges period on HTTP-only providers
+	PollInterval time.Duration
+	// ChunkSize is the largest backfill range requested at once,
+	// indexer.DefaultChunkSize when zero
+	ChunkSize uint64
+	// OnError is called with the error that ended every connection
+	OnError func(error)
+}
+
+// Event is a log delivered to or retracted from the consumer
+type Event struct {
+	Log types.Log
+	// Removed reports the log was retracted by a reorg after being delivered
+	Removed bool
+	// Backfilled reports the log was recovered by FilterLogs rather than pushed
+	Backfilled bool
+}
+
+// logKey identifies a log across reconnects
+type logKey struct {
+	block common.Hash
+	index uint
+}
+
+// Subscriber streams the logs matching a query over a connection that may
+// drop. Logs are delivered at most once per (block hash, log index), logs
+// missed while disconnected are backfilled and logs that a reorg removed are
+// retracted with an Event whose Removed is set.
+type Subscriber struct {
+	dial  Dialer
+	query ethereum.FilterQuery
+	cfg   Config
+
+	// next is the first block not yet covered, valid once started
+	next    uint64
+	started bool
+	// start is the FromBlock of the query, backfills never go below it
+	start uint64
+	// seen holds the logs delivered within the reorg window
+	seen map[logKey]types.Log
+}
+
+// New returns a subscriber for q. When q.FromBlock is set the logs from that
+// block on are backfilled first, otherwise streaming starts at the head.
+// q.ToBlock and q.BlockHash are ignored.
+func New(dial Dialer, q ethereum.FilterQuery, cfg Config) *Subscriber {
+	if cfg.ReorgDepth == 0 {
+		cfg.ReorgDepth = DefaultReorgDepth
+	}
+	if cfg.MinBackoff == 0 {
+		cfg.MinBackoff = DefaultMinBackoff
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	if cfg.ChunkSize == 0 {
+		cfg.ChunkSize = indexer.DefaultChunkSize
+	}
+
+	s := &Subscriber{
+		dial: dial,
+		query: ethereum.FilterQuery{
+			Addresses: q.Addresses,
+			Topics:    q.Topics,
+		},
+		cfg:  cfg,
+		seen: make(map[logKey]types.Log),
+	}
+	if q.FromBlock != nil {
+		s.start = q.FromBlock.Uint64()
+		s.next = s.start
+		s.started = true
+	}
+
+	return s
+}
+
+// Run delivers events until ctx is cancelled, reconnecting with exponential
+// backoff whenever the connection fails. It returns ctx.Err().
+func (s *Subscriber) Run(ctx context.Context, events chan<- Event) error {
+	backoff := s.cfg.MinBackoff
+	for {
+		connected, err := s.session(ctx, events)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if s.cfg.OnError != nil {
+			s.cfg.OnError(err)
+		}
+		if connected {
+			backoff = s.cfg.MinBackoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > s.cfg.MaxBackoff {
+			backoff = s.cfg.MaxBackoff
+		}
+	}
+}
+
+// session runs one connection until it fails. connected reports whether the
+// gap was backfilled, which resets the backoff.
+func (s *Subscriber) session(ctx context.Context, events chan<- Event) (connected bool, err error) {
+	client, err := s.dial(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer client.Close()
+
+	// subscribe before reading the head so no block falls between the
+	// backfill and the live stream, duplicates are dropped by deliver
+	logs := make(chan types.Log, 128)
+	sub, err := client.SubscribeFilterLogs(ctx, s.query, logs)
+	polling := errors.Is(err, rpc.ErrNotificationsUnsupported)
+	if err != nil && !polling {
+		return false, err
+	}
+
+	var filterID string
+	if polling {
+		if filterID, err = client.NewFilter(ctx, s.query); err != nil {
+			return false, err
+		}
+		defer client.UninstallFilter(context.Background(), filterID)
+	} else {
+		defer sub.Unsubscribe()
+	}
+
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return false, err
+	}
+	if err := s.backfill(ctx, client, head, events); err != nil {
+		return false, err
+	}
+
+	if polling {
+		return true, s.poll(ctx, client, filterID, events)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case err := <-sub.Err():
+			if err == nil {
+				err = errors.New("subscriber: subscription closed")
+			}
+			return true, err
+		case vLog := <-logs:
+			if err := s.deliver(ctx, vLog, false, events); err != nil {
+				return true, err
+			}
+		}
+	}
+}
+
+// poll reads eth_getFilterChanges until it fails, including when the node
+// dropped the filter
+func (s *Subscriber) poll(ctx context.Context, client Client, id string, events chan<- Event) error {
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		logs, err := client.FilterChanges(ctx, id)
+		if err != nil {
+			return err
+		}
+		for _, vLog := range logs {
+			if err := s.deliver(ctx, vLog, false, events); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// backfill fetches the logs between the gap start and head. The last
+// ReorgDepth blocks already covered are fetched again, and delivered logs
+// missing from them were reorged out while disconnected.
+func (s *Subscriber) backfill(ctx context.Context, client Client, head uint64, events chan<- Event) error {
+	if !s.started {
+		s.next = head + 1
+		s.started = true
+		return nil
+	}
+
+	from := s.start
+	if s.next > s.start+s.cfg.ReorgDepth {
+		from = s.next - s.cfg.ReorgDepth
+	}
+	if from > head {
+		return nil
+	}
+
+	logs, err := s.fetch(ctx, client, from, head)
+	if err != nil {
+		return err
+	}
+
+	canonical := make(map[logKey]bool, len(logs))
+	for _, vLog := range logs {
+		canonical[logKey{vLog.BlockHash, vLog.Index}] = true
+	}
+	var stale []types.Log
+	for key, vLog := range s.seen {
+		if vLog.BlockNumber >= from && vLog.BlockNumber <= head && !canonical[key] {
+			stale = append(stale, vLog)
+		}
+	}
+	// retract newest first, the order a node reports removed logs in
+	sort.Slice(stale, func(i, j int) bool {
+		if stale[i].BlockNumber != stale[j].BlockNumber {
+			return stale[i].BlockNumber > stale[j].BlockNumber
+		}
+		return stale[i].Index > stale[j].Index
+	})
+	for _, vLog := range stale {
+		vLog.Removed = true
+		if err := s.deliver(ctx, vLog, true, events); err != nil {
+			return err
+		}
+	}
+
+	for _, vLog := range logs {
+		if err := s.deliver(ctx, vLog, true, events); err != nil {
+			return err
+		}
+	}
+	if head+1 > s.next {
+		s.next = head + 1
+	}
+
+	return nil
+}
+
+// fetch runs FilterLogs over from..to, splitting the range when the provider
+// rejects it as too large
+func (s *Subscriber) fetch(ctx context.Context, client Client, from, to uint64) ([]types.Log, error) {
+	var out []types.Log
+
+	chunk := s.cfg.ChunkSize
+	for from <= to {
+		end := from + chunk - 1
+		if end > to || end < from {
+			end = to
+		}
+
+		q := s.query
+		q.FromBlock = new(big.Int).SetUint64(from)
+		q.ToBlock = new(big.Int).SetUint64(end)
+		logs, err := client.FilterLogs(ctx, q)
+		if err != nil && indexer.IsRangeError(err) && end > from {
+			chunk = (end - from + 1) / 2
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, logs...)
+		from = end + 1
+	}
+
+	return out, nil
+}
+
+// deliver emits a log unless it was already delivered, or a retraction if
+// the log is marked removed and was delivered before
+func (s *Subscriber) deliver(ctx context.Context, vLog types.Log, backfilled bool, events chan<- Event) error {
+	key := logKey{vLog.BlockHash, vLog.Index}
+
+	ev := Event{Log: vLog, Removed: vLog.Removed, Backfilled: backfilled}
+	if vLog.Removed {
+		if _, ok := s.seen[key]; !ok {
+			return nil
+		}
+		delete(s.seen, key)
+	} else {
+		if _, ok := s.seen[key]; ok {
+			return nil
+		}
+		s.seen[key] = vLog
+		if vLog.BlockNumber+1 > s.next {
+			s.next = vLog.BlockNumber + 1
+			s.prune()
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case events <- ev:
+		return nil
+	}
+}
+
+// prune forgets logs older than the reorg window
+func (s *Subscriber) prune() {
+	if s.next <= s.cfg.ReorgDepth+1 {
+		return
+	}
+	oldest := s.next - 1 - s.cfg.ReorgDepth
+	for key, vLog := range s.seen {
+		if vLog.BlockNumber < oldest {
+			delete(s.seen, key)
+		}
+	}
+}
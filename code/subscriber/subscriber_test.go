@@ -0,0 +1,255 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// fakeChain is the node state shared by every connection
+type fakeChain struct {
+	mu   sync.Mutex
+	head uint64
+	logs []types.Log
+}
+
+func (c *fakeChain) set(head uint64, logs ...types.Log) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.head = head
+	c.logs = logs
+}
+
+// fakeClient is one connection, live logs and failures are pushed by the test
+type fakeClient struct {
+	chain   *fakeChain
+	polling bool
+	push    chan types.Log
+	fail    chan error
+	changes chan []types.Log
+}
+
+func newFakeClient(chain *fakeChain, polling bool) *fakeClient {
+	return &fakeClient{
+		chain:   chain,
+		polling: polling,
+		push:    make(chan types.Log),
+		fail:    make(chan error, 1),
+		changes: make(chan []types.Log, 4),
+	}
+}
+
+func (c *fakeClient) BlockNumber(ctx context.Context) (uint64, error) {
+	c.chain.mu.Lock()
+	defer c.chain.mu.Unlock()
+	return c.chain.head, nil
+}
+
+func (c *fakeClient) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	c.chain.mu.Lock()
+	defer c.chain.mu.Unlock()
+
+	var out []types.Log
+	for _, vLog := range c.chain.logs {
+		if vLog.BlockNumber >= q.FromBlock.Uint64() && vLog.BlockNumber <= q.ToBlock.Uint64() {
+			out = append(out, vLog)
+		}
+	}
+	return out, nil
+}
+
+func (c *fakeClient) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	if c.polling {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	sub := &fakeSub{err: make(chan error, 1), quit: make(chan struct{})}
+	go func() {
+		for {
+			select {
+			case vLog := <-c.push:
+				ch <- vLog
+			case err := <-c.fail:
+				sub.err <- err
+				return
+			case <-sub.quit:
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+func (c *fakeClient) NewFilter(ctx context.Context, q ethereum.FilterQuery) (string, error) {
+	return "0x1", nil
+}
+
+func (c *fakeClient) FilterChanges(ctx context.Context, id string) ([]types.Log, error) {
+	select {
+	case err := <-c.fail:
+		return nil, err
+	case logs := <-c.changes:
+		return logs, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (c *fakeClient) UninstallFilter(ctx context.Context, id string) error {
+	return nil
+}
+
+func (c *fakeClient) Close() {}
+
+type fakeSub struct {
+	err  chan error
+	quit chan struct{}
+	once sync.Once
+}
+
+func (s *fakeSub) Unsubscribe() {
+	s.once.Do(func() { close(s.quit) })
+}
+
+func (s *fakeSub) Err() <-chan error {
+	return s.err
+}
+
+// dialer hands out the given connections in order
+func dialer(clients ...*fakeClient) Dialer {
+	next := make(chan *fakeClient, len(clients))
+	for _, c := range clients {
+		next <- c
+	}
+	return func(ctx context.Context) (Client, error) {
+		select {
+		case c := <-next:
+			return c, nil
+		default:
+			return nil, errors.New("connection refused")
+		}
+	}
+}
+
+func testLog(number uint64, fork byte, index uint) types.Log {
+	return types.Log{
+		Address:     common.HexToAddress("0x9b8f68d305daef003632fec0df1be20e0b23be23"),
+		BlockNumber: number,
+		BlockHash:   common.BytesToHash([]byte{byte(number), fork}),
+		Index:       index,
+	}
+}
+
+func run(s *Subscriber) (chan Event, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan Event)
+	go s.Run(ctx, events)
+	return events, cancel
+}
+
+func next(t *testing.T, events chan Event) Event {
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected an event")
+		return Event{}
+	}
+}
+
+func expect(t *testing.T, ev Event, number uint64, fork byte, removed, backfilled bool) {
+	t.Helper()
+	want := testLog(number, fork, 0)
+	if ev.Log.BlockHash != want.BlockHash || ev.Removed != removed || ev.Backfilled != backfilled {
+		t.Errorf("Expected block %v fork %v removed=%v backfilled=%v, got %v removed=%v backfilled=%v",
+			number, fork, removed, backfilled, ev.Log.BlockNumber, ev.Removed, ev.Backfilled)
+	}
+}
+
+func TestDedupAndRemoved(t *testing.T) {
+	t.Parallel()
+	chain := &fakeChain{}
+	chain.set(9)
+	client := newFakeClient(chain, false)
+
+	events, cancel := run(New(dialer(client), ethereum.FilterQuery{}, Config{}))
+	defer cancel()
+
+	client.push <- testLog(10, 0, 0)
+	expect(t, next(t, events), 10, 0, false, false)
+
+	// a duplicate is dropped and a retraction of an unknown log is ignored
+	client.push <- testLog(10, 0, 0)
+	unknown := testLog(11, 7, 0)
+	unknown.Removed = true
+	client.push <- unknown
+
+	removed := testLog(10, 0, 0)
+	removed.Removed = true
+	client.push <- removed
+	expect(t, next(t, events), 10, 0, true, false)
+
+	client.push <- testLog(10, 1, 0)
+	expect(t, next(t, events), 10, 1, false, false)
+}
+
+func TestReconnectBackfillsGap(t *testing.T) {
+	t.Parallel()
+	chain := &fakeChain{}
+	chain.set(12, testLog(5, 0, 0), testLog(10, 0, 0))
+	first := newFakeClient(chain, false)
+	second := newFakeClient(chain, false)
+
+	s := New(dialer(first, second), ethereum.FilterQuery{FromBlock: big.NewInt(5)}, Config{
+		ReorgDepth: 4,
+		MinBackoff: time.Millisecond,
+	})
+	events, cancel := run(s)
+	defer cancel()
+
+	expect(t, next(t, events), 5, 0, false, true)
+	expect(t, next(t, events), 10, 0, false, true)
+
+	first.push <- testLog(13, 0, 0)
+	expect(t, next(t, events), 13, 0, false, false)
+
+	// while disconnected block 13 is reorged and blocks 14 and 15 are mined
+	chain.set(15, testLog(5, 0, 0), testLog(10, 0, 0), testLog(13, 1, 0), testLog(14, 1, 0), testLog(15, 1, 0))
+	first.fail <- errors.New("websocket: close 1006")
+
+	expect(t, next(t, events), 13, 0, true, true)
+	expect(t, next(t, events), 13, 1, false, true)
+	expect(t, next(t, events), 14, 1, false, true)
+	expect(t, next(t, events), 15, 1, false, true)
+
+	second.push <- testLog(15, 1, 0)
+	second.push <- testLog(16, 1, 0)
+	expect(t, next(t, events), 16, 1, false, false)
+}
+
+func TestPollingFallback(t *testing.T) {
+	t.Parallel()
+	chain := &fakeChain{}
+	chain.set(20)
+	client := newFakeClient(chain, true)
+
+	events, cancel := run(New(dialer(client), ethereum.FilterQuery{}, Config{PollInterval: time.Millisecond}))
+	defer cancel()
+
+	removed := testLog(21, 0, 0)
+	removed.Removed = true
+	client.changes <- []types.Log{testLog(21, 0, 0)}
+	client.changes <- []types.Log{removed, testLog(21, 1, 0)}
+
+	expect(t, next(t, events), 21, 0, false, false)
+	expect(t, next(t, events), 21, 0, true, false)
+	expect(t, next(t, events), 21, 1, false, false)
+}
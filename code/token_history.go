@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"ethereum-development-with-go/code/tokens"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+func main() {
+	// historical BalanceOf calls need an archive node
+	client, err := ethclient.Dial("https://mainnet.infura.io/v3/**********")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// 0x Protocol Token (ZRX)
+	tokenAddress := common.HexToAddress("0xe41d2489571d322189246dafa5ebde1f4699f498")
+	account := common.HexToAddress("0x9f4A156c93E95636A6Cf00f974828BE47956e8F8")
+
+	h, err := tokens.LoadHistory(context.Background(), client, tokenAddress, account, 14500000, 14582340)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("opening:", h.Opening) // opening: 0
+	for _, entry := range h.Entries {
+		fmt.Println(entry.BlockNumber, entry.TxHash.Hex(), entry.Delta, entry.Balance)
+	}
+	fmt.Println("closing:", h.Closing())
+
+	checks, err := h.Verify(context.Background(), client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, check := range checks {
+		if !check.Matches() {
+			fmt.Println("mismatch at", check.BlockNumber, check.Expected, check.Actual, check.Anomaly)
+		}
+	}
+	fmt.Println("anomalies:", tokens.Anomalies(checks)) // anomalies: []
+
+	file, err := os.Create("./history.csv")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	if err := h.WriteCSV(file); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := h.WriteJSONLines(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
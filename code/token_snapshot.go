@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"ethereum-development-with-go/code/tokens"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+func main() {
+	// BalanceOf and CodeAt at the snapshot block need an archive node
+	client, err := ethclient.Dial("https://mainnet.infura.io/v3/**********")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Star (STAR) Address
+	tokenAddress := common.HexToAddress("0x9b8f68d305daef003632fec0df1be20e0b23be23")
+	block := uint64(14582340)
+
+	s, err := tokens.TakeSnapshot(context.Background(), client, tokenAddress, block, tokens.SnapshotConfig{
+		FromBlock:        4000000,
+		Exclude:          []common.Address{common.HexToAddress("0x000000000000000000000000000000000000dEaD")},
+		ExcludeContracts: true,
+		OnProgress: func(from, to uint64) {
+			log.Printf("replayed %d-%d", from, to)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	checks, err := s.Verify(context.Background(), client, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, check := range checks {
+		if !check.Matches() {
+			log.Fatalf("balance mismatch: expected %s, got %s", check.Expected, check.Actual)
+		}
+	}
+
+	fmt.Println("holders:", len(s.Holders))   // holders: 5021
+	fmt.Println("excluded:", len(s.Excluded)) // excluded: 12
+	fmt.Println("total:", s.Total())
+
+	file, err := os.Create(fmt.Sprintf("./snapshot-%d.csv", block))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	if err := s.WriteCSV(file); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,307 @@
+package tokens
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+
+	token "ethereum-development-with-go/code/contracts_erc20"
+	"ethereum-development-with-go/code/indexer"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+ * Rebuild ERC20 account histories and holder sets from Transfer events.
+ */
+
+// Anomaly explains a difference between replayed and on-chain balances
+type Anomaly string
+
+// Anomalies found by History.Verify
+const (
+	// AnomalyFeeOnTransfer means a block with transfers moved the balance by
+	// a different amount than its Transfer events say
+	AnomalyFeeOnTransfer Anomaly = "fee-on-transfer"
+	// AnomalyRebasing means the balance changed in blocks without transfers
+	AnomalyRebasing Anomaly = "rebasing"
+)
+
+// Backend is the part of a node client needed to read a token
+type Backend interface {
+	bind.ContractCaller
+	bind.ContractFilterer
+}
+
+// Entry is a Transfer in or out of the tracked account
+type Entry struct {
+	BlockNumber uint64         `json:"blockNumber"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	TxHash      common.Hash    `json:"transactionHash"`
+	LogIndex    uint           `json:"logIndex"`
+	From        common.Address `json:"from"`
+	To          common.Address `json:"to"`
+	Amount      *big.Int       `json:"amount"`
+	// Delta is the signed change of the account balance, zero for self transfers
+	Delta *big.Int `json:"delta"`
+	// Balance is the account balance after the transfer
+	Balance *big.Int `json:"balance"`
+}
+
+// Check compares the replayed balance with BalanceOf at the end of a block
+type Check struct {
+	BlockNumber uint64   `json:"blockNumber"`
+	Expected    *big.Int `json:"expected"`
+	Actual      *big.Int `json:"actual"`
+	Anomaly     Anomaly  `json:"anomaly,omitempty"`
+}
+
+// Matches reports whether the replayed balance is the on-chain one
+func (c Check) Matches() bool {
+	return c.Expected.Cmp(c.Actual) == 0
+}
+
+// History is the transfer history of one account for one token
+type History struct {
+	Token   common.Address
+	Account common.Address
+	// From and To are the inclusive block range
+	From, To uint64
+	// Opening is the balance at the end of block From-1
+	Opening *big.Int
+	Entries []Entry
+}
+
+// LoadHistory collects the transfers in or out of account between from and
+// to inclusive, in chunks of indexer.DefaultChunkSize blocks split further
+// when the provider asks, and computes the running balance. The opening balance is read
+// with BalanceOf at block from-1, which needs an archive node for old blocks.
+func LoadHistory(ctx context.Context, backend Backend, tokenAddress, account common.Address, from, to uint64) (*History, error) {
+	caller, err := token.NewTokenCaller(tokenAddress, backend)
+	if err != nil {
+		return nil, err
+	}
+	filterer, err := token.NewTokenFilterer(tokenAddress, backend)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &History{
+		Token:   tokenAddress,
+		Account: account,
+		From:    from,
+		To:      to,
+		Opening: new(big.Int),
+	}
+	if from > 0 {
+		opening, err := caller.BalanceOf(&bind.CallOpts{Context: ctx, BlockNumber: new(big.Int).SetUint64(from - 1)}, account)
+		if err != nil {
+			return nil, err
+		}
+		h.Opening = opening
+	}
+
+	rules := [][2][]common.Address{
+		{{account}, nil},
+		{nil, {account}},
+	}
+
+	// the range is fetched in chunks providers accept
+	seen := make(map[logID]bool)
+	err = inChunks(from, to, indexer.DefaultChunkSize, func(from, to uint64) error {
+		opts := &bind.FilterOpts{Start: from, End: &to, Context: ctx}
+		var entries []Entry
+		for _, rule := range rules {
+			it, err := filterer.FilterTransfer(opts, rule[0], rule[1])
+			if err != nil {
+				return err
+			}
+			for it.Next() {
+				if it.Event.Raw.Removed {
+					continue
+				}
+				entries = append(entries, Entry{
+					BlockNumber: it.Event.Raw.BlockNumber,
+					BlockHash:   it.Event.Raw.BlockHash,
+					TxHash:      it.Event.Raw.TxHash,
+					LogIndex:    it.Event.Raw.Index,
+					From:        it.Event.From,
+					To:          it.Event.To,
+					Amount:      it.Event.Tokens,
+				})
+			}
+			err = it.Error()
+			it.Close()
+			if err != nil {
+				return err
+			}
+		}
+
+		// self transfers match both rules
+		for _, entry := range entries {
+			id := logID{entry.BlockHash, entry.LogIndex}
+			if !seen[id] {
+				seen[id] = true
+				h.Entries = append(h.Entries, entry)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(h.Entries, func(i, j int) bool {
+		if h.Entries[i].BlockNumber != h.Entries[j].BlockNumber {
+			return h.Entries[i].BlockNumber < h.Entries[j].BlockNumber
+		}
+		return h.Entries[i].LogIndex < h.Entries[j].LogIndex
+	})
+
+	balance := new(big.Int).Set(h.Opening)
+	for i := range h.Entries {
+		entry := &h.Entries[i]
+		entry.Delta = new(big.Int)
+		if entry.To == account {
+			entry.Delta.Add(entry.Delta, entry.Amount)
+		}
+		if entry.From == account {
+			entry.Delta.Sub(entry.Delta, entry.Amount)
+		}
+		balance.Add(balance, entry.Delta)
+		entry.Balance = new(big.Int).Set(balance)
+	}
+
+	return h, nil
+}
+
+// logID identifies a log
+type logID struct {
+	block common.Hash
+	index uint
+}
+
+// Closing returns the balance after the last transfer
+func (h *History) Closing() *big.Int {
+	if len(h.Entries) == 0 {
+		return h.Opening
+	}
+	return h.Entries[len(h.Entries)-1].Balance
+}
+
+// BalanceAt returns the replayed balance at the end of block number
+func (h *History) BalanceAt(number uint64) *big.Int {
+	balance := h.Opening
+	for _, entry := range h.Entries {
+		if entry.BlockNumber > number {
+			break
+		}
+		balance = entry.Balance
+	}
+	return balance
+}
+
+// Verify compares the replayed balance with BalanceOf at the end of every
+// block with transfers, at the block before each of them and at To. A
+// difference that changes across a block with transfers is flagged
+// AnomalyFeeOnTransfer, one that changes between transfers AnomalyRebasing.
+func (h *History) Verify(ctx context.Context, caller bind.ContractCaller) ([]Check, error) {
+	instance, err := token.NewTokenCaller(h.Token, caller)
+	if err != nil {
+		return nil, err
+	}
+
+	// checkpoints maps each block to check to whether it holds transfers
+	checkpoints := make(map[uint64]bool)
+	for _, entry := range h.Entries {
+		if entry.BlockNumber > h.From {
+			if _, ok := checkpoints[entry.BlockNumber-1]; !ok {
+				checkpoints[entry.BlockNumber-1] = false
+			}
+		}
+		checkpoints[entry.BlockNumber] = true
+	}
+	if _, ok := checkpoints[h.To]; !ok {
+		checkpoints[h.To] = false
+	}
+	blocks := make([]uint64, 0, len(checkpoints))
+	for number := range checkpoints {
+		blocks = append(blocks, number)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i] < blocks[j] })
+
+	var checks []Check
+	diff := new(big.Int)
+	for _, number := range blocks {
+		actual, err := instance.BalanceOf(&bind.CallOpts{Context: ctx, BlockNumber: new(big.Int).SetUint64(number)}, h.Account)
+		if err != nil {
+			return nil, err
+		}
+
+		check := Check{BlockNumber: number, Expected: h.BalanceAt(number), Actual: actual}
+		current := new(big.Int).Sub(actual, check.Expected)
+		if current.Cmp(diff) != 0 {
+			check.Anomaly = AnomalyRebasing
+			if checkpoints[number] {
+				check.Anomaly = AnomalyFeeOnTransfer
+			}
+		}
+		diff = current
+		checks = append(checks, check)
+	}
+
+	return checks, nil
+}
+
+// Anomalies returns the distinct anomalies found by Verify
+func Anomalies(checks []Check) []Anomaly {
+	found := make(map[Anomaly]bool)
+	var out []Anomaly
+	for _, check := range checks {
+		if check.Anomaly != "" && !found[check.Anomaly] {
+			found[check.Anomaly] = true
+			out = append(out, check.Anomaly)
+		}
+	}
+	return out
+}
+
+// WriteCSV writes the entries with a header row, amounts in base units
+func (h *History) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"block", "tx", "log_index", "from", "to", "amount", "delta", "balance"}); err != nil {
+		return err
+	}
+	for _, entry := range h.Entries {
+		record := []string{
+			strconv.FormatUint(entry.BlockNumber, 10),
+			entry.TxHash.Hex(),
+			strconv.FormatUint(uint64(entry.LogIndex), 10),
+			entry.From.Hex(),
+			entry.To.Hex(),
+			entry.Amount.String(),
+			entry.Delta.String(),
+			entry.Balance.String(),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSONLines writes one JSON object per entry
+func (h *History) WriteJSONLines(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, entry := range h.Entries {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
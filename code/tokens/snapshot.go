@@ -0,0 +1,242 @@
+package tokens
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io"
+	"math/big"
+	"sort"
+
+	token "ethereum-development-with-go/code/contracts_erc20"
+	"ethereum-development-with-go/code/indexer"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultSampleSize is the number of holders Snapshot.Verify checks when n is zero
+const DefaultSampleSize = 20
+
+// Holder is an address and its token balance
+type Holder struct {
+	Address common.Address `json:"address"`
+	Balance *big.Int       `json:"balance"`
+}
+
+// SnapshotConfig configures TakeSnapshot
+type SnapshotConfig struct {
+	// FromBlock is where the replay starts, usually the token deployment block
+	FromBlock uint64
+	// ChunkSize is the largest block range requested at once,
+	// indexer.DefaultChunkSize when zero
+	ChunkSize uint64
+	// Exclude lists addresses left out of the snapshot, the zero address
+	// is always excluded
+	Exclude []common.Address
+	// ExcludeContracts leaves out holders with code at the snapshot block
+	ExcludeContracts bool
+	// OnProgress is called after every replayed range
+	OnProgress func(from, to uint64)
+}
+
+// Snapshot is the set of token holders at the end of a block
+type Snapshot struct {
+	Token common.Address
+	Block uint64
+	// Holders are sorted by balance, largest first, then by address
+	Holders []Holder
+	// Excluded holds the holders left out, in the same order
+	Excluded []Holder
+}
+
+// TakeSnapshot replays every Transfer of a token up to block and returns the
+// non-zero balances
+func TakeSnapshot(ctx context.Context, backend Backend, tokenAddress common.Address, block uint64, cfg SnapshotConfig) (*Snapshot, error) {
+	filterer, err := token.NewTokenFilterer(tokenAddress, backend)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ChunkSize == 0 {
+		cfg.ChunkSize = indexer.DefaultChunkSize
+	}
+
+	balances := make(map[common.Address]*big.Int)
+	credit := func(account common.Address, amount *big.Int) {
+		balance, ok := balances[account]
+		if !ok {
+			balance = new(big.Int)
+			balances[account] = balance
+		}
+		balance.Add(balance, amount)
+	}
+
+	err = inChunks(cfg.FromBlock, block, cfg.ChunkSize, func(from, to uint64) error {
+		transfers, err := fetchTransfers(ctx, filterer, from, to)
+		if err != nil {
+			return err
+		}
+		for _, transfer := range transfers {
+			credit(transfer.To, transfer.Tokens)
+			credit(transfer.From, new(big.Int).Neg(transfer.Tokens))
+		}
+		if cfg.OnProgress != nil {
+			cfg.OnProgress(from, to)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := map[common.Address]bool{{}: true}
+	for _, address := range cfg.Exclude {
+		excluded[address] = true
+	}
+
+	s := &Snapshot{Token: tokenAddress, Block: block}
+	number := new(big.Int).SetUint64(block)
+	for address, balance := range balances {
+		if balance.Sign() <= 0 {
+			continue
+		}
+		holder := Holder{Address: address, Balance: balance}
+
+		skip := excluded[address]
+		if !skip && cfg.ExcludeContracts {
+			code, err := backend.CodeAt(ctx, address, number)
+			if err != nil {
+				return nil, err
+			}
+			skip = len(code) > 0
+		}
+		if skip {
+			s.Excluded = append(s.Excluded, holder)
+		} else {
+			s.Holders = append(s.Holders, holder)
+		}
+	}
+	sortHolders(s.Holders)
+	sortHolders(s.Excluded)
+
+	return s, nil
+}
+
+// fetchTransfers returns the Transfer events of a block range
+func fetchTransfers(ctx context.Context, filterer *token.TokenFilterer, from, to uint64) ([]*token.TokenTransfer, error) {
+	it, err := filterer.FilterTransfer(&bind.FilterOpts{Start: from, End: &to, Context: ctx}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var transfers []*token.TokenTransfer
+	for it.Next() {
+		if !it.Event.Raw.Removed {
+			transfers = append(transfers, it.Event)
+		}
+	}
+
+	return transfers, it.Error()
+}
+
+// inChunks calls fetch on consecutive ranges of at most size blocks from from
+// to to, halving a range the provider rejects as too large and growing back
+// afterwards. fetch must have no effect when it fails
+func inChunks(from, to, size uint64, fetch func(from, to uint64) error) error {
+	chunk := size
+	for from <= to {
+		end := from + chunk - 1
+		if end > to || end < from {
+			end = to
+		}
+
+		err := fetch(from, end)
+		if err != nil && indexer.IsRangeError(err) && end > from {
+			chunk = (end - from + 1) / 2
+			continue
+		}
+		if err != nil || end == to {
+			return err
+		}
+		from = end + 1
+		if chunk < size {
+			chunk *= 2
+			if chunk > size {
+				chunk = size
+			}
+		}
+	}
+
+	return nil
+}
+
+// sortHolders orders holders by balance, largest first, then by address
+func sortHolders(holders []Holder) {
+	sort.Slice(holders, func(i, j int) bool {
+		if c := holders[i].Balance.Cmp(holders[j].Balance); c != 0 {
+			return c > 0
+		}
+		return bytes.Compare(holders[i].Address[:], holders[j].Address[:]) < 0
+	})
+}
+
+// Total returns the sum of the included balances
+func (s *Snapshot) Total() *big.Int {
+	total := new(big.Int)
+	for _, holder := range s.Holders {
+		total.Add(total, holder.Balance)
+	}
+	return total
+}
+
+// Sample returns up to n holders spread evenly over the sorted list, always
+// including the largest one, so the same snapshot yields the same sample
+func (s *Snapshot) Sample(n int) []Holder {
+	if n <= 0 || n >= len(s.Holders) {
+		return s.Holders
+	}
+	sample := make([]Holder, n)
+	for i := range sample {
+		sample[i] = s.Holders[i*len(s.Holders)/n]
+	}
+	return sample
+}
+
+// Verify compares a sample of n holders, DefaultSampleSize when zero, with
+// BalanceOf at the snapshot block
+func (s *Snapshot) Verify(ctx context.Context, caller bind.ContractCaller, n int) ([]Check, error) {
+	instance, err := token.NewTokenCaller(s.Token, caller)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		n = DefaultSampleSize
+	}
+
+	var checks []Check
+	opts := &bind.CallOpts{Context: ctx, BlockNumber: new(big.Int).SetUint64(s.Block)}
+	for _, holder := range s.Sample(n) {
+		actual, err := instance.BalanceOf(opts, holder.Address)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, Check{BlockNumber: s.Block, Expected: holder.Balance, Actual: actual})
+	}
+
+	return checks, nil
+}
+
+// WriteCSV writes the holders with a header row, balances in base units
+func (s *Snapshot) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"address", "balance"}); err != nil {
+		return err
+	}
+	for _, holder := range s.Holders {
+		if err := cw.Write([]string{holder.Address.Hex(), holder.Balance.String()}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
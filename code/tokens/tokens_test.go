@@ -0,0 +1,293 @@
+package tokens
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	token "ethereum-development-with-go/code/contracts_erc20"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	tokenAddress = common.HexToAddress("0x9b8f68d305daef003632fec0df1be20e0b23be23")
+	alice        = common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	bob          = common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	carol        = common.HexToAddress("0x00000000000000000000000000000000000ca201")
+	transferSig  = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+	tokenABI     = mustABI()
+)
+
+func mustABI() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(token.TokenABI))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// move is an actual balance change, which a Transfer log may or may not describe
+type move struct {
+	block    uint64
+	from, to common.Address
+	amount   int64
+}
+
+// fakeToken serves Transfer logs and answers balanceOf at any block
+type fakeToken struct {
+	logs      []types.Log
+	moves     []move
+	contracts map[common.Address]bool
+	// maxRange rejects wider eth_getLogs ranges like providers do
+	maxRange uint64
+}
+
+// transfer records a Transfer event and the matching move, less fee
+func (f *fakeToken) transfer(block uint64, from, to common.Address, amount, fee int64) {
+	data, err := tokenABI.Events["Transfer"].Inputs.NonIndexed().Pack(big.NewInt(amount))
+	if err != nil {
+		panic(err)
+	}
+	f.logs = append(f.logs, types.Log{
+		Address:     tokenAddress,
+		Topics:      []common.Hash{transferSig, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+		Data:        data,
+		BlockNumber: block,
+		BlockHash:   common.BigToHash(new(big.Int).SetUint64(block)),
+		TxHash:      common.BigToHash(big.NewInt(int64(len(f.logs)))),
+		Index:       uint(len(f.logs)),
+	})
+	f.moves = append(f.moves, move{block, from, to, amount - fee})
+}
+
+func (f *fakeToken) balance(account common.Address, number uint64) *big.Int {
+	balance := new(big.Int)
+	for _, m := range f.moves {
+		if m.block > number {
+			continue
+		}
+		if m.to == account {
+			balance.Add(balance, big.NewInt(m.amount))
+		}
+		if m.from == account {
+			balance.Sub(balance, big.NewInt(m.amount))
+		}
+	}
+	return balance
+}
+
+func (f *fakeToken) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	if account == tokenAddress || f.contracts[account] {
+		return []byte{0x60, 0x80}, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeToken) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	method, err := tokenABI.MethodById(call.Data)
+	if err != nil || method.Name != "balanceOf" {
+		return nil, errors.New("execution reverted")
+	}
+	args, err := method.Inputs.Unpack(call.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	number := ^uint64(0)
+	if blockNumber != nil {
+		number = blockNumber.Uint64()
+	}
+	return method.Outputs.Pack(f.balance(args[0].(common.Address), number))
+}
+
+func (f *fakeToken) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	if f.maxRange > 0 && q.ToBlock != nil && q.ToBlock.Uint64()-q.FromBlock.Uint64()+1 > f.maxRange {
+		return nil, fmt.Errorf("block range is too wide, at most %d blocks", f.maxRange)
+	}
+	var out []types.Log
+	for _, vLog := range f.logs {
+		if vLog.BlockNumber < q.FromBlock.Uint64() || (q.ToBlock != nil && vLog.BlockNumber > q.ToBlock.Uint64()) {
+			continue
+		}
+		matched := true
+		for i, alternatives := range q.Topics {
+			if len(alternatives) == 0 {
+				continue
+			}
+			found := false
+			for _, topic := range alternatives {
+				found = found || vLog.Topics[i] == topic
+			}
+			matched = matched && found
+		}
+		if matched {
+			out = append(out, vLog)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeToken) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return nil, errors.New("subscriptions not supported")
+}
+
+func TestLoadHistory(t *testing.T) {
+	t.Parallel()
+	backend := &fakeToken{maxRange: 3}
+	backend.transfer(1, common.Address{}, alice, 1000, 0)
+	backend.transfer(3, alice, bob, 300, 0)
+	backend.transfer(5, bob, alice, 100, 0)
+	backend.transfer(5, alice, alice, 50, 0)
+	backend.transfer(6, bob, carol, 100, 0)
+
+	h, err := LoadHistory(context.Background(), backend, tokenAddress, alice, 2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Opening.Int64() != 1000 {
+		t.Errorf("Expected opening %v, got %v", 1000, h.Opening)
+	}
+	if len(h.Entries) != 3 {
+		t.Fatalf("Expected %v entries, got %v", 3, len(h.Entries))
+	}
+	for i, want := range []int64{700, 800, 800} {
+		if h.Entries[i].Balance.Int64() != want {
+			t.Errorf("Expected balance %v after entry %v, got %v", want, i, h.Entries[i].Balance)
+		}
+	}
+	if h.BalanceAt(4).Int64() != 700 || h.Closing().Int64() != 800 {
+		t.Errorf("Expected 700 at block 4 and 800 closing, got %v and %v", h.BalanceAt(4), h.Closing())
+	}
+
+	checks, err := h.Verify(context.Background(), backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, check := range checks {
+		if !check.Matches() || check.Anomaly != "" {
+			t.Errorf("Expected block %v to match, got %v and %v", check.BlockNumber, check.Expected, check.Actual)
+		}
+	}
+
+	var csvOut, jsonOut bytes.Buffer
+	if err := h.WriteCSV(&csvOut); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(csvOut.String()), "\n")
+	if len(lines) != 4 || !strings.HasSuffix(lines[1], ",300,-300,700") {
+		t.Errorf("Unexpected CSV %q", csvOut.String())
+	}
+	if err := h.WriteJSONLines(&jsonOut); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(jsonOut.String(), "\n") != 3 || !strings.Contains(jsonOut.String(), `"delta":-300`) {
+		t.Errorf("Unexpected JSON lines %q", jsonOut.String())
+	}
+}
+
+func TestVerifyAnomalies(t *testing.T) {
+	t.Parallel()
+
+	{
+		backend := &fakeToken{}
+		backend.transfer(1, common.Address{}, alice, 1000, 0)
+		backend.transfer(4, alice, bob, 100, 2)
+
+		h, err := LoadHistory(context.Background(), backend, tokenAddress, bob, 0, 6)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checks, err := h.Verify(context.Background(), backend)
+		if err != nil {
+			t.Fatal(err)
+		}
+		anomalies := Anomalies(checks)
+		if len(anomalies) != 1 || anomalies[0] != AnomalyFeeOnTransfer {
+			t.Errorf("Expected %v, got %v", AnomalyFeeOnTransfer, anomalies)
+		}
+	}
+
+	{
+		backend := &fakeToken{}
+		backend.transfer(1, common.Address{}, alice, 1000, 0)
+		backend.transfer(6, alice, bob, 100, 0)
+		// a rebase credits alice without any event
+		backend.moves = append(backend.moves, move{3, common.Address{}, alice, 10})
+
+		h, err := LoadHistory(context.Background(), backend, tokenAddress, alice, 0, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checks, err := h.Verify(context.Background(), backend)
+		if err != nil {
+			t.Fatal(err)
+		}
+		anomalies := Anomalies(checks)
+		if len(anomalies) != 1 || anomalies[0] != AnomalyRebasing {
+			t.Errorf("Expected %v, got %v", AnomalyRebasing, anomalies)
+		}
+	}
+}
+
+func TestTakeSnapshot(t *testing.T) {
+	t.Parallel()
+	pool := common.HexToAddress("0x00000000000000000000000000000000000f001")
+	treasury := common.HexToAddress("0x0000000000000000000000000000000000007ea5")
+	backend := &fakeToken{contracts: map[common.Address]bool{pool: true}}
+	backend.transfer(1, common.Address{}, alice, 1000, 0)
+	backend.transfer(2, alice, bob, 300, 0)
+	backend.transfer(2, alice, carol, 300, 0)
+	backend.transfer(3, alice, pool, 100, 0)
+	backend.transfer(3, alice, treasury, 50, 0)
+	backend.transfer(4, bob, alice, 300, 0)
+	backend.transfer(9, carol, bob, 300, 0)
+
+	s, err := TakeSnapshot(context.Background(), backend, tokenAddress, 5, SnapshotConfig{
+		ChunkSize:        2,
+		Exclude:          []common.Address{treasury},
+		ExcludeContracts: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Holder{{alice, big.NewInt(550)}, {carol, big.NewInt(300)}}
+	if len(s.Holders) != len(want) {
+		t.Fatalf("Expected %v holders, got %v", len(want), s.Holders)
+	}
+	for i := range want {
+		if s.Holders[i].Address != want[i].Address || s.Holders[i].Balance.Cmp(want[i].Balance) != 0 {
+			t.Errorf("Expected %v, got %v", want[i], s.Holders[i])
+		}
+	}
+	if len(s.Excluded) != 2 || s.Excluded[0].Address != pool || s.Excluded[1].Address != treasury {
+		t.Errorf("Expected the pool and treasury excluded, got %v", s.Excluded)
+	}
+	if s.Total().Int64() != 850 {
+		t.Errorf("Expected total %v, got %v", 850, s.Total())
+	}
+
+	checks, err := s.Verify(context.Background(), backend, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 2 || !checks[0].Matches() || !checks[1].Matches() {
+		t.Errorf("Expected the sample to match, got %v", checks)
+	}
+
+	var out bytes.Buffer
+	if err := s.WriteCSV(&out); err != nil {
+		t.Fatal(err)
+	}
+	expected := "address,balance\n" + alice.Hex() + ",550\n" + carol.Hex() + ",300\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
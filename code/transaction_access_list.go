@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+
+	helper "ethereum-development-with-go/code/helper"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func main() {
+	s, err := helper.New(&helper.Options{ProviderURI: "https://mainnet.infura.io/v3/**********"})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	from := "0x96216849c49358B10257cb55b28eA603c874b05E"
+	tokenAddress := "0xdAC17F958D2ee523a2206206994597C13D831ec7"
+	data, err := s.TransferTokensTxData("0x4592d8f8d7b001e72cb26a73e4fa1806a51ac79d", big.NewInt(1000000))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	result, err := s.CreateAccessList(from, tokenAddress, big.NewInt(0), data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("gas without list:", result.GasWithout) // gas without list: ...
+	fmt.Println("gas with list:", result.GasWith)       // gas with list: ...
+	for _, tuple := range result.AccessList {
+		fmt.Println(tuple.Address.Hex(), len(tuple.StorageKeys), "slots")
+	}
+
+	// lists only pay off for contracts the call reaches indirectly
+	list := result.AccessList
+	gas := result.GasWithout
+	if !result.Worth() {
+		list = nil
+	} else {
+		gas = result.GasWith
+	}
+
+	nonce, err := s.Client.PendingNonceAt(context.Background(), common.HexToAddress(from))
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx, err := s.SignDynamicFeeTx(nonce, tokenAddress, big.NewInt(0), gas, big.NewInt(2e9), big.NewInt(60e9), data, list, "fad9c8855b740a0b7ed4c221dbad0f33a83a49cad6b3fe8d5817ac83d38b6a19")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(tx.Hash().Hex(), "with", len(tx.AccessList()), "access list entries")
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+
+	"ethereum-development-with-go/code/dryrun"
+	helper "ethereum-development-with-go/code/helper"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func main() {
+	s, err := helper.New(&helper.Options{ProviderURI: "https://mainnet.infura.io/v3/**********"})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	from := common.HexToAddress("0x96216849c49358B10257cb55b28eA603c874b05E")
+	to := "0x4592d8f8d7b001e72cb26a73e4fa1806a51ac79d"
+	data, err := s.TransferTokensTxData(to, big.NewInt(1000000))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	nonce, err := s.Client.PendingNonceAt(context.Background(), from)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx, err := s.SignTx(nonce, "0xdAC17F958D2ee523a2206206994597C13D831ec7", big.NewInt(0), 100000, nil, data, "fad9c8855b740a0b7ed4c221dbad0f33a83a49cad6b3fe8d5817ac83d38b6a19")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// what if the sender had 10 ETH for gas
+	overrides := dryrun.Overrides{}.SetBalance(from, new(big.Int).Mul(big.NewInt(10), big.NewInt(1e18)))
+	result, err := s.SimulateTx(tx, overrides)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if !result.Success {
+		fmt.Println("would revert:", result.Revert) // would revert: execution reverted: ...
+		return
+	}
+	fmt.Println("gas used:", result.GasUsed, "estimated:", result.GasEstimated)
+	for _, l := range result.Logs {
+		fmt.Println("log", l.Address.Hex(), l.Topics)
+	}
+	for _, w := range result.Warnings {
+		fmt.Println("warning:", w)
+	}
+
+	if err := s.SendTx(tx); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"ethereum-development-with-go/code/calldata"
+	"ethereum-development-with-go/code/hdwallet"
+	"ethereum-development-with-go/code/offline"
+	"ethereum-development-with-go/code/sigdb"
+	"ethereum-development-with-go/code/util"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// online:  go run transaction_offline.go prepare -from 0x96216849c49358B10257cb55b28eA603c874b05E -to 0x4592d8f8d7b001e72cb26a73e4fa1806a51ac79d -value 0.1 -out tx.json
+// offline: go run transaction_offline.go sign -in tx.json -keystore ./tmp/UTC--... -out tx.signed
+// offline: go run transaction_offline.go sign -in tx.json -mnemonic "test test ... junk" -out tx.signed
+// online:  go run transaction_offline.go broadcast -in tx.signed
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal("usage: transaction_offline.go prepare|sign|broadcast [flags]")
+	}
+
+	switch os.Args[1] {
+	case "prepare":
+		prepare(os.Args[2:])
+	case "sign":
+		sign(os.Args[2:])
+	case "broadcast":
+		broadcast(os.Args[2:])
+	default:
+		log.Fatalf("unknown step %q", os.Args[1])
+	}
+}
+
+func prepare(args []string) {
+	fs := flag.NewFlagSet("prepare", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "https://rinkeby.infura.io/v3/**********", "node endpoint")
+	from := fs.String("from", "", "sender address")
+	to := fs.String("to", "", "recipient, empty for a contract creation")
+	value := fs.String("value", "0", "amount in ETH, or with a unit such as 1.5gwei")
+	data := fs.String("data", "", "hex calldata")
+	gas := fs.Uint64("gas", 0, "gas limit, estimated when zero")
+	legacy := fs.Bool("legacy", false, "use a gas price instead of EIP-1559 fees")
+	out := fs.String("out", "tx.json", "unsigned transaction file")
+	fs.Parse(args)
+
+	if !util.IsValidAddress(*from) {
+		log.Fatal("-from must be an address")
+	}
+	req := offline.Request{From: common.HexToAddress(*from), Gas: *gas, Legacy: *legacy}
+	if *to != "" {
+		if !util.IsValidAddress(*to) {
+			log.Fatal("-to must be an address")
+		}
+		address := common.HexToAddress(*to)
+		req.To = &address
+	}
+	amount, err := util.ParseUnits(*value, 18)
+	if err != nil {
+		log.Fatal(err)
+	}
+	req.Value = amount
+	if *data != "" {
+		if req.Data, err = hexutil.Decode(*data); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	client, err := ethclient.Dial(*rpcURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	u, err := offline.Prepare(context.Background(), client, req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := offline.WriteUnsigned(*out, u); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Print(u.Summary(calldata.NewDecoder(sigdb.Default())))
+	fmt.Println("written to", *out)
+}
+
+func sign(args []string) {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	in := fs.String("in", "tx.json", "unsigned transaction file")
+	keyFile := fs.String("keystore", "", "keystore file, the password is read from KEYSTORE_PASSWORD or stdin")
+	mnemonic := fs.String("mnemonic", "", "mnemonic, the passphrase is read from MNEMONIC_PASSPHRASE")
+	path := fs.String("path", "m/44'/60'/0'/0/0", "derivation path used with -mnemonic")
+	yes := fs.Bool("yes", false, "sign without asking for confirmation")
+	out := fs.String("out", "tx.signed", "signed raw transaction file")
+	fs.Parse(args)
+
+	u, err := offline.ReadUnsigned(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// no network from here on, the summary is all the signer gets to check
+	stdin := bufio.NewReader(os.Stdin)
+	fmt.Print(u.Summary(calldata.NewDecoder(sigdb.Default())))
+	if !*yes {
+		fmt.Print("sign this transaction? [y/N] ")
+		answer, _ := stdin.ReadString('\n')
+		if strings.ToLower(strings.TrimSpace(answer)) != "y" {
+			log.Fatal("not signed")
+		}
+	}
+
+	var key *ecdsa.PrivateKey
+	switch {
+	case *keyFile != "":
+		password, ok := os.LookupEnv("KEYSTORE_PASSWORD")
+		if !ok {
+			// echoed, run it where nobody is looking
+			fmt.Print("keystore password: ")
+			password, _ = stdin.ReadString('\n')
+			password = strings.TrimRight(password, "\r\n")
+		}
+		keyJSON, err := ioutil.ReadFile(*keyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		decrypted, err := keystore.DecryptKey(keyJSON, password)
+		if err != nil {
+			log.Fatal(err)
+		}
+		key = decrypted.PrivateKey
+	case *mnemonic != "":
+		wallet, err := hdwallet.NewFromMnemonic(*mnemonic, os.Getenv("MNEMONIC_PASSPHRASE"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		derivation, err := accounts.ParseDerivationPath(*path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if key, err = wallet.Derive(derivation); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatal("either -keystore or -mnemonic is required")
+	}
+
+	tx, err := u.Sign(key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(*out, []byte(hex.EncodeToString(raw)+"\n"), 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("signed", tx.Hash().Hex(), "written to", *out)
+}
+
+func broadcast(args []string) {
+	fs := flag.NewFlagSet("broadcast", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "https://rinkeby.infura.io/v3/**********", "node endpoint")
+	in := fs.String("in", "tx.signed", "signed raw transaction file")
+	fs.Parse(args)
+
+	content, err := ioutil.ReadFile(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(content)), "0x"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	client, err := ethclient.Dial(*rpcURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	decoded, err := offline.Broadcast(context.Background(), client, raw)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, warning := range decoded.Warnings {
+		log.Println("warning:", warning)
+	}
+
+	fmt.Printf("tx sent: %s from %s\n", decoded.Hash.Hex(), decoded.From.Hex()) // tx sent: 0x... from 0x...
+}
@@ -1,28 +1,45 @@
 package main
 
 import (
-	"encoding/hex"
 	"fmt"
 	"log"
+	"os"
 
-	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/rlp"
+	"ethereum-development-with-go/code/calldata"
+	"ethereum-development-with-go/code/rawtx"
+	"ethereum-development-with-go/code/sigdb"
 )
 
+// go run transaction_raw_decode.go 0x02f8b1...
 func main() {
 	rawTx := "f86d82069e843b9aca16825208944592d8f8d7b001e72cb26a73e4fa1806a51ac79d880de0b6b3a7640000802ba0cbd7194eeebccae26b92033d7495c2f63940afae88d4ae7e69af4617b3ea8b79a042ab7bed0b6a37b8e550c3a681dcd16a3eba037dc8bddeb91a2b3aff13c96141"
+	if len(os.Args) > 1 {
+		rawTx = os.Args[1]
+	}
 
-	tx := new(types.Transaction)
-	rawTxBytes, err := hex.DecodeString(rawTx)
-	rlp.DecodeBytes(rawTxBytes, &tx)
-
-	fmt.Println(tx.Hash().Hex())
-
-	msg, err := tx.AsMessage(types.NewEIP155Signer(tx.ChainId()), nil)
+	// pass a chain id instead of nil to reject transactions signed for another chain
+	decoder := rawtx.NewDecoder(nil, calldata.NewDecoder(sigdb.Default()))
+	decoded, err := decoder.DecodeHex(rawTx)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	fmt.Println(msg.From().Hex())
+	fmt.Println(decoded.Hash.Hex())
+	// 0x4f3fadcb6b928c1157c874fedc24c22b5f4c8e6b45147d44aeb2c6c25afafd16
+	fmt.Println(decoded.From.Hex())
 	// 0x96216849c49358B10257cb55b28eA603c874b05E
+
+	out, err := decoded.JSON()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(out))
+	// {
+	//   "type": 0,
+	//   "typeName": "legacy",
+	//   ...
+	//   "protected": true,
+	//   "signer": "eip155",
+	//   "from": "0x96216849c49358b10257cb55b28ea603c874b05e"
+	// }
 }
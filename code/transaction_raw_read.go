@@ -2,14 +2,15 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
 	"log"
 
+	"ethereum-development-with-go/code/rawtx"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/ethereum/go-ethereum/rlp"
 )
 
 func main() {
@@ -18,47 +19,21 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// works for legacy, EIP-155 and typed transactions alike
 	txHash := common.HexToHash("0xc2688ee9ed1eaac3f71f080b3abe2b5ace80871ef1f9602fba1af0931ea85a98")
-	tx, _, err := client.TransactionByHash(context.Background(), txHash)
+	publicKey, address, err := rawtx.PublicKeyByHash(context.Background(), client, txHash)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	v, r, s := tx.RawSignatureValues()
-	R := r.Bytes()
-	S := s.Bytes()
-	V := byte(v.Uint64() - 27 + 4)
+	fmt.Printf("%x\n", crypto.FromECDSAPub(publicKey)) // 04...
+	fmt.Println(address.Hex())                         // 0xb3de5b46f54d50cfed7fc6af4986d9077fe2ef81
 
-	sig := make([]byte, 65)
-	copy(sig[32-len(R):32], R)
-	copy(sig[64-len(S):64], S)
-	sig[64] = V
-	fmt.Println("V", V)
-	_ = V
-
-	//signer := types.HomesteadSigner{}
-	signer := types.NewEIP155Signer(tx.ChainId())
-	hash := signer.Hash(tx)
-
-	rawTx, err := rlp.EncodeToBytes(tx)
-	if err != nil {
-		panic(err)
-	}
-
-	fmt.Printf("%x\n", rawTx) //
-
-	publicKeyBytes, err := crypto.Ecrecover(hash.Bytes(), sig)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	fmt.Printf("%x\n", publicKeyBytes) //
-
-	publicKeyECDSA, err := crypto.UnmarshalPubkey(publicKeyBytes)
+	// only the owner of the address can decrypt this
+	ciphertext, err := ecies.Encrypt(rand.Reader, ecies.ImportECDSAPublic(publicKey), []byte("hello"), nil, nil)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	address := crypto.PubkeyToAddress(*publicKeyECDSA).Hex()
-	fmt.Println(address) // 0xb3de5b46f54d50cfed7fc6af4986d9077fe2ef81
+	fmt.Printf("%x\n", ciphertext)
 }
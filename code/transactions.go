@@ -6,16 +6,22 @@ import (
 	"log"
 	"math/big"
 
+	"ethereum-development-with-go/code/blockcheck"
+	"ethereum-development-with-go/code/calldata"
+	token "ethereum-development-with-go/code/contracts_erc20" // for demo
+	"ethereum-development-with-go/code/sigdb"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 func main() {
-	client, err := ethclient.Dial("https://cloudflare-eth.com")
+	rpcClient, err := rpc.Dial("https://cloudflare-eth.com")
 	if err != nil {
 		log.Fatal(err)
 	}
+	client := ethclient.NewClient(rpcClient)
 
 	blockNumber := big.NewInt(14582340)
 	block, err := client.BlockByNumber(context.Background(), blockNumber)
@@ -23,6 +29,23 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// 节点返回的交易和收据必须与区块头中的根一致
+	report, err := blockcheck.New(rpcClient).Verify(context.Background(), blockNumber)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, mismatch := range report.Mismatches {
+		fmt.Println(mismatch) // receiptsRoot: header 0x..., computed 0x...
+	}
+	if err := report.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	decoder := calldata.NewDecoder(sigdb.Default())
+	if err := decoder.RegisterJSON(token.TokenABI); err != nil {
+		log.Fatal(err)
+	}
+
 	for _, tx := range block.Transactions() {
 		fmt.Println(tx.Hash().Hex())        // 0x5d49fcaa394c97ec8a9c3e7bd9e8388d420fb050a52083ca52ff24b3b65bc9c2
 		fmt.Println(tx.Value().String())    // 10000000000000000
@@ -31,6 +54,13 @@ func main() {
 		fmt.Println(tx.Nonce())             // 110644
 		fmt.Println(tx.Data())              // []
 		fmt.Println(tx.To().Hex())          // 0x55fE59D8Ad77035154dDd0AD0388D09Dd4047A8e
+		if call, err := decoder.Decode(tx.Data()); err == nil {
+			out, err := call.JSON()
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(out)) // {"selector": "0xa9059cbb", "method": "transfer", ...}
+		}
 
 		chainID, err := client.NetworkID(context.Background())
 		if err != nil {
@@ -1,27 +1,159 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
 
+	"ethereum-development-with-go/code/trie"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethdb"
-	ethtrie "github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
+// go run trie.go load -db ./tmp/trie -in pairs.json -secure
+// go run trie.go get -db ./tmp/trie -root 0x... -key dog -secure
+// go run trie.go prove -db ./tmp/trie -root 0x... -key dog -secure -out proof.json
+// go run trie.go verify -root 0x... -key dog -proof proof.json -secure
+// go run trie.go dump -db ./tmp/trie -root 0x... -secure
+// go run trie.go diff -db ./tmp/trie -root 0x... -other 0x... -secure
 func main() {
-	diskdb := ethdb.NewMemDatabase()
-	triedb := ethtrie.NewDatabase(diskdb)
-	trie, err := ethtrie.New(common.Hash{}, triedb)
+	if len(os.Args) < 2 {
+		log.Fatal("usage: trie.go load|get|prove|verify|dump|diff [flags]")
+	}
+
+	fs := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	dir := fs.String("db", "./tmp/trie", "leveldb directory")
+	secure := fs.Bool("secure", false, "hash keys like the state and storage tries")
+	root := fs.String("root", "", "trie root")
+	other := fs.String("other", "", "root to diff against")
+	in := fs.String("in", "", "key/value pairs, .json object or .csv rows")
+	key := fs.String("key", "", "key, 0x-prefixed hex or text")
+	proofFile := fs.String("proof", "", "proof file to verify")
+	out := fs.String("out", "", "proof file to write, stdout when empty")
+	fs.Parse(os.Args[2:])
+
+	// verification needs nothing but the root and the proof
+	if os.Args[1] == "verify" {
+		verify(common.HexToHash(*root), parse(*key), *proofFile, *secure)
+		return
+	}
+
+	db, err := trie.OpenDatabase(*dir)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer db.Close()
 
-	trie.Update([]byte("foo"), []byte("bar"))
-	value, err := trie.TryGet([]byte("foo"))
+	switch os.Args[1] {
+	case "load":
+		pairs, err := trie.ReadFile(*in)
+		if err != nil {
+			log.Fatal(err)
+		}
+		t := open(db, "", *secure)
+		if err := t.Load(pairs); err != nil {
+			log.Fatal(err)
+		}
+		hash, err := t.Commit()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(hash.Hex())
+	case "get":
+		value, err := open(db, *root, *secure).Get(parse(*key))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if value == nil {
+			log.Fatalf("%s is not in the trie", *key)
+		}
+		fmt.Println(trie.Format(value))
+	case "prove":
+		nodes, err := open(db, *root, *secure).Prove(parse(*key))
+		if err != nil {
+			log.Fatal(err)
+		}
+		proof := make([]hexutil.Bytes, len(nodes))
+		for i, node := range nodes {
+			proof[i] = node
+		}
+		data, err := json.MarshalIndent(proof, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *out == "" {
+			fmt.Println(string(data))
+		} else if err := ioutil.WriteFile(*out, data, 0644); err != nil {
+			log.Fatal(err)
+		}
+	case "dump":
+		err := open(db, *root, *secure).Iterate(func(key, value []byte) error {
+			fmt.Println(trie.Format(key), trie.Format(value))
+			return nil
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "diff":
+		diff, err := trie.Diff(open(db, *root, *secure), open(db, *other, *secure))
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, change := range diff {
+			switch {
+			case change.Old == nil:
+				fmt.Println("+", trie.Format(change.Key), trie.Format(change.New))
+			case change.New == nil:
+				fmt.Println("-", trie.Format(change.Key), trie.Format(change.Old))
+			default:
+				fmt.Println("~", trie.Format(change.Key), trie.Format(change.Old), "->", trie.Format(change.New))
+			}
+		}
+	default:
+		log.Fatalf("unknown command %q", os.Args[1])
+	}
+}
+
+func open(db *trie.Database, root string, secure bool) *trie.Trie {
+	t, err := db.Open(common.HexToHash(root), secure)
 	if err != nil {
 		log.Fatal(err)
 	}
+	return t
+}
 
-	fmt.Println(string(value)) // bar
+func parse(s string) []byte {
+	b, err := trie.Parse(s)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return b
+}
+
+func verify(root common.Hash, key []byte, proofFile string, secure bool) {
+	data, err := ioutil.ReadFile(proofFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var proof []hexutil.Bytes
+	if err := json.Unmarshal(data, &proof); err != nil {
+		log.Fatal(err)
+	}
+	nodes := make([][]byte, len(proof))
+	for i, node := range proof {
+		nodes[i] = node
+	}
+
+	value, err := trie.VerifyProof(root, key, nodes, secure)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if value == nil {
+		fmt.Println("proven absent")
+		return
+	}
+	fmt.Println(trie.Format(value))
 }
@@ -0,0 +1,121 @@
+package trie
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Pair is a key and its value
+type Pair struct {
+	Key   []byte
+	Value []byte
+}
+
+// Parse reads a 0x-prefixed string as hex and anything else as text
+func Parse(s string) ([]byte, error) {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return hexutil.Decode("0x" + s[2:])
+	}
+	return []byte(s), nil
+}
+
+// Format is the inverse of Parse, printable text stays text
+func Format(b []byte) string {
+	s := string(b)
+	if len(b) == 0 || strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return hexutil.Encode(b)
+	}
+	for _, r := range s {
+		if r == unicode.ReplacementChar || !unicode.IsPrint(r) {
+			return hexutil.Encode(b)
+		}
+	}
+	return s
+}
+
+// ReadJSON reads an object of keys to values, sorted by key
+func ReadJSON(r io.Reader) ([]Pair, error) {
+	var object map[string]string
+	if err := json.NewDecoder(r).Decode(&object); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]Pair, len(keys))
+	for i, key := range keys {
+		pair, err := parsePair(key, object[key])
+		if err != nil {
+			return nil, err
+		}
+		pairs[i] = pair
+	}
+	return pairs, nil
+}
+
+// ReadCSV reads key,value rows, a key,value header row is skipped
+func ReadCSV(r io.Reader) ([]Pair, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > 0 && strings.EqualFold(rows[0][0], "key") && strings.EqualFold(rows[0][1], "value") {
+		rows = rows[1:]
+	}
+
+	pairs := make([]Pair, len(rows))
+	for i, row := range rows {
+		pair, err := parsePair(row[0], row[1])
+		if err != nil {
+			return nil, err
+		}
+		pairs[i] = pair
+	}
+	return pairs, nil
+}
+
+// ReadFile reads pairs from a .json or .csv file
+func ReadFile(path string) ([]Pair, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ReadJSON(f)
+	case ".csv":
+		return ReadCSV(f)
+	default:
+		return nil, fmt.Errorf("trie: unknown format of %s, want .json or .csv", path)
+	}
+}
+
+func parsePair(key, value string) (Pair, error) {
+	k, err := Parse(key)
+	if err != nil {
+		return Pair{}, fmt.Errorf("key %q: %v", key, err)
+	}
+	v, err := Parse(value)
+	if err != nil {
+		return Pair{}, fmt.Errorf("value of %q: %v", key, err)
+	}
+	return Pair{Key: k, Value: v}, nil
+}
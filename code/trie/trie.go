@@ -0,0 +1,254 @@
+package trie
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/leveldb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	ethtrie "github.com/ethereum/go-ethereum/trie"
+)
+
+/*
+ * Build, persist, prove and compare Merkle Patricia tries, the structure
+ * behind state, storage, transaction and receipt roots.
+ */
+
+var (
+	// ErrInvalidProof is returned when a proof does not lead to its root
+	ErrInvalidProof = errors.New("trie: invalid proof")
+)
+
+// backing is implemented by both the plain and the secure trie
+type backing interface {
+	TryGet(key []byte) ([]byte, error)
+	TryUpdate(key, value []byte) error
+	TryDelete(key []byte) error
+	Hash() common.Hash
+	Commit(onleaf ethtrie.LeafCallback) (common.Hash, int, error)
+	Prove(key []byte, fromLevel uint, proofDb ethdb.KeyValueWriter) error
+	NodeIterator(start []byte) ethtrie.NodeIterator
+}
+
+// Database holds the nodes of any number of tries
+type Database struct {
+	disk   ethdb.KeyValueStore
+	triedb *ethtrie.Database
+}
+
+// OpenDatabase opens or creates a goleveldb database in dir
+func OpenDatabase(dir string) (*Database, error) {
+	disk, err := leveldb.New(dir, 16, 16, "", false)
+	if err != nil {
+		return nil, err
+	}
+	return newDatabase(disk), nil
+}
+
+// NewMemoryDatabase returns a database that lives as long as the process
+func NewMemoryDatabase() *Database {
+	return newDatabase(memorydb.New())
+}
+
+func newDatabase(disk ethdb.KeyValueStore) *Database {
+	// preimages let secure tries give back the keys they hashed
+	return &Database{disk: disk, triedb: ethtrie.NewDatabaseWithConfig(disk, &ethtrie.Config{Preimages: true})}
+}
+
+// Close closes the underlying store
+func (db *Database) Close() error {
+	return db.disk.Close()
+}
+
+// Open returns the trie at root, an empty root starts a new trie. Secure tries
+// hash their keys like the state and storage tries do
+func (db *Database) Open(root common.Hash, secure bool) (*Trie, error) {
+	var (
+		t   backing
+		err error
+	)
+	if secure {
+		t, err = ethtrie.NewSecure(root, db.triedb)
+	} else {
+		t, err = ethtrie.New(root, db.triedb)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Trie{db: db, trie: t, secure: secure}, nil
+}
+
+// Trie is a plain or secure trie in a Database
+type Trie struct {
+	db     *Database
+	trie   backing
+	secure bool
+}
+
+// Secure reports whether keys are hashed
+func (t *Trie) Secure() bool {
+	return t.secure
+}
+
+// Get returns the value of key, nil when it is absent
+func (t *Trie) Get(key []byte) ([]byte, error) {
+	return t.trie.TryGet(key)
+}
+
+// Put sets key to value, an empty value deletes the key
+func (t *Trie) Put(key, value []byte) error {
+	return t.trie.TryUpdate(key, value)
+}
+
+// Delete removes key
+func (t *Trie) Delete(key []byte) error {
+	return t.trie.TryDelete(key)
+}
+
+// Load puts all pairs
+func (t *Trie) Load(pairs []Pair) error {
+	for _, pair := range pairs {
+		if err := t.Put(pair.Key, pair.Value); err != nil {
+			return fmt.Errorf("key %s: %v", Format(pair.Key), err)
+		}
+	}
+	return nil
+}
+
+// Hash returns the root without writing anything
+func (t *Trie) Hash() common.Hash {
+	return t.trie.Hash()
+}
+
+// Commit writes the trie to its database and returns the root
+func (t *Trie) Commit() (common.Hash, error) {
+	root, _, err := t.trie.Commit(nil)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := t.db.triedb.Commit(root, false, nil); err != nil {
+		return common.Hash{}, err
+	}
+	return root, nil
+}
+
+// Prove returns the nodes on the path to key from the root down. The proof
+// of an absent key shows where the path ends
+func (t *Trie) Prove(key []byte) ([][]byte, error) {
+	// unlike its other methods, the secure trie proves the key as given
+	if t.secure {
+		key = crypto.Keccak256(key)
+	}
+	var proof nodeList
+	if err := t.trie.Prove(key, 0, &proof); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// Iterate calls fn for every pair in path order. Keys of a secure trie come
+// back as set when the preimage is known, else as their hash
+func (t *Trie) Iterate(fn func(key, value []byte) error) error {
+	it := ethtrie.NewIterator(t.trie.NodeIterator(nil))
+	for it.Next() {
+		if err := fn(t.key(it.Key), it.Value); err != nil {
+			return err
+		}
+	}
+	return it.Err
+}
+
+// key maps a path back to the key that was set
+func (t *Trie) key(path []byte) []byte {
+	if secure, ok := t.trie.(*ethtrie.SecureTrie); ok {
+		if key := secure.GetKey(path); key != nil {
+			return key
+		}
+	}
+	return common.CopyBytes(path)
+}
+
+// Change is a key whose value differs between two tries, Old is nil for an
+// added key and New for a removed one
+type Change struct {
+	Key []byte
+	Old []byte
+	New []byte
+}
+
+// Diff returns the changes from a to b in path order, only the subtries that
+// differ are visited
+func Diff(a, b *Trie) ([]Change, error) {
+	if a.secure != b.secure {
+		return nil, errors.New("trie: cannot diff a secure and a plain trie")
+	}
+
+	changes := make(map[string]*Change)
+	var paths []string
+	collect := func(from, to *Trie, set func(c *Change, value []byte)) error {
+		diff, _ := ethtrie.NewDifferenceIterator(from.trie.NodeIterator(nil), to.trie.NodeIterator(nil))
+		it := ethtrie.NewIterator(diff)
+		for it.Next() {
+			path := string(it.Key)
+			c, ok := changes[path]
+			if !ok {
+				c = &Change{Key: to.key(it.Key)}
+				changes[path] = c
+				paths = append(paths, path)
+			}
+			set(c, common.CopyBytes(it.Value))
+		}
+		return it.Err
+	}
+
+	if err := collect(a, b, func(c *Change, value []byte) { c.New = value }); err != nil {
+		return nil, err
+	}
+	if err := collect(b, a, func(c *Change, value []byte) { c.Old = value }); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(paths)
+	diff := make([]Change, len(paths))
+	for i, path := range paths {
+		diff[i] = *changes[path]
+	}
+	return diff, nil
+}
+
+// VerifyProof checks proof against root and returns the value of key, nil
+// when the proof shows the key is absent
+func VerifyProof(root common.Hash, key []byte, proof [][]byte, secure bool) ([]byte, error) {
+	if secure {
+		key = crypto.Keccak256(key)
+	}
+	db := memorydb.New()
+	for _, node := range proof {
+		if err := db.Put(crypto.Keccak256(node), node); err != nil {
+			return nil, err
+		}
+	}
+
+	value, err := ethtrie.VerifyProof(root, key, db)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	return value, nil
+}
+
+// nodeList collects proof nodes in the order they are written
+type nodeList [][]byte
+
+func (n *nodeList) Put(key []byte, value []byte) error {
+	*n = append(*n, common.CopyBytes(value))
+	return nil
+}
+
+func (n *nodeList) Delete(key []byte) error {
+	return errors.New("trie: proofs are append only")
+}
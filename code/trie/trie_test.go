@@ -0,0 +1,178 @@
+package trie
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var pairs = []Pair{
+	{Key: []byte("doe"), Value: []byte("reindeer")},
+	{Key: []byte("dog"), Value: []byte("puppy")},
+	{Key: []byte("dogglesworth"), Value: []byte("cat")},
+}
+
+func load(t *testing.T, db *Database, secure bool, pairs []Pair) *Trie {
+	tr, err := db.Open(common.Hash{}, secure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Load(pairs); err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+func TestRoot(t *testing.T) {
+	t.Parallel()
+	// the vector of geth's own trie tests
+	expected := common.HexToHash("0x8aad789dff2f538bca5d8ea56e8abe10f4c7ba3a5dea95fea4cd6e7c3a1168d3")
+	if root := load(t, NewMemoryDatabase(), false, pairs).Hash(); root != expected {
+		t.Errorf("Expected %v, got %v", expected.Hex(), root.Hex())
+	}
+	if root := load(t, NewMemoryDatabase(), true, pairs).Hash(); root == expected {
+		t.Errorf("Expected a secure trie to hash its keys")
+	}
+}
+
+func TestPersist(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	db, err := OpenDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := load(t, db, true, pairs).Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if db, err = OpenDatabase(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tr, err := db.Open(root, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := tr.Get([]byte("dog")); string(value) != "puppy" {
+		t.Errorf("Expected puppy, got %q", value)
+	}
+
+	// iteration follows the hashed keys, the preimages give back the keys
+	seen := make(map[string]string)
+	if err := tr.Iterate(func(key, value []byte) error {
+		seen[string(key)] = string(value)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 3 || seen["dogglesworth"] != "cat" {
+		t.Errorf("Expected the three pairs back, got %v", seen)
+	}
+
+	if _, err := db.Open(common.Hash{1}, true); err == nil {
+		t.Errorf("Expected an error for an unknown root")
+	}
+}
+
+func TestProof(t *testing.T) {
+	t.Parallel()
+	for _, secure := range []bool{false, true} {
+		tr := load(t, NewMemoryDatabase(), secure, pairs)
+		root := tr.Hash()
+
+		proof, err := tr.Prove([]byte("doe"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value, err := VerifyProof(root, []byte("doe"), proof, secure); err != nil || string(value) != "reindeer" {
+			t.Errorf("Expected reindeer, got %q and %v", value, err)
+		}
+		if _, err := VerifyProof(common.Hash{1}, []byte("doe"), proof, secure); !errors.Is(err, ErrInvalidProof) {
+			t.Errorf("Expected %v for the wrong root, got %v", ErrInvalidProof, err)
+		}
+
+		// absence is proven by where the path ends
+		if proof, err = tr.Prove([]byte("cat")); err != nil {
+			t.Fatal(err)
+		}
+		if value, err := VerifyProof(root, []byte("cat"), proof, secure); err != nil || value != nil {
+			t.Errorf("Expected no value, got %q and %v", value, err)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+	db := NewMemoryDatabase()
+	a := load(t, db, true, pairs)
+	b := load(t, db, true, []Pair{
+		{Key: []byte("doe"), Value: []byte("reindeer")},
+		{Key: []byte("dog"), Value: []byte("hound")},
+		{Key: []byte("horse"), Value: []byte("stallion")},
+	})
+	if _, err := a.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][2]string{
+		"dog":          {"puppy", "hound"},
+		"dogglesworth": {"cat", ""},
+		"horse":        {"", "stallion"},
+	}
+	if len(diff) != len(expected) {
+		t.Fatalf("Expected %d changes, got %d", len(expected), len(diff))
+	}
+	for _, change := range diff {
+		values, ok := expected[string(change.Key)]
+		if !ok || string(change.Old) != values[0] || string(change.New) != values[1] {
+			t.Errorf("Expected %v, got %q from %q to %q", values, change.Key, change.Old, change.New)
+		}
+	}
+
+	if diff, _ := Diff(a, a); len(diff) != 0 {
+		t.Errorf("Expected no changes, got %v", diff)
+	}
+	if _, err := Diff(a, load(t, db, false, pairs)); err == nil {
+		t.Errorf("Expected an error for a plain and a secure trie")
+	}
+}
+
+func TestReadPairs(t *testing.T) {
+	t.Parallel()
+	fromJSON, err := ReadJSON(strings.NewReader(`{"dog": "puppy", "0x01": "0xff"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromCSV, err := ReadCSV(strings.NewReader("key,value\n0x01, 0xff\ndog,puppy\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, read := range [][]Pair{fromJSON, fromCSV} {
+		if len(read) != 2 || !bytes.Equal(read[0].Key, []byte{1}) || !bytes.Equal(read[0].Value, []byte{0xff}) || string(read[1].Value) != "puppy" {
+			t.Errorf("Expected 0x01 and dog, got %v", read)
+		}
+	}
+	if _, err := ReadCSV(strings.NewReader("0xzz,1\n")); err == nil {
+		t.Errorf("Expected an error for bad hex")
+	}
+
+	for _, s := range []string{"dog", "0x00ff", "0x"} {
+		b, _ := Parse(s)
+		if Format(b) != s {
+			t.Errorf("Expected %v, got %v", s, Format(b))
+		}
+	}
+	if Format([]byte("0xab")) != "0x30786162" {
+		t.Errorf("Expected text that looks like hex to be hex encoded, got %v", Format([]byte("0xab")))
+	}
+}
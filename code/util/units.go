@@ -0,0 +1,315 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxExponent bounds the scientific notation exponent accepted by ParseUnits
+const maxExponent = 256
+
+var (
+	// ErrInvalidAmount is returned when an amount string cannot be parsed
+	ErrInvalidAmount = errors.New("invalid amount")
+	// ErrUnknownUnit is returned when an amount has an unrecognised unit suffix
+	ErrUnknownUnit = errors.New("unknown unit")
+	// ErrTooManyDecimals is returned when an amount has more fractional digits than the decimals allow
+	ErrTooManyDecimals = errors.New("too many decimal places")
+	// ErrInvalidDecimals is returned for a negative decimals value
+	ErrInvalidDecimals = errors.New("invalid decimals")
+)
+
+// units maps ether denominations to their number of decimals
+var units = map[string]int{
+	"wei":        0,
+	"kwei":       3,
+	"babbage":    3,
+	"mwei":       6,
+	"lovelace":   6,
+	"gwei":       9,
+	"shannon":    9,
+	"szabo":      12,
+	"microether": 12,
+	"finney":     15,
+	"milliether": 15,
+	"ether":      18,
+	"eth":        18,
+}
+
+// UnitDecimals returns the number of decimals of an ether denomination such
+// as "gwei" or "ether", case insensitive
+func UnitDecimals(unit string) (int, bool) {
+	d, ok := units[strings.ToLower(unit)]
+	return d, ok
+}
+
+// RoundingMode controls how FormatUnits drops digits beyond the requested precision
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds to nearest, ties away from zero
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds to nearest, ties to the even digit
+	RoundHalfEven
+	// RoundDown truncates towards zero
+	RoundDown
+	// RoundUp rounds away from zero
+	RoundUp
+)
+
+// WholeUnits as FormatOptions.Precision rounds to whole units, dropping all
+// fractional digits
+const WholeUnits = -1
+
+// FormatOptions configures FormatUnits, the zero value keeps every digit
+type FormatOptions struct {
+	// Precision is the number of fractional digits to keep, zero keeps all of
+	// them and WholeUnits none
+	Precision int
+	// Rounding is applied when Precision drops digits
+	Rounding RoundingMode
+	// ThousandsSeparator is inserted between groups of three integer digits
+	ThousandsSeparator string
+	// TrimZeros removes trailing fractional zeros
+	TrimZeros bool
+}
+
+// ParseUnits parses an amount such as "1.5 gwei", "0.02 ether", "1e-6" or "100"
+// into base units. A unit suffix selects the matching ether denomination,
+// otherwise decimals is used. Inputs with more fractional digits than the
+// resulting decimals allow are rejected rather than truncated.
+func ParseUnits(value string, decimals int) (*big.Int, error) {
+	if decimals < 0 {
+		return nil, ErrInvalidDecimals
+	}
+
+	number, unit := splitUnit(strings.TrimSpace(value))
+	if unit != "" {
+		d, ok := UnitDecimals(unit)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownUnit, unit)
+		}
+		decimals = d
+	}
+
+	mantissa, scale, err := parseNumber(number)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", err, value)
+	}
+
+	shift := decimals - scale
+	if shift >= 0 {
+		return mantissa.Mul(mantissa, pow10(shift)), nil
+	}
+
+	q, r := new(big.Int).QuoRem(mantissa, pow10(-shift), new(big.Int))
+	if r.Sign() != 0 {
+		return nil, fmt.Errorf("%w: %q has more than %d decimals", ErrTooManyDecimals, value, decimals)
+	}
+
+	return q, nil
+}
+
+// ToBaseUnits converts a string, float64, int64, *big.Int or *big.Float
+// amount into base units, returning an error instead of silently using zero
+func ToBaseUnits(iamount interface{}, decimals int) (*big.Int, error) {
+	switch v := iamount.(type) {
+	case string:
+		return ParseUnits(v, decimals)
+	case float64:
+		// the shortest representation is what the caller wrote, not the binary approximation
+		return ParseUnits(strconv.FormatFloat(v, 'g', -1, 64), decimals)
+	case int64:
+		return ParseUnits(strconv.FormatInt(v, 10), decimals)
+	case int:
+		return ParseUnits(strconv.Itoa(v), decimals)
+	case *big.Int:
+		if v == nil {
+			return nil, ErrInvalidAmount
+		}
+		return ParseUnits(v.String(), decimals)
+	case *big.Float:
+		if v == nil {
+			return nil, ErrInvalidAmount
+		}
+		return ParseUnits(v.Text('g', -1), decimals)
+	default:
+		return nil, fmt.Errorf("%w: unsupported type %T", ErrInvalidAmount, iamount)
+	}
+}
+
+// FormatUnits formats a base unit amount with the given decimals for display
+func FormatUnits(amount *big.Int, decimals int, opts *FormatOptions) (string, error) {
+	if amount == nil {
+		return "", ErrInvalidAmount
+	}
+	if decimals < 0 {
+		return "", ErrInvalidDecimals
+	}
+	if opts == nil {
+		opts = &FormatOptions{TrimZeros: true}
+	}
+
+	abs := new(big.Int).Abs(amount)
+	precision := opts.Precision
+	switch {
+	case precision == WholeUnits:
+		precision = 0
+	case precision <= 0 || precision > decimals:
+		precision = decimals
+	}
+
+	if drop := decimals - precision; drop > 0 {
+		abs = roundQuo(abs, pow10(drop), opts.Rounding)
+	}
+
+	digits := abs.String()
+	if len(digits) <= precision {
+		digits = strings.Repeat("0", precision-len(digits)+1) + digits
+	}
+	intPart := digits[:len(digits)-precision]
+	fracPart := digits[len(digits)-precision:]
+
+	if opts.TrimZeros {
+		fracPart = strings.TrimRight(fracPart, "0")
+	}
+	if opts.ThousandsSeparator != "" {
+		intPart = groupThousands(intPart, opts.ThousandsSeparator)
+	}
+
+	var sb strings.Builder
+	if amount.Sign() < 0 && abs.Sign() != 0 {
+		sb.WriteByte('-')
+	}
+	sb.WriteString(intPart)
+	if fracPart != "" {
+		sb.WriteByte('.')
+		sb.WriteString(fracPart)
+	}
+
+	return sb.String(), nil
+}
+
+// splitUnit separates a trailing alphabetic unit from the numeric part
+func splitUnit(s string) (string, string) {
+	i := strings.LastIndexFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	unit := s[i+1:]
+	if unit == "" {
+		return s, ""
+	}
+
+	number := strings.TrimSpace(s[:i+1])
+	// a bare exponent marker belongs to the number, e.g. "1e" is malformed rather than a unit
+	if strings.EqualFold(unit, "e") {
+		return s, ""
+	}
+
+	return number, unit
+}
+
+// parseNumber parses a decimal number with an optional exponent into an
+// integer mantissa and the number of decimal places it carries
+func parseNumber(s string) (*big.Int, int, error) {
+	if s == "" {
+		return nil, 0, ErrInvalidAmount
+	}
+
+	exp := 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil || e > maxExponent || e < -maxExponent {
+			return nil, 0, ErrInvalidAmount
+		}
+		exp = e
+		s = s[:i]
+	}
+
+	negative := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		negative = true
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	if intPart == "" && fracPart == "" {
+		return nil, 0, ErrInvalidAmount
+	}
+	for _, r := range intPart + fracPart {
+		if r < '0' || r > '9' {
+			return nil, 0, ErrInvalidAmount
+		}
+	}
+
+	mantissa, ok := new(big.Int).SetString(intPart+fracPart, 10)
+	if !ok {
+		return nil, 0, ErrInvalidAmount
+	}
+	if negative {
+		mantissa.Neg(mantissa)
+	}
+
+	return mantissa, len(fracPart) - exp, nil
+}
+
+// roundQuo divides a non-negative x by y using the given rounding mode
+func roundQuo(x, y *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(x, y, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+
+	half := new(big.Int).Lsh(r, 1).Cmp(y)
+	switch mode {
+	case RoundDown:
+	case RoundUp:
+		q.Add(q, big.NewInt(1))
+	case RoundHalfEven:
+		if half > 0 || (half == 0 && q.Bit(0) == 1) {
+			q.Add(q, big.NewInt(1))
+		}
+	default:
+		if half >= 0 {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+
+	return q
+}
+
+// groupThousands inserts sep between groups of three digits
+func groupThousands(digits string, sep string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+
+	var sb strings.Builder
+	head := len(digits) % 3
+	if head > 0 {
+		sb.WriteString(digits[:head])
+	}
+	for i := head; i < len(digits); i += 3 {
+		if sb.Len() > 0 {
+			sb.WriteString(sep)
+		}
+		sb.WriteString(digits[i : i+3])
+	}
+
+	return sb.String()
+}
+
+// pow10 returns 10^n
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,126 @@
+package util
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestParseUnits(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		value    string
+		decimals int
+		expected string
+	}{
+		{"1.5 gwei", 18, "1500000000"},
+		{"0.02 ether", 6, "20000000000000000"},
+		{"0.02ETH", 0, "20000000000000000"},
+		{"1e-6", 18, "1000000000000"},
+		{"1.25E2", 2, "12500"},
+		{"100", 6, "100000000"},
+		{"-0.5", 1, "-5"},
+		{".5", 1, "5"},
+		{"42 wei", 18, "42"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseUnits(tt.value, tt.decimals)
+		if err != nil {
+			t.Errorf("Got error for %q: %s", tt.value, err)
+			continue
+		}
+		if got.String() != tt.expected {
+			t.Errorf("Expected %s for %q, got %s", tt.expected, tt.value, got)
+		}
+	}
+}
+
+func TestParseUnitsErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		value    string
+		decimals int
+		expected error
+	}{
+		{"1.0000001", 6, ErrTooManyDecimals},
+		{"0.1 wei", 18, ErrTooManyDecimals},
+		{"1e-7", 6, ErrTooManyDecimals},
+		{"1 bitcoin", 18, ErrUnknownUnit},
+		{"", 18, ErrInvalidAmount},
+		{"1.2.3", 18, ErrInvalidAmount},
+		{"1e", 18, ErrInvalidAmount},
+		{"0x10", 18, ErrInvalidAmount},
+		{"1", -1, ErrInvalidDecimals},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseUnits(tt.value, tt.decimals)
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("Expected %v for %q, got %v", tt.expected, tt.value, err)
+		}
+	}
+}
+
+func TestToBaseUnits(t *testing.T) {
+	t.Parallel()
+	{
+		got, err := ToBaseUnits(0.1, 18)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != "100000000000000000" {
+			t.Errorf("Expected %s, got %s", "100000000000000000", got)
+		}
+	}
+
+	{
+		_, err := ToBaseUnits(struct{}{}, 18)
+		if !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("Expected %v, got %v", ErrInvalidAmount, err)
+		}
+	}
+}
+
+func TestFormatUnits(t *testing.T) {
+	t.Parallel()
+	amount, _ := new(big.Int).SetString("1234567125000000000000", 10)
+	tests := []struct {
+		amount   *big.Int
+		opts     *FormatOptions
+		expected string
+	}{
+		{amount, nil, "1234.567125"},
+		{amount, &FormatOptions{Precision: 2, ThousandsSeparator: ","}, "1,234.57"},
+		{amount, &FormatOptions{Precision: 2, Rounding: RoundDown}, "1234.56"},
+		{amount, &FormatOptions{Precision: 5, Rounding: RoundHalfEven}, "1234.56712"},
+		{amount, &FormatOptions{Precision: 5, Rounding: RoundHalfUp}, "1234.56713"},
+		{amount, &FormatOptions{Precision: WholeUnits, Rounding: RoundUp}, "1235"},
+		{amount, &FormatOptions{Precision: 8}, "1234.56712500"},
+		{new(big.Int).Neg(amount), &FormatOptions{Precision: 1, ThousandsSeparator: " "}, "-1 234.6"},
+		{big.NewInt(5), &FormatOptions{}, "0.000000000000000005"},
+		{big.NewInt(-4), &FormatOptions{Precision: WholeUnits}, "0"},
+		{amount, &FormatOptions{}, "1234.567125000000000000"},
+	}
+
+	for _, tt := range tests {
+		got, err := FormatUnits(tt.amount, 18, tt.opts)
+		if err != nil {
+			t.Errorf("Got error: %s", err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("Expected %s, got %s", tt.expected, got)
+		}
+	}
+}
+
+func TestUnitDecimals(t *testing.T) {
+	t.Parallel()
+	if d, ok := UnitDecimals("GWei"); !ok || d != 9 {
+		t.Errorf("Expected 9, got %d", d)
+	}
+	if _, ok := UnitDecimals("dogecoin"); ok {
+		t.Errorf("Expected an unknown unit")
+	}
+}
@@ -55,7 +55,7 @@ func IsZeroAddress(iaddress interface{}) bool {
 	return reflect.DeepEqual(addressBytes, zeroAddressBytes)
 }
 
-// ToDecimal wei to decimals
+// ToDecimal wei to decimals, see FormatUnits for an error-returning variant
 func ToDecimal(ivalue interface{}, decimals int) decimal.Decimal {
 	value := new(big.Int)
 	switch v := ivalue.(type) {
@@ -72,7 +72,7 @@ func ToDecimal(ivalue interface{}, decimals int) decimal.Decimal {
 	return result
 }
 
-// ToWei decimals to wei
+// ToWei decimals to wei, see ToBaseUnits for an error-returning variant
 func ToWei(iamount interface{}, decimals int) *big.Int {
 	amount := decimal.NewFromFloat(0)
 	switch v := iamount.(type) {
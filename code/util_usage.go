@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/hex"
 	"fmt"
+	"log"
 	"math/big"
 
 	util "ethereum-development-with-go/code/util" // for demo
@@ -28,6 +29,22 @@ func main() {
 	eth := util.ToDecimal(wei, 18)
 	fmt.Println(eth) // 0.02
 
+	gwei, err := util.ParseUnits("1.5 gwei", 18)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(gwei) // 1500000000
+
+	_, err = util.ParseUnits("0.0000001", 6)
+	fmt.Println(err) // too many decimal places: "0.0000001" has more than 6 decimals
+
+	balance, _ := new(big.Int).SetString("1234567125000000000000", 10)
+	display, err := util.FormatUnits(balance, 18, &util.FormatOptions{Precision: 2, ThousandsSeparator: ","})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(display) // 1,234.57
+
 	gasLimit := uint64(21000)
 	gasPrice := new(big.Int)
 	gasPrice.SetString("2000000000", 10)
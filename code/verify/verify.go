@@ -0,0 +1,247 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"ethereum-development-with-go/code/bytecode"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+/*
+ * Verify that the code deployed at an address is that of a compiled
+ * artifact, by running its creation code in a local EVM and comparing the
+ * runtime it returns. The EVM runs up to London, creation code built for
+ * Shanghai or later, such as solc 0.8.20 and later emitting PUSH0 by
+ * default, fails with ErrUnsupportedEVM.
+ */
+
+// gasLimit is the gas given to the creation code
+const gasLimit = 30000000
+
+var (
+	// ErrMismatch is returned by Result.Err when the code does not match
+	ErrMismatch = errors.New("verify: code does not match the artifact")
+	ErrNoCode   = errors.New("verify: no code")
+	ErrCreation = errors.New("verify: creation code failed")
+	// ErrUnsupportedEVM is returned when the creation code runs an opcode of
+	// a fork after London, compile for evmVersion london to verify it
+	ErrUnsupportedEVM = errors.New("verify: unsupported EVM version")
+)
+
+// CodeReader reads code, ethclient and the simulated backend implement it
+type CodeReader interface {
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+}
+
+// Reference is where an immutable lies in the runtime, as solc gives them in
+// evm.deployedBytecode.immutableReferences
+type Reference struct {
+	Start  int `json:"start"`
+	Length int `json:"length"`
+}
+
+// Artifact is the compiled contract to verify against
+type Artifact struct {
+	ABI abi.ABI
+	// Bin is the creation code without constructor arguments
+	Bin []byte
+	// Immutables are ignored when comparing, they may depend on the
+	// deployment block or sender
+	Immutables []Reference
+}
+
+// Match is how closely deployed code matches an artifact
+type Match int
+
+const (
+	// NoMatch is code that differs
+	NoMatch Match = iota
+	// PartialMatch is the same code with another metadata hash, a build of
+	// the same contract from differing sources, comments or settings
+	PartialMatch
+	// FullMatch is the same code and metadata
+	FullMatch
+)
+
+func (m Match) String() string {
+	switch m {
+	case FullMatch:
+		return "full"
+	case PartialMatch:
+		return "partial"
+	}
+	return "none"
+}
+
+// Immutable is the deployed value of an immutable
+type Immutable struct {
+	Reference
+	Value []byte
+}
+
+// Result is the outcome of a verification
+type Result struct {
+	Address common.Address
+	Match   Match
+	// Expected is the runtime of the artifact, Actual the deployed code
+	Expected []byte
+	Actual   []byte
+	// Metadata are those of both codes, nil when missing
+	ExpectedMetadata *bytecode.Metadata
+	ActualMetadata   *bytecode.Metadata
+	Immutables       []Immutable
+	// Offset is the first differing byte, -1 when the codes match
+	Offset int
+}
+
+// Err returns an ErrMismatch unless the match is at least partial
+func (r *Result) Err() error {
+	if r.Match != NoMatch {
+		return nil
+	}
+	return fmt.Errorf("%w: %s differs at byte %d of %d, expected %d", ErrMismatch, r.Address.Hex(), r.Offset, len(r.Actual), len(r.Expected))
+}
+
+// Verify compares the code at address at block, nil being the latest, with
+// the artifact deployed with args. Mismatches are not returned as errors,
+// use Result.Err for that. Arguments only kept in storage do not show in the
+// runtime and so are not verified
+func Verify(ctx context.Context, backend CodeReader, address common.Address, block *big.Int, artifact *Artifact, args ...interface{}) (*Result, error) {
+	input, err := artifact.ABI.Pack("", args...)
+	if err != nil {
+		return nil, err
+	}
+	expected, err := Runtime(address, append(append([]byte{}, artifact.Bin...), input...))
+	if err != nil {
+		return nil, err
+	}
+	actual, err := backend.CodeAt(ctx, address, block)
+	if err != nil {
+		return nil, err
+	}
+	if len(actual) == 0 {
+		return nil, fmt.Errorf("%w at %s", ErrNoCode, address.Hex())
+	}
+	result := Compare(expected, actual, artifact.Immutables)
+	result.Address = address
+	return result, nil
+}
+
+// Runtime runs creation code as if deployed at address and returns the
+// runtime it returns. The block and sender are zero, immutables taken from
+// them differ from those deployed. Opcodes of forks after London, PUSH0
+// among them, fail with ErrUnsupportedEVM
+func Runtime(address common.Address, creation []byte) ([]byte, error) {
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err != nil {
+		return nil, err
+	}
+	blockContext := vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		GetHash:     func(uint64) common.Hash { return common.Hash{} },
+		BlockNumber: new(big.Int),
+		Time:        new(big.Int),
+		Difficulty:  new(big.Int),
+		BaseFee:     new(big.Int),
+		GasLimit:    gasLimit,
+	}
+	tracer := new(faultTracer)
+	evm := vm.NewEVM(blockContext, vm.TxContext{GasPrice: new(big.Int)}, statedb, params.AllEthashProtocolChanges,
+		vm.Config{Debug: true, Tracer: tracer})
+
+	// create the account as CREATE does, so address(this) is that deployed
+	statedb.CreateAccount(address)
+	statedb.SetNonce(address, 1)
+	contract := vm.NewContract(vm.AccountRef(common.Address{}), vm.AccountRef(address), new(big.Int), gasLimit)
+	contract.SetCallCode(&address, crypto.Keccak256Hash(creation), creation)
+	runtime, err := evm.Interpreter().Run(contract, nil, false)
+	if err != nil && tracer.newer {
+		return nil, fmt.Errorf("%w: %s at %d needs a fork after London", ErrUnsupportedEVM, tracer.name, tracer.pc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCreation, err)
+	}
+	return runtime, nil
+}
+
+// faultTracer records a fault on an opcode the EVM does not know yet
+type faultTracer struct {
+	newer bool
+	name  string
+	pc    uint64
+}
+
+func (t *faultTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+	if !t.newer && bytecode.Newer(op) {
+		t.newer = true
+		t.name = bytecode.Instruction{PC: pc, Op: op}.Name()
+		t.pc = pc
+	}
+}
+
+func (t *faultTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+}
+
+func (t *faultTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+}
+
+func (t *faultTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+}
+
+func (t *faultTracer) CaptureExit(output []byte, gasUsed uint64, err error) {}
+
+func (t *faultTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {}
+
+// Compare compares the expected runtime with the deployed code, the bytes of
+// the immutables aside
+func Compare(expected, actual []byte, immutables []Reference) *Result {
+	result := &Result{Expected: expected, Actual: actual, Offset: -1}
+
+	// take the deployed immutables so they compare equal
+	masked := append([]byte{}, expected...)
+	for _, ref := range immutables {
+		if ref.Start < 0 || ref.Length < 0 || ref.Start+ref.Length > len(actual) || ref.Start+ref.Length > len(masked) {
+			continue
+		}
+		value := actual[ref.Start : ref.Start+ref.Length]
+		copy(masked[ref.Start:], value)
+		result.Immutables = append(result.Immutables, Immutable{Reference: ref, Value: value})
+	}
+
+	expectedMetadata, expectedSize := bytecode.ParseMetadata(masked)
+	actualMetadata, actualSize := bytecode.ParseMetadata(actual)
+	result.ExpectedMetadata, result.ActualMetadata = expectedMetadata, actualMetadata
+
+	switch {
+	case bytes.Equal(masked, actual):
+		result.Match = FullMatch
+		return result
+	case bytes.Equal(masked[:len(masked)-expectedSize], actual[:len(actual)-actualSize]):
+		result.Match = PartialMatch
+	}
+	result.Offset = difference(masked, actual)
+	return result
+}
+
+// difference returns the offset of the first byte where a and b differ, the
+// end of the shorter when it prefixes the other
+func difference(a, b []byte) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
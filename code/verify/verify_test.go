@@ -0,0 +1,136 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	store "ethereum-development-with-go/code/contracts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// stamped is creation code whose runtime, PUSH32 <timestamp> STOP, keeps the
+// block timestamp as an immutable at byte 1
+var stamped = common.FromHex("0x602260106000394260015260226000f3" + "7f" + strings.Repeat("00", 32) + "00")
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+	key, _ := crypto.GenerateKey()
+	auth, _ := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{
+		auth.From: {Balance: big.NewInt(1000000000000000000)},
+	}, 8000000)
+	defer backend.Close()
+
+	parsed, err := abi.JSON(strings.NewReader(store.StoreABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifact := &Artifact{ABI: parsed, Bin: common.FromHex(store.StoreBin)}
+
+	deployed, _, _, err := store.DeployStore(auth, backend, "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the same contract built with another metadata hash
+	rebuilt := append([]byte{}, artifact.Bin...)
+	rebuilt[len(rebuilt)-3] ^= 0xff
+	partial, _, _, err := bind.DeployContract(auth, parsed, rebuilt, backend, "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, _, err := bind.DeployContract(auth, abi.ABI{}, stamped, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.Commit()
+
+	ctx := context.Background()
+	for address, expected := range map[common.Address]Match{deployed: FullMatch, partial: PartialMatch, other: NoMatch} {
+		result, err := Verify(ctx, backend, address, nil, artifact, "1.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Match != expected {
+			t.Errorf("Expected a %v match of %v, got %v", expected, address.Hex(), result.Match)
+		}
+		if (result.Err() == nil) != (expected != NoMatch) {
+			t.Errorf("Expected an error only without a match, got %v", result.Err())
+		}
+	}
+
+	result, _ := Verify(ctx, backend, partial, nil, artifact, "1.0")
+	if result.ExpectedMetadata == nil || result.ActualMetadata == nil || result.ExpectedMetadata.Swarm == result.ActualMetadata.Swarm {
+		t.Errorf("Expected differing metadata, got %+v and %+v", result.ExpectedMetadata, result.ActualMetadata)
+	}
+	if result.Offset != len(result.Actual)-3 {
+		t.Errorf("Expected a difference at %d, got %d", len(result.Actual)-3, result.Offset)
+	}
+
+	if _, err := Verify(ctx, backend, auth.From, nil, artifact, "1.0"); !errors.Is(err, ErrNoCode) {
+		t.Errorf("Expected %v, got %v", ErrNoCode, err)
+	}
+}
+
+func TestVerifyImmutables(t *testing.T) {
+	t.Parallel()
+	key, _ := crypto.GenerateKey()
+	auth, _ := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{
+		auth.From: {Balance: big.NewInt(1000000000000000000)},
+	}, 8000000)
+	defer backend.Close()
+
+	address, _, _, err := bind.DeployContract(auth, abi.ABI{}, stamped, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.Commit()
+
+	ctx := context.Background()
+	artifact := &Artifact{Bin: stamped}
+	if result, _ := Verify(ctx, backend, address, nil, artifact); result.Match != NoMatch || result.Offset == -1 {
+		t.Errorf("Expected no match without the immutable, got %v", result.Match)
+	}
+
+	artifact.Immutables = []Reference{{Start: 1, Length: 32}}
+	result, err := Verify(ctx, backend, address, nil, artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Match != FullMatch || len(result.Immutables) != 1 {
+		t.Fatalf("Expected a full match, got %v", result.Match)
+	}
+	header, _ := backend.HeaderByNumber(ctx, nil)
+	if timestamp := new(big.Int).SetBytes(result.Immutables[0].Value); timestamp.Uint64() != header.Time {
+		t.Errorf("Expected the timestamp %d, got %v", header.Time, timestamp)
+	}
+}
+
+func TestRuntime(t *testing.T) {
+	t.Parallel()
+	address := common.HexToAddress("0xbebebebebebebebebebebebebebebebebebebebe")
+	// returns ADDRESS, so address(this) is that given
+	runtime, err := Runtime(address, common.FromHex("0x3060005260206000f3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(runtime, address.Hash().Bytes()) {
+		t.Errorf("Expected %x, got %x", address.Hash(), runtime)
+	}
+	if _, err := Runtime(address, common.FromHex("0xfe")); !errors.Is(err, ErrCreation) {
+		t.Errorf("Expected %v, got %v", ErrCreation, err)
+	}
+	// PUSH0 PUSH0 RETURN, as solc 0.8.20 and later emit for Shanghai
+	if _, err := Runtime(address, common.FromHex("0x5f5ff3")); !errors.Is(err, ErrUnsupportedEVM) {
+		t.Errorf("Expected %v, got %v", ErrUnsupportedEVM, err)
+	}
+}